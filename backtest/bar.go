@@ -0,0 +1,44 @@
+// Package backtest is an event-driven backtesting engine. A Strategy sees the
+// bars one at a time and submits orders, a simulated broker fills them on a
+// later bar and a portfolio ledger records every fill, round-trip trade and
+// the daily equity curve.
+package backtest
+
+import (
+	"time"
+
+	"github.com/markcheno/go-quote"
+)
+
+type Bar struct {
+	Date   time.Time `json:"date"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume float64   `json:"volume"`
+}
+
+// BarsFromQuote converts go-quote's column-oriented series into bars.
+func BarsFromQuote(q quote.Quote) []Bar {
+	bars := make([]Bar, len(q.Close))
+	for i := range q.Close {
+		bars[i] = Bar{
+			Date:   q.Date[i],
+			Open:   q.Open[i],
+			High:   q.High[i],
+			Low:    q.Low[i],
+			Close:  q.Close[i],
+			Volume: q.Volume[i],
+		}
+	}
+	return bars
+}
+
+func Closes(bars []Bar) []float64 {
+	closes := make([]float64, len(bars))
+	for i, bar := range bars {
+		closes[i] = bar.Close
+	}
+	return closes
+}
@@ -0,0 +1,130 @@
+package backtest
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// BuyAndHold invests all cash on the first bar it sees, the first after the
+// warm-up, and never sells.
+type BuyAndHold struct {
+	invested bool
+}
+
+func (s *BuyAndHold) Init(bars []Bar) error {
+	s.invested = false
+	return nil
+}
+
+func (s *BuyAndHold) OnBar(ctx *Context) {
+	if !s.invested {
+		ctx.BuyValue(ctx.Cash(), "buy and hold")
+		s.invested = true
+	}
+}
+
+// Comparison measures a strategy's daily returns against a benchmark's.
+// Alpha is Jensen's alpha, annualised; the information ratio is the
+// annualised mean active return over the tracking error.
+type Comparison struct {
+	Alpha            float64 `json:"alpha"`
+	Beta             float64 `json:"beta"`
+	Correlation      float64 `json:"correlation"`
+	TrackingError    float64 `json:"trackingError"`
+	InformationRatio float64 `json:"informationRatio"`
+	ExcessReturn     float64 `json:"excessReturn"`
+}
+
+type BenchmarkResult struct {
+	Symbol     string     `json:"symbol"`
+	Metrics    Metrics    `json:"metrics"`
+	Comparison Comparison `json:"comparison"`
+}
+
+// Benchmark runs buy-and-hold over bars with the same capital and compares
+// the strategy's equity curve against it.
+func Benchmark(ctx context.Context, symbol string, bars []Bar, strategy []EquityPoint, config Config) (BenchmarkResult, error) {
+	if config.Actions.Symbol != symbol {
+		config.Actions = CorporateActions{}
+	}
+	config.Symbol = symbol
+	config.Margin = Margin{}
+	config.Sizing = Sizing{}
+	config.Progress = nil
+	result, err := Run(ctx, bars, &BuyAndHold{}, config)
+	if err != nil {
+		return BenchmarkResult{}, err
+	}
+
+	return BenchmarkResult{
+		Symbol:     symbol,
+		Metrics:    result.Metrics,
+		Comparison: Compare(strategy, result.Equity, config.RiskFreeRate),
+	}, nil
+}
+
+// Compare aligns both equity curves on their common dates and compares the
+// returns between consecutive common dates.
+func Compare(strategy []EquityPoint, benchmark []EquityPoint, riskFreeRate float64) Comparison {
+	var comparison Comparison
+
+	benchmarkByDate := make(map[time.Time]float64, len(benchmark))
+	for _, point := range benchmark {
+		benchmarkByDate[dateOnly(point.Date)] = point.Equity
+	}
+
+	var strategyReturns, benchmarkReturns []float64
+	var previousStrategy, previousBenchmark float64
+	var started bool
+	for _, point := range strategy {
+		benchmarkEquity, ok := benchmarkByDate[dateOnly(point.Date)]
+		if !ok {
+			continue
+		}
+		if started && previousStrategy > 0 && previousBenchmark > 0 {
+			strategyReturns = append(strategyReturns, point.Equity/previousStrategy-1)
+			benchmarkReturns = append(benchmarkReturns, benchmarkEquity/previousBenchmark-1)
+		}
+		previousStrategy, previousBenchmark = point.Equity, benchmarkEquity
+		started = true
+	}
+
+	if len(strategyReturns) < 2 {
+		return comparison
+	}
+
+	strategyMean, strategyStd := meanStd(strategyReturns)
+	benchmarkMean, benchmarkStd := meanStd(benchmarkReturns)
+
+	active := make([]float64, len(strategyReturns))
+	var covariance float64
+	for i := range strategyReturns {
+		active[i] = strategyReturns[i] - benchmarkReturns[i]
+		covariance += (strategyReturns[i] - strategyMean) * (benchmarkReturns[i] - benchmarkMean)
+	}
+	covariance /= float64(len(strategyReturns) - 1)
+
+	if benchmarkStd > 0 {
+		comparison.Beta = covariance / (benchmarkStd * benchmarkStd)
+	}
+	if benchmarkStd > 0 && strategyStd > 0 {
+		comparison.Correlation = covariance / (strategyStd * benchmarkStd)
+	}
+
+	dailyRiskFree := riskFreeRate / TradingDaysPerYear
+	comparison.Alpha = (strategyMean - dailyRiskFree - comparison.Beta*(benchmarkMean-dailyRiskFree)) * TradingDaysPerYear
+
+	activeMean, activeStd := meanStd(active)
+	comparison.ExcessReturn = activeMean * TradingDaysPerYear
+	comparison.TrackingError = activeStd * math.Sqrt(TradingDaysPerYear)
+	if activeStd > 0 {
+		comparison.InformationRatio = activeMean / activeStd * math.Sqrt(TradingDaysPerYear)
+	}
+
+	return comparison
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,178 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type FillMode string
+
+const (
+	NextOpen  FillMode = "nextOpen"
+	NextClose FillMode = "nextClose"
+)
+
+func ParseFillMode(value string) (FillMode, error) {
+	switch strings.ToLower(value) {
+	case "", strings.ToLower(string(NextOpen)):
+		return NextOpen, nil
+	case strings.ToLower(string(NextClose)):
+		return NextClose, nil
+	}
+	return "", fmt.Errorf("unknown fill mode %q", value)
+}
+
+// Broker simulates order execution: orders submitted on one bar are filled on
+// the following bar at its open or close, adjusted by the slippage, tick size,
+// lot size and fees in Costs. Without Margin, buys are capped by the
+// available cash and sells by the position, so the account is never short or
+// in debt; with it, orders that open or add to a position are capped by the
+// buying power instead, and sells beyond the position go short when shorting
+// is allowed.
+type Broker struct {
+	Mode    FillMode
+	Costs   Costs
+	Margin  Margin
+	pending []Order
+}
+
+func NewBroker(mode FillMode, costs Costs) *Broker {
+	return &Broker{Mode: mode, Costs: costs}
+}
+
+func (b *Broker) Submit(order Order) {
+	b.pending = append(b.pending, order)
+}
+
+func (b *Broker) Pending() []Order {
+	return b.pending
+}
+
+// Split restates the pending orders for symbol in post-split shares.
+func (b *Broker) Split(symbol string, ratio float64) {
+	for i := range b.pending {
+		if b.pending[i].Symbol == symbol || b.pending[i].Symbol == "" {
+			b.pending[i].Quantity *= ratio
+		}
+	}
+}
+
+// Cancel drops every pending order.
+func (b *Broker) Cancel() {
+	b.pending = nil
+}
+
+// Execute fills the pending orders for symbol against bar and books them in
+// portfolio. Orders without a symbol are taken to be for symbol.
+func (b *Broker) Execute(symbol string, bar Bar, portfolio *Portfolio) []Fill {
+	for i := range b.pending {
+		if b.pending[i].Symbol == "" {
+			b.pending[i].Symbol = symbol
+		}
+	}
+	return b.ExecuteBars(map[string]Bar{symbol: bar}, portfolio)
+}
+
+// ExecuteBars fills the pending orders of every symbol that has a bar, sells
+// before buys so a rebalance can spend what it sells. Orders for symbols
+// without a bar stay pending.
+func (b *Broker) ExecuteBars(bars map[string]Bar, portfolio *Portfolio) []Fill {
+	var fills []Fill
+	var pending []Order
+
+	for _, side := range []Side{Sell, Buy} {
+		for _, order := range b.pending {
+			if order.Side != side {
+				continue
+			}
+
+			bar, ok := bars[order.Symbol]
+			if !ok {
+				pending = append(pending, order)
+				continue
+			}
+
+			if fill, ok := b.fill(order, bar, portfolio); ok {
+				fills = append(fills, fill)
+			}
+		}
+	}
+
+	b.pending = pending
+	return fills
+}
+
+func (b *Broker) fill(order Order, bar Bar, portfolio *Portfolio) (Fill, bool) {
+	reference := bar.Open
+	if b.Mode == NextClose {
+		reference = bar.Close
+	}
+	if reference <= 0 {
+		return Fill{}, false
+	}
+
+	quantity := order.Quantity
+	if quantity == 0 && order.Value > 0 {
+		quantity = order.Value / reference
+	}
+
+	price := b.Costs.Price(order.Side, reference, quantity, bar)
+	portfolio.Mark(map[string]float64{order.Symbol: reference})
+	held := portfolio.Position(order.Symbol).Quantity
+
+	switch order.Side {
+	case Buy:
+		if order.Quantity == 0 {
+			quantity = order.Value / price
+		}
+		if !b.Margin.enabled() {
+			quantity = math.Min(b.Costs.Lots(quantity), b.Costs.Affordable(portfolio.Cash, price))
+			break
+		}
+
+		// Covering a short is always allowed; only the rest needs buying power
+		covering := math.Max(-held, 0)
+		if quantity <= covering+epsilon {
+			quantity = math.Min(b.Costs.Lots(quantity), covering)
+			break
+		}
+		power := b.Margin.BuyingPower(portfolio) + covering*reference
+		quantity = covering + math.Min(b.Costs.Lots(quantity-covering), b.Costs.Affordable(power, price))
+	case Sell:
+		closing := math.Max(held, 0)
+		if quantity <= closing+epsilon || !b.Margin.AllowShort {
+			if quantity >= closing {
+				quantity = closing
+			} else {
+				quantity = b.Costs.Lots(quantity)
+			}
+			break
+		}
+
+		power := b.Margin.BuyingPower(portfolio) + closing*reference
+		quantity = closing + math.Min(b.Costs.Lots(quantity-closing), b.Costs.Affordable(power, price))
+	}
+
+	if quantity <= epsilon {
+		return Fill{}, false
+	}
+
+	value := quantity * price
+	fee, tax := b.Costs.Fee(order.Side, value)
+
+	fill := Fill{
+		Date:     bar.Date,
+		Symbol:   order.Symbol,
+		Side:     order.Side,
+		Quantity: quantity,
+		Price:    price,
+		Value:    value,
+		Fee:      fee,
+		Tax:      tax,
+		Slippage: math.Abs(price-reference) * quantity,
+		Reason:   order.Reason,
+	}
+	portfolio.Apply(fill)
+	return fill, true
+}
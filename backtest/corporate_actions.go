@@ -0,0 +1,241 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var ErrInvalidAction = errors.New("invalid corporate action: split ratios must be positive and dividends must not be negative")
+
+// Split replaces every share with Ratio shares from Date on: 2 is a 2-for-1
+// split and 0.1 a 1-for-10 reverse split.
+type Split struct {
+	Date  time.Time `json:"date"`
+	Ratio float64   `json:"ratio"`
+}
+
+// Dividend pays Amount per share to whoever holds the shares before the
+// ex-dividend Date. Amount is what was paid at the time, not adjusted for
+// later splits.
+type Dividend struct {
+	Date   time.Time `json:"date"`
+	Amount float64   `json:"amount"`
+}
+
+type CorporateActions struct {
+	Symbol    string     `json:"symbol"`
+	Splits    []Split    `json:"splits"`
+	Dividends []Dividend `json:"dividends"`
+}
+
+type Adjustment string
+
+const (
+	// Raw prices are the prices the shares traded at.
+	Raw Adjustment = "raw"
+	// SplitAdjusted prices are scaled so splits leave no gap.
+	SplitAdjusted Adjustment = "split"
+	// TotalReturn prices are also scaled down before every ex-date by the
+	// dividend, so the series grows by the total return.
+	TotalReturn Adjustment = "total"
+)
+
+// ParseAdjustment reads an adjustment; empty means TotalReturn, which is
+// what Yahoo's adjusted prices are.
+func ParseAdjustment(value string) (Adjustment, error) {
+	switch Adjustment(value) {
+	case "", TotalReturn:
+		return TotalReturn, nil
+	case Raw, SplitAdjusted:
+		return Adjustment(value), nil
+	}
+	return "", fmt.Errorf("unknown adjustment %q, expected raw, split or total", value)
+}
+
+func (a CorporateActions) Validate() error {
+	for _, split := range a.Splits {
+		if split.Ratio <= 0 {
+			return ErrInvalidAction
+		}
+	}
+	for _, dividend := range a.Dividends {
+		if dividend.Amount < 0 {
+			return ErrInvalidAction
+		}
+	}
+	return nil
+}
+
+// Merge returns the actions of both, sorted by date. An action of other
+// replaces one of the same kind on the same date.
+func (a CorporateActions) Merge(other CorporateActions) CorporateActions {
+	merged := CorporateActions{Symbol: a.Symbol}
+	if merged.Symbol == "" {
+		merged.Symbol = other.Symbol
+	}
+
+	splits := make(map[time.Time]Split)
+	for _, split := range append(append([]Split{}, a.Splits...), other.Splits...) {
+		splits[dateOnly(split.Date)] = split
+	}
+	for _, split := range splits {
+		merged.Splits = append(merged.Splits, split)
+	}
+
+	dividends := make(map[time.Time]Dividend)
+	for _, dividend := range append(append([]Dividend{}, a.Dividends...), other.Dividends...) {
+		dividends[dateOnly(dividend.Date)] = dividend
+	}
+	for _, dividend := range dividends {
+		merged.Dividends = append(merged.Dividends, dividend)
+	}
+
+	merged.sort()
+	return merged
+}
+
+// Between returns the actions dated from start through end; a zero bound is
+// open.
+func (a CorporateActions) Between(start time.Time, end time.Time) CorporateActions {
+	within := func(date time.Time) bool {
+		return (start.IsZero() || !date.Before(start)) && (end.IsZero() || !date.After(end))
+	}
+
+	between := CorporateActions{Symbol: a.Symbol}
+	for _, split := range a.Splits {
+		if within(split.Date) {
+			between.Splits = append(between.Splits, split)
+		}
+	}
+	for _, dividend := range a.Dividends {
+		if within(dividend.Date) {
+			between.Dividends = append(between.Dividends, dividend)
+		}
+	}
+	return between
+}
+
+// For returns the actions a backtest on prices with adjustment still has to
+// apply: all of them on raw prices, the dividends restated per split-adjusted
+// share on split-adjusted prices, and none on total-return prices, which
+// already contain them.
+func (a CorporateActions) For(adjustment Adjustment) CorporateActions {
+	switch adjustment {
+	case Raw:
+		return a
+	case SplitAdjusted:
+		restated := CorporateActions{Symbol: a.Symbol}
+		for _, dividend := range a.Dividends {
+			ratio := 1.0
+			for _, split := range a.Splits {
+				if split.Date.After(dividend.Date) {
+					ratio *= split.Ratio
+				}
+			}
+			restated.Dividends = append(restated.Dividends, Dividend{Date: dividend.Date, Amount: dividend.Amount / ratio})
+		}
+		return restated
+	}
+	return CorporateActions{Symbol: a.Symbol}
+}
+
+func (a *CorporateActions) sort() {
+	sort.Slice(a.Splits, func(i, j int) bool {
+		return a.Splits[i].Date.Before(a.Splits[j].Date)
+	})
+	sort.Slice(a.Dividends, func(i, j int) bool {
+		return a.Dividends[i].Date.Before(a.Dividends[j].Date)
+	})
+}
+
+// ConvertBars restates bars with adjustment from as bars with adjustment to.
+// Adjusted prices are relative to the last bar, which keeps its raw price,
+// so only the actions dated up to the last bar matter.
+func ConvertBars(bars []Bar, actions CorporateActions, from Adjustment, to Adjustment) []Bar {
+	if from == to {
+		return append([]Bar(nil), bars...)
+	}
+	return adjust(unadjust(bars, actions, from), actions, to)
+}
+
+// adjust scales raw bars. Walking back from the last bar, every action dated
+// after a bar multiplies the prices up to that bar: a split by 1 / ratio and
+// a dividend by 1 - amount / close, the close being the raw close before the
+// ex-date.
+func adjust(bars []Bar, actions CorporateActions, to Adjustment) []Bar {
+	adjusted := make([]Bar, len(bars))
+	if to == Raw {
+		copy(adjusted, bars)
+		return adjusted
+	}
+
+	actions.sort()
+	splits, dividends := len(actions.Splits)-1, len(actions.Dividends)-1
+	price, volume := 1.0, 1.0
+
+	for i := len(bars) - 1; i >= 0; i-- {
+		date := bars[i].Date
+		for ; splits >= 0 && actions.Splits[splits].Date.After(date); splits-- {
+			if i < len(bars)-1 {
+				price /= actions.Splits[splits].Ratio
+				volume *= actions.Splits[splits].Ratio
+			}
+		}
+		for ; dividends >= 0 && actions.Dividends[dividends].Date.After(date); dividends-- {
+			if to == TotalReturn && i < len(bars)-1 && bars[i].Close > 0 {
+				price *= 1 - actions.Dividends[dividends].Amount/bars[i].Close
+			}
+		}
+
+		adjusted[i] = scaleBar(bars[i], price, volume)
+	}
+	return adjusted
+}
+
+// unadjust is the inverse of adjust. The raw close before an ex-date is not
+// known until the dividend factor is, so it is solved from
+// adjusted = factor * (raw - amount).
+func unadjust(bars []Bar, actions CorporateActions, from Adjustment) []Bar {
+	raw := make([]Bar, len(bars))
+	if from == Raw {
+		copy(raw, bars)
+		return raw
+	}
+
+	actions.sort()
+	splits, dividends := len(actions.Splits)-1, len(actions.Dividends)-1
+	price, volume := 1.0, 1.0
+
+	for i := len(bars) - 1; i >= 0; i-- {
+		date := bars[i].Date
+		for ; splits >= 0 && actions.Splits[splits].Date.After(date); splits-- {
+			if i < len(bars)-1 {
+				price /= actions.Splits[splits].Ratio
+				volume *= actions.Splits[splits].Ratio
+			}
+		}
+		for ; dividends >= 0 && actions.Dividends[dividends].Date.After(date); dividends-- {
+			if from == TotalReturn && i < len(bars)-1 {
+				amount := actions.Dividends[dividends].Amount
+				close := bars[i].Close/price + amount
+				if close > 0 {
+					price *= 1 - amount/close
+				}
+			}
+		}
+
+		raw[i] = scaleBar(bars[i], 1/price, 1/volume)
+	}
+	return raw
+}
+
+func scaleBar(bar Bar, price float64, volume float64) Bar {
+	bar.Open *= price
+	bar.High *= price
+	bar.Low *= price
+	bar.Close *= price
+	bar.Volume *= volume
+	return bar
+}
@@ -0,0 +1,181 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Commission charges Percent of the trade value (0.0015 is 0.15%) plus Fixed,
+// but never less than Minimum.
+type Commission struct {
+	Percent float64 `json:"percent"`
+	Fixed   float64 `json:"fixed"`
+	Minimum float64 `json:"minimum"`
+}
+
+func (c Commission) Fee(value float64) float64 {
+	if value <= 0 {
+		return 0
+	}
+	return math.Max(value*c.Percent+c.Fixed, c.Minimum)
+}
+
+const (
+	SlippageNone   = "none"
+	SlippageFixed  = "fixed"
+	SlippageVolume = "volume"
+)
+
+// Slippage moves the fill price against the order. The fixed model always
+// costs BPS basis points; the volume model adds Impact basis points for
+// every 1% of the bar's volume the order takes.
+type Slippage struct {
+	Model  string  `json:"model"`
+	BPS    float64 `json:"bps"`
+	Impact float64 `json:"impact"`
+}
+
+// Fraction is the adverse price move, as a fraction of price, for trading
+// quantity shares on bar.
+func (s Slippage) Fraction(quantity float64, bar Bar) float64 {
+	switch s.Model {
+	case SlippageFixed:
+		return s.BPS / 10000
+	case SlippageVolume:
+		fraction := s.BPS / 10000
+		if bar.Volume > 0 {
+			fraction += s.Impact / 10000 * (quantity / bar.Volume * 100)
+		}
+		return fraction
+	}
+	return 0
+}
+
+// TickTier sets the tick size for prices below UpTo; the last tier may leave
+// UpTo zero to cover every higher price.
+type TickTier struct {
+	UpTo float64 `json:"upTo"`
+	Size float64 `json:"size"`
+}
+
+// idxTicks is the Indonesia Stock Exchange price fraction table
+var idxTicks = []TickTier{
+	{UpTo: 200, Size: 1},
+	{UpTo: 500, Size: 2},
+	{UpTo: 2000, Size: 5},
+	{UpTo: 5000, Size: 10},
+	{Size: 25},
+}
+
+// Costs describe what it costs to trade: commissions differ between buying
+// and selling, SellTax is a fraction of the sell value, LotSize restricts
+// quantities to whole lots (0 allows fractional shares) and prices are
+// rounded to TickSize or to the tiers of TickTable ("idx").
+type Costs struct {
+	BuyCommission  Commission `json:"buyCommission"`
+	SellCommission Commission `json:"sellCommission"`
+	SellTax        float64    `json:"sellTax"`
+	Slippage       Slippage   `json:"slippage"`
+	LotSize        float64    `json:"lotSize"`
+	TickSize       float64    `json:"tickSize"`
+	TickTable      string     `json:"tickTable"`
+}
+
+func (c Costs) Validate() error {
+	switch c.Slippage.Model {
+	case "", SlippageNone, SlippageFixed, SlippageVolume:
+	default:
+		return fmt.Errorf("unknown slippage model %q", c.Slippage.Model)
+	}
+
+	switch strings.ToLower(c.TickTable) {
+	case "", "idx":
+	default:
+		return fmt.Errorf("unknown tick table %q", c.TickTable)
+	}
+
+	if c.LotSize < 0 || c.TickSize < 0 || c.SellTax < 0 {
+		return fmt.Errorf("lot size, tick size and sell tax must not be negative")
+	}
+
+	for _, commission := range []Commission{c.BuyCommission, c.SellCommission} {
+		if commission.Percent < 0 || commission.Fixed < 0 || commission.Minimum < 0 {
+			return fmt.Errorf("commission percent, fixed and minimum must not be negative")
+		}
+	}
+
+	if c.Slippage.BPS < 0 || c.Slippage.Impact < 0 {
+		return fmt.Errorf("slippage bps and impact must not be negative")
+	}
+
+	return nil
+}
+
+func (c Costs) tick(price float64) float64 {
+	if c.TickSize > 0 {
+		return c.TickSize
+	}
+	if strings.ToLower(c.TickTable) == "idx" {
+		for _, tier := range idxTicks {
+			if tier.UpTo == 0 || price < tier.UpTo {
+				return tier.Size
+			}
+		}
+	}
+	return 0
+}
+
+// Price applies slippage and tick rounding to the reference price. Buys round
+// up and sells round down, so rounding never improves the fill.
+func (c Costs) Price(side Side, reference float64, quantity float64, bar Bar) float64 {
+	slippage := c.Slippage.Fraction(quantity, bar)
+
+	price := reference * (1 + slippage)
+	if side == Sell {
+		price = reference * (1 - slippage)
+	}
+
+	tick := c.tick(price)
+	if tick <= 0 {
+		return price
+	}
+
+	// Round the tick count first so float noise such as 4.0000000001 ticks is not pushed a whole tick
+	ticks := math.Round(price/tick*1e6) / 1e6
+	if side == Buy {
+		return math.Ceil(ticks) * tick
+	}
+	return math.Max(math.Floor(ticks), 1) * tick
+}
+
+// Lots rounds quantity down to whole lots.
+func (c Costs) Lots(quantity float64) float64 {
+	if c.LotSize <= 0 {
+		return quantity
+	}
+	return math.Floor(quantity/c.LotSize+epsilon) * c.LotSize
+}
+
+// Fee returns the commission and tax on a trade of value.
+func (c Costs) Fee(side Side, value float64) (float64, float64) {
+	if side == Buy {
+		return c.BuyCommission.Fee(value), 0
+	}
+	return c.SellCommission.Fee(value), value * c.SellTax
+}
+
+// Affordable is the largest whole-lot quantity whose value and buy
+// commission fit in cash. The cost of value is the larger of
+// value*(1+Percent)+Fixed and value+Minimum, so both have to fit.
+func (c Costs) Affordable(cash float64, price float64) float64 {
+	if price <= 0 || cash <= 0 {
+		return 0
+	}
+
+	value := math.Min((cash-c.BuyCommission.Fixed)/(1+c.BuyCommission.Percent), cash-c.BuyCommission.Minimum)
+	if value <= 0 {
+		return 0
+	}
+	return c.Lots(value / price)
+}
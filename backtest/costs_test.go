@@ -0,0 +1,71 @@
+package backtest
+
+import "testing"
+
+func TestCommissionFee(t *testing.T) {
+	tests := []struct {
+		name       string
+		commission Commission
+		value      float64
+		fee        float64
+	}{
+		{"percent", Commission{Percent: 0.0015}, 1000000, 1500},
+		{"percent and fixed", Commission{Percent: 0.0015, Fixed: 10}, 1000000, 1510},
+		{"minimum", Commission{Percent: 0.0015, Minimum: 5}, 1000, 5},
+		{"above the minimum", Commission{Percent: 0.0015, Minimum: 5}, 10000, 15},
+		{"nothing traded", Commission{Minimum: 5}, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if fee := test.commission.Fee(test.value); !near(fee, test.fee) {
+				t.Errorf("Fee(%g) = %g, want %g", test.value, fee, test.fee)
+			}
+		})
+	}
+}
+
+func TestSellTax(t *testing.T) {
+	costs := Costs{SellCommission: Commission{Percent: 0.0025}, SellTax: 0.001}
+
+	fee, tax := costs.Fee(Sell, 100000)
+	if !near(fee, 250) || !near(tax, 100) {
+		t.Errorf("sell fee and tax = %g and %g, want 250 and 100", fee, tax)
+	}
+	if fee, tax := costs.Fee(Buy, 100000); fee != 0 || tax != 0 {
+		t.Errorf("buy fee and tax = %g and %g, want none", fee, tax)
+	}
+}
+
+func TestAffordable(t *testing.T) {
+	tests := []struct {
+		name     string
+		costs    Costs
+		cash     float64
+		price    float64
+		quantity float64
+	}{
+		{"whole lots", Costs{LotSize: 100}, 10000, 99, 100},
+		{"less than a lot", Costs{LotSize: 100}, 9899, 99, 0},
+		{"rounded down to a lot", Costs{LotSize: 100}, 25000, 99, 200},
+		{"fractional shares", Costs{}, 9900, 99, 100},
+		{"percent fits", Costs{LotSize: 100, BuyCommission: Commission{Percent: 0.01}}, 10000, 99, 100},
+		{"percent does not fit", Costs{LotSize: 100, BuyCommission: Commission{Percent: 0.02}}, 10000, 99, 0},
+		{"minimum does not fit", Costs{LotSize: 100, BuyCommission: Commission{Minimum: 101}}, 10000, 99, 0},
+		{"no cash", Costs{LotSize: 100}, 0, 99, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quantity := test.costs.Affordable(test.cash, test.price)
+			if !near(quantity, test.quantity) {
+				t.Fatalf("Affordable(%g, %g) = %g, want %g", test.cash, test.price, quantity, test.quantity)
+			}
+
+			fee, _ := test.costs.Fee(Buy, quantity*test.price)
+			if cost := quantity*test.price + fee; cost > test.cash+epsilon {
+				t.Errorf("%g shares cost %g, more than the %g of cash", quantity, cost, test.cash)
+			}
+		})
+	}
+}
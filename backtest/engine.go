@@ -0,0 +1,317 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	ErrNoBars         = errors.New("no bars to backtest")
+	ErrInvalidCapital = errors.New("initial cash must be positive")
+)
+
+// Strategy receives the bars one at a time. Init is called once with the full
+// series so indicators can be precomputed; it must only derive values that
+// are causal (value i uses bars[:i+1]), otherwise the backtest looks ahead.
+type Strategy interface {
+	Init(bars []Bar) error
+	OnBar(ctx *Context)
+}
+
+// Context is what a strategy can see and do on the current bar.
+type Context struct {
+	Index     int
+	Bars      []Bar
+	Symbol    string
+	Portfolio *Portfolio
+	broker    *Broker
+	sizer     *sizer
+}
+
+func (c *Context) Bar() Bar {
+	return c.Bars[c.Index]
+}
+
+func (c *Context) Position() Position {
+	return c.Portfolio.Position(c.Symbol)
+}
+
+func (c *Context) Cash() float64 {
+	return c.Portfolio.Cash
+}
+
+// BuyingPower is the cash on a cash account, and on a margin account what
+// the leverage cap still allows to be opened.
+func (c *Context) BuyingPower() float64 {
+	return c.broker.Margin.BuyingPower(c.Portfolio)
+}
+
+func (c *Context) Submit(order Order) {
+	if order.Symbol == "" {
+		order.Symbol = c.Symbol
+	}
+	c.broker.Submit(order)
+}
+
+func (c *Context) Buy(quantity float64, reason string) {
+	c.Submit(Order{Side: Buy, Quantity: quantity, Reason: reason})
+}
+
+// BuyValue buys as many shares as value pays for at the fill price.
+func (c *Context) BuyValue(value float64, reason string) {
+	c.Submit(Order{Side: Buy, Value: value, Reason: reason})
+}
+
+func (c *Context) Sell(quantity float64, reason string) {
+	c.Submit(Order{Side: Sell, Quantity: quantity, Reason: reason})
+}
+
+// EntryValue is what a new position should be worth under the backtest's
+// Sizing, capped by the buying power.
+func (c *Context) EntryValue() float64 {
+	return c.sizer.value(c.Index, c.Bar().Close, c.Portfolio, c.BuyingPower())
+}
+
+// Short sells quantity shares beyond any long position; the broker only
+// fills the short part when the Margin allows shorting.
+func (c *Context) Short(quantity float64, reason string) {
+	c.Sell(math.Max(c.Position().Quantity, 0)+quantity, reason)
+}
+
+// ShortValue shorts as many shares as value is worth at the fill price.
+func (c *Context) ShortValue(value float64, reason string) {
+	c.Submit(Order{Side: Sell, Value: value, Reason: reason})
+}
+
+// Close sells a long position or buys back a short one.
+func (c *Context) Close(reason string) {
+	quantity := c.Position().Quantity
+	switch {
+	case quantity > 0:
+		c.Sell(quantity, reason)
+	case quantity < 0:
+		c.Buy(-quantity, reason)
+	}
+}
+
+type Config struct {
+	Symbol       string
+	InitialCash  float64
+	Fill         FillMode
+	Costs        Costs
+	RiskFreeRate float64
+	// WarmUp bars only feed the strategy's indicators; trading and the
+	// equity curve start after them.
+	WarmUp int
+	Margin Margin
+	Sizing Sizing
+	// Actions are the splits and dividends of Symbol still to be applied to
+	// the bars, see CorporateActions.For. Each takes effect at the open of
+	// the first bar on or after its date.
+	Actions CorporateActions
+	// Liquidate closes every position at the close of the last bar, paying
+	// the slippage, fees and taxes of the exit, instead of leaving it open
+	// and marked to that close.
+	Liquidate bool
+	// Progress, when set, is called after every bar.
+	Progress func(done int, total int)
+}
+
+type EquityPoint struct {
+	Date     time.Time `json:"date"`
+	Cash     float64   `json:"cash"`
+	Holdings float64   `json:"holdings"`
+	Equity   float64   `json:"equity"`
+}
+
+type Result struct {
+	Symbol      string        `json:"symbol"`
+	InitialCash float64       `json:"initialCash"`
+	FinalEquity float64       `json:"finalEquity"`
+	Cash        float64       `json:"cash"`
+	Shares      float64       `json:"shares"`
+	CostBasis   float64       `json:"costBasis"`
+	GainLoss    float64       `json:"gainLoss"`
+	Fees        float64       `json:"fees"`
+	BorrowFees  float64       `json:"borrowFees"`
+	Interest    float64       `json:"interest"`
+	Dividends   float64       `json:"dividends"`
+	MarginCalls int           `json:"marginCalls"`
+	Fills       []Fill        `json:"fills"`
+	Trades      []Trade       `json:"trades"`
+	Metrics     Metrics       `json:"metrics"`
+	Equity      []EquityPoint `json:"equity"`
+}
+
+// Run replays bars through strategy. On every bar the broker first fills the
+// orders submitted on the previous bar, then the strategy sees the bar, and
+// finally the portfolio is marked to the close for the equity curve. When the
+// Sizing pyramids, units are added at the close once the strategy has left
+// the bar without orders. On a
+// margin account the close is also when borrow fees and interest are charged
+// and the margin is checked; a margin call cancels the pending orders and
+// closes every position on the next bar. Run stops with ctx's error when ctx
+// is cancelled.
+func Run(ctx context.Context, bars []Bar, strategy Strategy, config Config) (Result, error) {
+	if len(bars) == 0 || config.WarmUp >= len(bars) {
+		return Result{}, ErrNoBars
+	}
+	if config.InitialCash <= 0 {
+		return Result{}, ErrInvalidCapital
+	}
+	if err := config.Costs.Validate(); err != nil {
+		return Result{}, err
+	}
+	if err := config.Margin.Validate(); err != nil {
+		return Result{}, err
+	}
+	if err := config.Sizing.Validate(); err != nil {
+		return Result{}, err
+	}
+	if err := config.Actions.Validate(); err != nil {
+		return Result{}, err
+	}
+
+	if err := strategy.Init(bars); err != nil {
+		return Result{}, err
+	}
+
+	portfolio := NewPortfolio(config.InitialCash)
+	broker := NewBroker(config.Fill, config.Costs)
+	broker.Margin = config.Margin
+	sizer := newSizer(config.Sizing, bars)
+	equity := make([]EquityPoint, 0, len(bars)-config.WarmUp)
+	marginCalls := 0
+	called := false
+
+	actions := config.Actions.Merge(CorporateActions{})
+	splits, dividends := actions.Splits, actions.Dividends
+
+	for i, bar := range bars {
+		select {
+		case <-ctx.Done():
+			return Result{}, ctx.Err()
+		default:
+		}
+
+		for ; len(splits) > 0 && !splits[0].Date.After(bar.Date); splits = splits[1:] {
+			if i > config.WarmUp {
+				portfolio.Split(config.Symbol, splits[0].Ratio)
+				broker.Split(config.Symbol, splits[0].Ratio)
+				sizer.split(splits[0].Ratio)
+			}
+		}
+		for ; len(dividends) > 0 && !dividends[0].Date.After(bar.Date); dividends = dividends[1:] {
+			if i > config.WarmUp {
+				portfolio.Distribute(config.Symbol, dividends[0].Amount)
+			}
+		}
+
+		if i < config.WarmUp {
+			continue
+		}
+
+		fills := broker.Execute(config.Symbol, bar, portfolio)
+		sizer.track(config.Symbol, fills, portfolio)
+
+		barCtx := &Context{
+			Index:     i,
+			Bars:      bars[:i+1],
+			Symbol:    config.Symbol,
+			Portfolio: portfolio,
+			broker:    broker,
+			sizer:     sizer,
+		}
+		strategy.OnBar(barCtx)
+
+		prices := map[string]float64{config.Symbol: bar.Close}
+		portfolio.Mark(prices)
+
+		held := barCtx.Position().Quantity
+		if len(broker.Pending()) == 0 && sizer.pyramid(held, bar.Close) {
+			if held > 0 {
+				barCtx.BuyValue(barCtx.EntryValue(), "pyramid")
+			} else {
+				barCtx.ShortValue(barCtx.EntryValue(), "pyramid")
+			}
+		}
+
+		portfolio.Finance(config.Margin)
+
+		if config.Margin.MarginCall(portfolio) {
+			if !called {
+				marginCalls++
+			}
+			called = true
+
+			broker.Cancel()
+			for symbol, position := range portfolio.Positions {
+				side := Sell
+				if position.Quantity < 0 {
+					side = Buy
+				}
+				broker.Submit(Order{Symbol: symbol, Side: side, Quantity: math.Abs(position.Quantity), Reason: "margin call"})
+			}
+		} else {
+			called = false
+		}
+
+		total := portfolio.Equity(prices)
+		equity = append(equity, EquityPoint{
+			Date:     bar.Date,
+			Cash:     portfolio.Cash,
+			Holdings: total - portfolio.Cash,
+			Equity:   total,
+		})
+
+		if config.Progress != nil {
+			config.Progress(i+1-config.WarmUp, len(bars)-config.WarmUp)
+		}
+	}
+
+	last := bars[len(bars)-1]
+	prices := map[string]float64{config.Symbol: last.Close}
+
+	if config.Liquidate && len(portfolio.Positions) > 0 {
+		closer := NewBroker(NextClose, config.Costs)
+		closer.Margin = config.Margin
+		for symbol, position := range portfolio.Positions {
+			side := Sell
+			if position.Quantity < 0 {
+				side = Buy
+			}
+			closer.Submit(Order{Symbol: symbol, Side: side, Quantity: math.Abs(position.Quantity), Reason: "liquidate"})
+		}
+		closer.Execute(config.Symbol, last, portfolio)
+
+		total := portfolio.Equity(prices)
+		equity[len(equity)-1].Cash = portfolio.Cash
+		equity[len(equity)-1].Holdings = total - portfolio.Cash
+		equity[len(equity)-1].Equity = total
+	}
+
+	position := portfolio.Position(config.Symbol)
+
+	result := Result{
+		Symbol:      config.Symbol,
+		InitialCash: config.InitialCash,
+		FinalEquity: portfolio.Equity(prices),
+		Cash:        portfolio.Cash,
+		Shares:      position.Quantity,
+		CostBasis:   position.Quantity * position.AvgPrice,
+		Fees:        portfolio.Fees,
+		BorrowFees:  portfolio.BorrowFees,
+		Interest:    portfolio.Interest,
+		Dividends:   portfolio.Dividends,
+		MarginCalls: marginCalls,
+		Fills:       portfolio.Fills,
+		Trades:      append(portfolio.Trades, portfolio.OpenTrades(last.Date, prices)...),
+		Equity:      equity,
+	}
+	result.GainLoss = result.FinalEquity - result.InitialCash
+	result.Metrics = ComputeMetrics(result.Equity, result.Trades, config.RiskFreeRate)
+
+	return result, nil
+}
@@ -0,0 +1,151 @@
+package backtest
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// script is a strategy that runs the step for a bar index, if there is one.
+type script map[int]func(ctx *Context)
+
+func (s script) Init(bars []Bar) error {
+	return nil
+}
+
+func (s script) OnBar(ctx *Context) {
+	if step, ok := s[ctx.Index]; ok {
+		step(ctx)
+	}
+}
+
+func near(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestFills(t *testing.T) {
+	bars := testBars(100, 100, 110, 120)
+	bars[1].Open = 105
+
+	tests := []struct {
+		name  string
+		fill  FillMode
+		costs Costs
+		price float64
+		fee   float64
+	}{
+		{"next open", NextOpen, Costs{}, 105, 0},
+		{"next close", NextClose, Costs{}, 100, 0},
+		{"slippage and ticks", NextOpen, Costs{Slippage: Slippage{Model: SlippageFixed, BPS: 10}, TickSize: 0.5}, 105.5, 0},
+		{"commission", NextOpen, Costs{BuyCommission: Commission{Percent: 0.001}}, 105, 1.05},
+		{"minimum fee", NextOpen, Costs{BuyCommission: Commission{Percent: 0.001, Minimum: 5}}, 105, 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			strategy := script{0: func(ctx *Context) { ctx.Buy(10, "entry") }}
+			config := Config{Symbol: "TEST", InitialCash: 10000, Fill: test.fill, Costs: test.costs}
+
+			result, err := Run(context.Background(), bars, strategy, config)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if len(result.Fills) != 1 {
+				t.Fatalf("fills = %+v, want one", result.Fills)
+			}
+
+			fill := result.Fills[0]
+			if !fill.Date.Equal(bars[1].Date) || !near(fill.Price, test.price) || !near(fill.Fee, test.fee) {
+				t.Errorf("fill = %s @ %g fee %g, want %s @ %g fee %g", fill.Date.Format("2006-01-02"), fill.Price, fill.Fee, bars[1].Date.Format("2006-01-02"), test.price, test.fee)
+			}
+			if want := 10000 - 10*test.price - test.fee; !near(result.Cash, want) {
+				t.Errorf("cash = %g, want %g", result.Cash, want)
+			}
+		})
+	}
+}
+
+func TestMarginCall(t *testing.T) {
+	bars := testBars(100, 100, 100, 70, 70, 70)
+	strategy := script{0: func(ctx *Context) { ctx.BuyValue(ctx.BuyingPower(), "entry") }}
+	config := Config{
+		Symbol:      "TEST",
+		InitialCash: 10000,
+		Margin:      Margin{MaxLeverage: 2, MaintenanceMargin: 0.5},
+	}
+
+	result, err := Run(context.Background(), bars, strategy, config)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// 200 shares on 10000 of cash; at 70 the equity of 4000 is below half the 14000 held
+	if len(result.Fills) != 2 || result.Fills[0].Quantity != 200 {
+		t.Fatalf("fills = %+v, want 200 bought and sold", result.Fills)
+	}
+	sold := result.Fills[1]
+	if sold.Reason != "margin call" || !sold.Date.Equal(bars[4].Date) || sold.Price != 70 {
+		t.Errorf("sell = %q on %s @ %g, want the margin call on %s @ 70", sold.Reason, sold.Date.Format("2006-01-02"), sold.Price, bars[4].Date.Format("2006-01-02"))
+	}
+	if result.MarginCalls != 1 || result.Shares != 0 {
+		t.Errorf("margin calls = %d with %g shares left, want 1 with none", result.MarginCalls, result.Shares)
+	}
+	if !near(result.FinalEquity, 4000) {
+		t.Errorf("final equity = %g, want 4000", result.FinalEquity)
+	}
+}
+
+func TestSplitAfterWarmUp(t *testing.T) {
+	// raw prices: the 2:1 split halves the price on the sixth bar
+	bars := testBars(100, 100, 100, 100, 100, 50, 50, 50)
+	strategy := script{2: func(ctx *Context) { ctx.Buy(100, "entry") }}
+
+	tests := []struct {
+		name   string
+		split  int
+		shares float64
+	}{
+		{"after the warm-up", 5, 200},
+		{"during the warm-up", 1, 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := Config{
+				Symbol:      "TEST",
+				InitialCash: 100000,
+				WarmUp:      2,
+				Actions:     CorporateActions{Symbol: "TEST", Splits: []Split{{Date: bars[test.split].Date, Ratio: 2}}},
+			}
+
+			result, err := Run(context.Background(), bars, strategy, config)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if result.Shares != test.shares {
+				t.Errorf("shares = %g, want %g", result.Shares, test.shares)
+			}
+			if len(result.Equity) != len(bars)-2 {
+				t.Errorf("equity points = %d, want %d after the warm-up", len(result.Equity), len(bars)-2)
+			}
+		})
+	}
+}
+
+func TestBenchmarkAfterWarmUp(t *testing.T) {
+	bars := testBars(100, 100, 100, 110, 120, 130)
+	strategy := equityCurve(10000, 10000, 10000, 10000)
+
+	for _, warmUp := range []int{0, 2} {
+		config := Config{Symbol: "TEST", InitialCash: 10000, WarmUp: warmUp}
+
+		result, err := Benchmark(context.Background(), "TEST", bars, strategy, config)
+		if err != nil {
+			t.Fatalf("Benchmark with a warm-up of %d: %v", warmUp, err)
+		}
+		// bought at 100 on the bar after the first one seen, held to 130
+		if !near(result.Metrics.TotalReturn, 0.3) {
+			t.Errorf("benchmark return with a warm-up of %d = %g, want 0.3", warmUp, result.Metrics.TotalReturn)
+		}
+	}
+}
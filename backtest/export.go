@@ -0,0 +1,69 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+const exportDate = "2006-01-02"
+
+// WriteTradesCSV writes the trade blotter, one round trip per row.
+func WriteTradesCSV(w io.Writer, trades []Trade) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"symbol", "direction", "entry_date", "exit_date", "entry_price", "exit_price", "quantity", "fees", "pnl", "return", "reason", "open"})
+
+	for _, trade := range trades {
+		writer.Write([]string{
+			trade.Symbol,
+			trade.Direction,
+			trade.EntryDate.Format(exportDate),
+			trade.ExitDate.Format(exportDate),
+			formatFloat(trade.EntryPrice),
+			formatFloat(trade.ExitPrice),
+			formatFloat(trade.Quantity),
+			formatFloat(trade.Fees),
+			formatFloat(trade.PnL),
+			formatFloat(trade.Return),
+			trade.Reason,
+			strconv.FormatBool(trade.Open),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteEquityCSV writes the daily equity curve with the drawdown from the
+// running peak.
+func WriteEquityCSV(w io.Writer, equity []EquityPoint) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"date", "cash", "holdings", "equity", "drawdown"})
+
+	var peak float64
+	for _, point := range equity {
+		if point.Equity > peak {
+			peak = point.Equity
+		}
+
+		var drawdown float64
+		if peak > 0 {
+			drawdown = (peak - point.Equity) / peak
+		}
+
+		writer.Write([]string{
+			point.Date.Format(exportDate),
+			formatFloat(point.Cash),
+			formatFloat(point.Holdings),
+			formatFloat(point.Equity),
+			formatFloat(drawdown),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,68 @@
+package backtest
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrInvalidMargin = errors.New("invalid margin: leverage and rates must not be negative and the maintenance margin must be below 1")
+
+// Margin turns the cash account into a margin account. The zero value keeps
+// it long only and without borrowing.
+//
+// MaxLeverage caps gross exposure (longs plus shorts) at that multiple of
+// equity when orders are filled; 0 and 1 both mean no borrowing. When equity
+// falls below MaintenanceMargin times gross exposure, or to zero, every
+// position is liquidated on the next bar. BorrowRate is the annual fee on
+// the value of short positions and MarginRate the annual interest on a
+// negative cash balance, both charged daily.
+type Margin struct {
+	AllowShort        bool    `json:"allowShort"`
+	MaxLeverage       float64 `json:"maxLeverage"`
+	MaintenanceMargin float64 `json:"maintenanceMargin"`
+	BorrowRate        float64 `json:"borrowRate"`
+	MarginRate        float64 `json:"marginRate"`
+}
+
+func (m Margin) Validate() error {
+	if m.MaxLeverage < 0 || m.BorrowRate < 0 || m.MarginRate < 0 || m.MaintenanceMargin < 0 || m.MaintenanceMargin >= 1 {
+		return ErrInvalidMargin
+	}
+	return nil
+}
+
+func (m Margin) enabled() bool {
+	return m.AllowShort || m.MaxLeverage > 1
+}
+
+func (m Margin) leverage() float64 {
+	return math.Max(m.MaxLeverage, 1)
+}
+
+// BuyingPower is what can still be spent on opening positions: the cash
+// without margin, otherwise equity times the leverage cap less the gross
+// exposure already held.
+func (m Margin) BuyingPower(portfolio *Portfolio) float64 {
+	if !m.enabled() {
+		return portfolio.Cash
+	}
+
+	long, short := portfolio.Exposure()
+	return math.Max(portfolio.MarkedEquity()*m.leverage()-long-short, 0)
+}
+
+// MarginCall reports whether the account has to be liquidated.
+func (m Margin) MarginCall(portfolio *Portfolio) bool {
+	if !m.enabled() {
+		return false
+	}
+
+	long, short := portfolio.Exposure()
+	gross := long + short
+	if gross <= epsilon {
+		return false
+	}
+
+	equity := portfolio.MarkedEquity()
+	return equity <= 0 || equity < m.MaintenanceMargin*gross
+}
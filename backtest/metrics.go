@@ -0,0 +1,179 @@
+package backtest
+
+import "math"
+
+// TradingDaysPerYear annualises daily returns and volatility
+const TradingDaysPerYear = 252
+
+type Metrics struct {
+	TotalReturn         float64 `json:"totalReturn"`
+	CAGR                float64 `json:"cagr"`
+	AnnualVolatility    float64 `json:"annualVolatility"`
+	Sharpe              float64 `json:"sharpe"`
+	Sortino             float64 `json:"sortino"`
+	Calmar              float64 `json:"calmar"`
+	MaxDrawdown         float64 `json:"maxDrawdown"`
+	MaxDrawdownDuration int     `json:"maxDrawdownDuration"`
+	WinRate             float64 `json:"winRate"`
+	ProfitFactor        float64 `json:"profitFactor"`
+	AverageWin          float64 `json:"averageWin"`
+	AverageLoss         float64 `json:"averageLoss"`
+	Exposure            float64 `json:"exposure"`
+	Trades              int     `json:"trades"`
+}
+
+// ComputeMetrics derives performance statistics from a daily equity curve and
+// its trades. Ratios are returned as fractions (0.12 is 12%), riskFreeRate is
+// annual, and MaxDrawdownDuration counts bars from a peak until equity
+// recovers it (or the curve ends). Only closed trades enter the trade
+// statistics. Undefined ratios, such as a profit factor without losing
+// trades, are reported as zero so the result always encodes as JSON.
+func ComputeMetrics(equity []EquityPoint, trades []Trade, riskFreeRate float64) Metrics {
+	var metrics Metrics
+	if len(equity) == 0 {
+		return metrics
+	}
+
+	first := equity[0].Equity
+	last := equity[len(equity)-1].Equity
+	if first > 0 {
+		metrics.TotalReturn = last/first - 1
+	}
+
+	years := equity[len(equity)-1].Date.Sub(equity[0].Date).Hours() / 24 / 365.25
+	if years > 0 && first > 0 && last > 0 {
+		metrics.CAGR = math.Pow(last/first, 1/years) - 1
+	}
+
+	returns := DailyReturns(equity)
+	dailyRiskFree := riskFreeRate / TradingDaysPerYear
+
+	mean, std := meanStd(returns)
+	metrics.AnnualVolatility = std * math.Sqrt(TradingDaysPerYear)
+	if std > 0 {
+		metrics.Sharpe = (mean - dailyRiskFree) / std * math.Sqrt(TradingDaysPerYear)
+	}
+
+	var downside float64
+	for _, r := range returns {
+		if excess := r - dailyRiskFree; excess < 0 {
+			downside += excess * excess
+		}
+	}
+	if len(returns) > 0 && downside > 0 {
+		downsideDeviation := math.Sqrt(downside / float64(len(returns)))
+		metrics.Sortino = (mean - dailyRiskFree) / downsideDeviation * math.Sqrt(TradingDaysPerYear)
+	}
+
+	metrics.MaxDrawdown, metrics.MaxDrawdownDuration = maxDrawdown(equity)
+	if metrics.MaxDrawdown > 0 {
+		metrics.Calmar = metrics.CAGR / metrics.MaxDrawdown
+	}
+
+	var exposed int
+	for _, point := range equity {
+		if math.Abs(point.Holdings) > epsilon {
+			exposed++
+		}
+	}
+	metrics.Exposure = float64(exposed) / float64(len(equity))
+
+	var wins, losses int
+	var grossProfit, grossLoss float64
+	for _, trade := range trades {
+		if trade.Open {
+			continue
+		}
+		metrics.Trades++
+		if trade.PnL > 0 {
+			wins++
+			grossProfit += trade.PnL
+		} else if trade.PnL < 0 {
+			losses++
+			grossLoss -= trade.PnL
+		}
+	}
+	if metrics.Trades > 0 {
+		metrics.WinRate = float64(wins) / float64(metrics.Trades)
+	}
+	if wins > 0 {
+		metrics.AverageWin = grossProfit / float64(wins)
+	}
+	if losses > 0 {
+		metrics.AverageLoss = -grossLoss / float64(losses)
+	}
+	if grossLoss > 0 {
+		metrics.ProfitFactor = grossProfit / grossLoss
+	}
+
+	return metrics
+}
+
+// DailyReturns is the bar-to-bar return of the equity curve.
+func DailyReturns(equity []EquityPoint) []float64 {
+	if len(equity) < 2 {
+		return nil
+	}
+
+	returns := make([]float64, 0, len(equity)-1)
+	for i := 1; i < len(equity); i++ {
+		previous := equity[i-1].Equity
+		if previous == 0 {
+			returns = append(returns, 0)
+			continue
+		}
+		returns = append(returns, equity[i].Equity/previous-1)
+	}
+	return returns
+}
+
+// meanStd returns the mean and sample standard deviation of values
+func meanStd(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	if len(values) < 2 {
+		return mean, 0
+	}
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
+
+// maxDrawdown returns the deepest peak-to-trough decline as a fraction of the
+// peak and the longest run of bars spent below a previous peak
+func maxDrawdown(equity []EquityPoint) (float64, int) {
+	var deepest float64
+	var longest int
+
+	peak := equity[0].Equity
+	peakIndex := 0
+	for i, point := range equity {
+		if point.Equity >= peak {
+			peak = point.Equity
+			peakIndex = i
+			continue
+		}
+
+		if peak > 0 {
+			if drawdown := (peak - point.Equity) / peak; drawdown > deepest {
+				deepest = drawdown
+			}
+		}
+		if duration := i - peakIndex; duration > longest {
+			longest = duration
+		}
+	}
+
+	return deepest, longest
+}
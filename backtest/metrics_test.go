@@ -0,0 +1,68 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+)
+
+func equityCurve(values ...float64) []EquityPoint {
+	bars := testBars(values...)
+	equity := make([]EquityPoint, len(bars))
+	for i, bar := range bars {
+		equity[i] = EquityPoint{Date: bar.Date, Cash: bar.Close, Equity: bar.Close}
+	}
+	return equity
+}
+
+func TestMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		equity   []float64
+		sharpe   float64
+		drawdown float64
+		duration int
+	}{
+		{
+			name:     "one drawdown",
+			equity:   []float64{100, 110, 99, 121},
+			sharpe:   7.2287657613,
+			drawdown: 0.1,
+			duration: 1,
+		},
+		{
+			name:     "never recovered",
+			equity:   []float64{100, 80, 90, 60, 70},
+			sharpe:   -3.9163919652,
+			drawdown: 0.4,
+			duration: 4,
+		},
+		{
+			name:     "flat",
+			equity:   []float64{100, 100, 100},
+			sharpe:   0,
+			drawdown: 0,
+			duration: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metrics := ComputeMetrics(equityCurve(test.equity...), nil, 0)
+
+			if math.Abs(metrics.Sharpe-test.sharpe) > 1e-9 {
+				t.Errorf("sharpe = %g, want %g", metrics.Sharpe, test.sharpe)
+			}
+			if !near(metrics.MaxDrawdown, test.drawdown) {
+				t.Errorf("max drawdown = %g, want %g", metrics.MaxDrawdown, test.drawdown)
+			}
+			if metrics.MaxDrawdownDuration != test.duration {
+				t.Errorf("max drawdown duration = %d, want %d", metrics.MaxDrawdownDuration, test.duration)
+			}
+
+			last, first := test.equity[len(test.equity)-1], test.equity[0]
+			if !near(metrics.TotalReturn, last/first-1) {
+				t.Errorf("total return = %g, want %g", metrics.TotalReturn, last/first-1)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package backtest
+
+import (
+	"fmt"
+
+	"github.com/markcheno/go-talib"
+)
+
+// MomentumRSI buys when the close is below BuyThreshold times its moving
+// average while RSI is oversold, and exits when the close rises above
+// SellThreshold times the average, drops StopLoss below the entry price or
+// TrailingStop below the highest close since entry.
+type MomentumRSI struct {
+	WindowSize    int
+	RSIPeriod     int
+	OversoldRSI   float64
+	BuyThreshold  float64
+	SellThreshold float64
+	StopLoss      float64
+	TrailingStop  float64
+
+	averagePrices []float64
+	rsi           []float64
+	entryPrice    float64
+	highestPrice  float64
+}
+
+func NewMomentumRSI(buyThreshold float64, sellThreshold float64) *MomentumRSI {
+	return &MomentumRSI{
+		WindowSize:    30,
+		RSIPeriod:     14,
+		OversoldRSI:   30,
+		BuyThreshold:  buyThreshold,
+		SellThreshold: sellThreshold,
+		StopLoss:      0.05,
+		TrailingStop:  0.1,
+	}
+}
+
+// Init fails unless there are more bars than the longer of the two periods,
+// which is what the moving average and RSI need for a first value.
+func (s *MomentumRSI) Init(bars []Bar) error {
+	if s.WindowSize < 1 || s.RSIPeriod < 1 {
+		return fmt.Errorf("momentum RSI periods must be positive")
+	}
+	period := s.WindowSize
+	if s.RSIPeriod > period {
+		period = s.RSIPeriod
+	}
+	if len(bars) <= period {
+		return fmt.Errorf("momentum RSI needs more than %d bars", period)
+	}
+
+	closes := Closes(bars)
+	s.averagePrices = talib.Sma(closes, s.WindowSize)
+	s.rsi = talib.Rsi(closes, s.RSIPeriod)
+	s.entryPrice = 0
+	s.highestPrice = 0
+	return nil
+}
+
+func (s *MomentumRSI) OnBar(ctx *Context) {
+	i := ctx.Index
+	if i < s.WindowSize-1 || i < s.RSIPeriod || s.averagePrices[i] == 0 {
+		return
+	}
+
+	stockPrice := ctx.Bar().Close
+	momentum := stockPrice / s.averagePrices[i]
+	position := ctx.Position()
+
+	if position.Quantity > 0 {
+		if s.entryPrice == 0 {
+			s.entryPrice = position.AvgPrice
+			s.highestPrice = position.AvgPrice
+		}
+		if stockPrice > s.highestPrice {
+			s.highestPrice = stockPrice
+		}
+
+		switch {
+		case momentum > s.SellThreshold:
+			ctx.Close("momentum above sell threshold")
+		case stockPrice < s.entryPrice*(1-s.StopLoss):
+			ctx.Close("stop loss")
+		case stockPrice < s.highestPrice*(1-s.TrailingStop):
+			ctx.Close("trailing stop")
+		}
+		return
+	}
+
+	s.entryPrice = 0
+	s.highestPrice = 0
+
+	// Only buy if RSI is oversold
+	if momentum < s.BuyThreshold && s.rsi[i] < s.OversoldRSI && ctx.EntryValue() > 0 {
+		ctx.BuyValue(ctx.EntryValue(), "momentum below buy threshold and RSI oversold")
+	}
+}
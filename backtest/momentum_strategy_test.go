@@ -0,0 +1,63 @@
+package backtest
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testBars makes one daily bar per close, opening at the previous close.
+func testBars(closes ...float64) []Bar {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	bars := make([]Bar, len(closes))
+	for i, close := range closes {
+		open := close
+		if i > 0 {
+			open = closes[i-1]
+		}
+		bars[i] = Bar{
+			Date:   start.AddDate(0, 0, i),
+			Open:   open,
+			High:   math.Max(open, close),
+			Low:    math.Min(open, close),
+			Close:  close,
+			Volume: 1000000,
+		}
+	}
+	return bars
+}
+
+func rising(n int) []float64 {
+	closes := make([]float64, n)
+	for i := range closes {
+		closes[i] = 100 + float64(i)
+	}
+	return closes
+}
+
+func TestMomentumRSIShortSeries(t *testing.T) {
+	tests := []struct {
+		name string
+		bars int
+		ok   bool
+	}{
+		{"no more bars than the window", 30, false},
+		{"ten bars", 10, false},
+		{"one bar past the window", 31, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := Config{Symbol: "TEST", InitialCash: 1000000}
+			_, err := Run(context.Background(), testBars(rising(test.bars)...), NewMomentumRSI(0.95, 1.05), config)
+			if test.ok && err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if !test.ok && (err == nil || !strings.Contains(err.Error(), "needs more than 30 bars")) {
+				t.Fatalf("Run error = %v, want it to need more than 30 bars", err)
+			}
+		})
+	}
+}
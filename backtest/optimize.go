@@ -0,0 +1,456 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MaxTrials bounds how many backtests one optimisation may run.
+const MaxTrials = 10000
+
+var (
+	ErrNoRanges      = errors.New("optimisation needs at least one parameter range")
+	ErrInvalidRange  = errors.New("invalid parameter range")
+	ErrTooManyTrials = fmt.Errorf("optimisation would run more than %d backtests", MaxTrials)
+)
+
+type Search string
+
+const (
+	GridSearch   Search = "grid"
+	RandomSearch Search = "random"
+)
+
+func ParseSearch(value string) (Search, error) {
+	switch Search(value) {
+	case "", GridSearch:
+		return GridSearch, nil
+	case RandomSearch:
+		return RandomSearch, nil
+	}
+	return "", fmt.Errorf("unknown search %q", value)
+}
+
+// Objective is the metric trials are ranked by, higher is better.
+// MaxDrawdown ranks the shallowest drawdown first.
+type Objective string
+
+const (
+	ObjectiveSharpe       Objective = "sharpe"
+	ObjectiveSortino      Objective = "sortino"
+	ObjectiveCalmar       Objective = "calmar"
+	ObjectiveCAGR         Objective = "cagr"
+	ObjectiveTotalReturn  Objective = "totalReturn"
+	ObjectiveProfitFactor Objective = "profitFactor"
+	ObjectiveMaxDrawdown  Objective = "maxDrawdown"
+)
+
+func ParseObjective(value string) (Objective, error) {
+	switch objective := Objective(value); objective {
+	case "":
+		return ObjectiveSharpe, nil
+	case ObjectiveSharpe, ObjectiveSortino, ObjectiveCalmar, ObjectiveCAGR, ObjectiveTotalReturn, ObjectiveProfitFactor, ObjectiveMaxDrawdown:
+		return objective, nil
+	}
+	return "", fmt.Errorf("unknown objective %q", value)
+}
+
+func (o Objective) Score(metrics Metrics) float64 {
+	switch o {
+	case ObjectiveSortino:
+		return metrics.Sortino
+	case ObjectiveCalmar:
+		return metrics.Calmar
+	case ObjectiveCAGR:
+		return metrics.CAGR
+	case ObjectiveTotalReturn:
+		return metrics.TotalReturn
+	case ObjectiveProfitFactor:
+		return metrics.ProfitFactor
+	case ObjectiveMaxDrawdown:
+		return -metrics.MaxDrawdown
+	}
+	return metrics.Sharpe
+}
+
+// ParamRange is the set of values tried for one parameter: either Values,
+// or Min to Max in steps of Step. Random search without a Step draws
+// uniformly between Min and Max, rounding when Integer is set.
+type ParamRange struct {
+	Name    string    `json:"name"`
+	Min     float64   `json:"min"`
+	Max     float64   `json:"max"`
+	Step    float64   `json:"step"`
+	Values  []float64 `json:"values"`
+	Integer bool      `json:"integer"`
+}
+
+// Grid lists the values of a stepped or enumerated range.
+func (r ParamRange) Grid() ([]float64, error) {
+	if len(r.Values) > 0 {
+		for _, value := range r.Values {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				return nil, fmt.Errorf("%w: %s has a value that is not a finite number", ErrInvalidRange, r.Name)
+			}
+		}
+		return r.Values, nil
+	}
+	for _, bound := range []float64{r.Min, r.Max, r.Step} {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return nil, fmt.Errorf("%w: %s needs a finite min, max and step", ErrInvalidRange, r.Name)
+		}
+	}
+	if r.Step <= 0 {
+		return nil, fmt.Errorf("%w: %s needs values or a positive step", ErrInvalidRange, r.Name)
+	}
+	if r.Max < r.Min {
+		return nil, fmt.Errorf("%w: %s has max below min", ErrInvalidRange, r.Name)
+	}
+
+	// compared as a float first, a huge range would overflow the int
+	steps := math.Floor((r.Max-r.Min)/r.Step + 1e-9)
+	if steps+1 > MaxTrials {
+		return nil, ErrTooManyTrials
+	}
+	count := int(steps) + 1
+
+	values := make([]float64, count)
+	for i := range values {
+		// rounding keeps 0.1 steps from drifting to 0.30000000000000004
+		values[i] = math.Round((r.Min+float64(i)*r.Step)*1e9) / 1e9
+	}
+	return values, nil
+}
+
+func (r ParamRange) sample(random *rand.Rand) (float64, error) {
+	if len(r.Values) > 0 || r.Step > 0 {
+		values, err := r.Grid()
+		if err != nil {
+			return 0, err
+		}
+		return values[random.Intn(len(values))], nil
+	}
+	if r.Max < r.Min {
+		return 0, fmt.Errorf("%w: %s has max below min", ErrInvalidRange, r.Name)
+	}
+
+	value := r.Min + random.Float64()*(r.Max-r.Min)
+	if r.Integer {
+		value = math.Round(value)
+	}
+	return value, nil
+}
+
+// StrategyFactory builds a fresh strategy for one set of parameters; trials
+// run concurrently, so strategies must not share state.
+type StrategyFactory func(params map[string]float64) (Strategy, error)
+
+type OptimizeConfig struct {
+	Config Config
+	// Fixed parameters are passed to every trial alongside the ranged ones.
+	Fixed     map[string]float64
+	Ranges    []ParamRange
+	Search    Search
+	Samples   int
+	Seed      int64
+	Objective Objective
+	// MaxDrawdown rejects trials drawing down deeper than this fraction; zero
+	// disables the constraint.
+	MaxDrawdown float64
+	// Workers defaults to the number of CPUs.
+	Workers int
+	// Progress, when set, is called after every finished trial.
+	Progress func(done int, total int)
+}
+
+type Trial struct {
+	Rank        int                `json:"rank"`
+	Params      map[string]float64 `json:"params"`
+	Score       float64            `json:"score"`
+	Feasible    bool               `json:"feasible"`
+	FinalEquity float64            `json:"finalEquity"`
+	Metrics     Metrics            `json:"metrics"`
+	Error       string             `json:"error,omitempty"`
+}
+
+type OptimizeResult struct {
+	Objective Objective `json:"objective"`
+	Search    Search    `json:"search"`
+	Evaluated int       `json:"evaluated"`
+	Feasible  int       `json:"feasible"`
+	Trials    []Trial   `json:"trials"`
+}
+
+// Best returns the top ranked feasible trial.
+func (r OptimizeResult) Best() (Trial, bool) {
+	if len(r.Trials) == 0 || !r.Trials[0].Feasible {
+		return Trial{}, false
+	}
+	return r.Trials[0], true
+}
+
+// Optimize backtests every parameter set of the search in parallel and
+// ranks them by the objective, feasible trials first. It stops early with
+// ctx's error when ctx is cancelled.
+func Optimize(ctx context.Context, bars []Bar, factory StrategyFactory, config OptimizeConfig) (OptimizeResult, error) {
+	if len(config.Ranges) == 0 {
+		return OptimizeResult{}, ErrNoRanges
+	}
+
+	candidates, err := candidates(config)
+	if err != nil {
+		return OptimizeResult{}, err
+	}
+
+	workers := config.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	trials := make([]Trial, len(candidates))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				trials[i] = runTrial(ctx, bars, factory, candidates[i], config)
+
+				if config.Progress != nil {
+					mu.Lock()
+					done++
+					config.Progress(done, len(candidates))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	cancelled := false
+	for i := range candidates {
+		if cancelled {
+			break
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			cancelled = true
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return OptimizeResult{}, err
+	}
+
+	sort.SliceStable(trials, func(i, j int) bool {
+		if trials[i].Feasible != trials[j].Feasible {
+			return trials[i].Feasible
+		}
+		return trials[i].Score > trials[j].Score
+	})
+
+	result := OptimizeResult{
+		Objective: config.Objective,
+		Search:    config.Search,
+		Evaluated: len(trials),
+		Trials:    trials,
+	}
+	for i := range trials {
+		trials[i].Rank = i + 1
+		if trials[i].Feasible {
+			result.Feasible++
+		}
+	}
+
+	return result, nil
+}
+
+// runTrial runs on the optimiser's own goroutines, out of reach of any
+// recovery middleware, so a strategy that panics fails its trial instead of
+// taking the process down.
+func runTrial(ctx context.Context, bars []Bar, factory StrategyFactory, params map[string]float64, config OptimizeConfig) (trial Trial) {
+	trial = Trial{Params: params}
+	defer func() {
+		if r := recover(); r != nil {
+			trial = Trial{Params: params, Error: fmt.Sprint(r)}
+		}
+	}()
+
+	strategy, err := factory(params)
+	if err != nil {
+		trial.Error = err.Error()
+		return trial
+	}
+
+	// trials report through config.Progress, not bar by bar
+	run := config.Config
+	run.Progress = nil
+
+	result, err := Run(ctx, bars, strategy, run)
+	if err != nil {
+		trial.Error = err.Error()
+		return trial
+	}
+
+	trial.Metrics = result.Metrics
+	trial.FinalEquity = result.FinalEquity
+	trial.Score = config.Objective.Score(result.Metrics)
+	trial.Feasible = config.MaxDrawdown <= 0 || result.Metrics.MaxDrawdown <= config.MaxDrawdown
+	return trial
+}
+
+// candidates expands the ranges into the parameter sets to try: the full
+// cartesian product for a grid search, or Samples distinct random draws.
+func candidates(config OptimizeConfig) ([]map[string]float64, error) {
+	withFixed := func(values map[string]float64) map[string]float64 {
+		params := make(map[string]float64, len(config.Fixed)+len(values))
+		for name, value := range config.Fixed {
+			params[name] = value
+		}
+		for name, value := range values {
+			params[name] = value
+		}
+		return params
+	}
+
+	if config.Search == RandomSearch {
+		if config.Samples <= 0 || config.Samples > MaxTrials {
+			return nil, fmt.Errorf("%w: samples must be between 1 and %d", ErrInvalidRange, MaxTrials)
+		}
+
+		random := rand.New(rand.NewSource(config.Seed))
+		seen := make(map[string]bool)
+		var sets []map[string]float64
+
+		// a small grid may hold fewer distinct sets than samples
+		for attempts := 0; len(sets) < config.Samples && attempts < config.Samples*10; attempts++ {
+			values := make(map[string]float64, len(config.Ranges))
+			for _, r := range config.Ranges {
+				value, err := r.sample(random)
+				if err != nil {
+					return nil, err
+				}
+				values[r.Name] = value
+			}
+
+			if key := paramKey(values); !seen[key] {
+				seen[key] = true
+				sets = append(sets, withFixed(values))
+			}
+		}
+		return sets, nil
+	}
+
+	sets := []map[string]float64{{}}
+	for _, r := range config.Ranges {
+		values, err := r.Grid()
+		if err != nil {
+			return nil, err
+		}
+		if len(sets)*len(values) > MaxTrials {
+			return nil, ErrTooManyTrials
+		}
+
+		var next []map[string]float64
+		for _, set := range sets {
+			for _, value := range values {
+				params := make(map[string]float64, len(set)+1)
+				for name, v := range set {
+					params[name] = v
+				}
+				params[r.Name] = value
+				next = append(next, params)
+			}
+		}
+		sets = next
+	}
+
+	for i, set := range sets {
+		sets[i] = withFixed(set)
+	}
+	return sets, nil
+}
+
+func paramKey(params map[string]float64) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	for _, name := range names {
+		key.WriteString(name + "=" + strconv.FormatFloat(params[name], 'g', -1, 64) + ";")
+	}
+	return key.String()
+}
+
+// Heatmap is the best feasible score for every pair of values of two
+// parameters, the other parameters free. Scores[y][x] is nil where no
+// feasible trial had that pair.
+type Heatmap struct {
+	X       string       `json:"x"`
+	Y       string       `json:"y"`
+	XValues []float64    `json:"xValues"`
+	YValues []float64    `json:"yValues"`
+	Scores  [][]*float64 `json:"scores"`
+}
+
+func NewHeatmap(trials []Trial, x string, y string) Heatmap {
+	heatmap := Heatmap{X: x, Y: y}
+
+	xIndex := make(map[float64]int)
+	yIndex := make(map[float64]int)
+	for _, trial := range trials {
+		if _, ok := xIndex[trial.Params[x]]; !ok {
+			xIndex[trial.Params[x]] = 0
+			heatmap.XValues = append(heatmap.XValues, trial.Params[x])
+		}
+		if _, ok := yIndex[trial.Params[y]]; !ok {
+			yIndex[trial.Params[y]] = 0
+			heatmap.YValues = append(heatmap.YValues, trial.Params[y])
+		}
+	}
+
+	sort.Float64s(heatmap.XValues)
+	sort.Float64s(heatmap.YValues)
+	for i, value := range heatmap.XValues {
+		xIndex[value] = i
+	}
+	for i, value := range heatmap.YValues {
+		yIndex[value] = i
+	}
+
+	heatmap.Scores = make([][]*float64, len(heatmap.YValues))
+	for i := range heatmap.Scores {
+		heatmap.Scores[i] = make([]*float64, len(heatmap.XValues))
+	}
+
+	for _, trial := range trials {
+		if !trial.Feasible {
+			continue
+		}
+
+		cell := &heatmap.Scores[yIndex[trial.Params[y]]][xIndex[trial.Params[x]]]
+		if *cell == nil || trial.Score > **cell {
+			score := trial.Score
+			*cell = &score
+		}
+	}
+
+	return heatmap
+}
@@ -0,0 +1,82 @@
+package backtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// failingFactory builds strategies that index past the bars they were given
+// once the bar index reaches the "from" parameter, like a strategy whose
+// period is longer than the series.
+func failingFactory(params map[string]float64) (Strategy, error) {
+	from := int(params["from"])
+	return everyBar(func(ctx *Context) {
+		if ctx.Index >= from {
+			_ = ctx.Bars[ctx.Index+1]
+		}
+	}), nil
+}
+
+// everyBar is a strategy that runs step on every bar.
+type everyBar func(ctx *Context)
+
+func (s everyBar) Init(bars []Bar) error {
+	return nil
+}
+
+func (s everyBar) OnBar(ctx *Context) {
+	s(ctx)
+}
+
+func TestOptimizeRecoversFromPanics(t *testing.T) {
+	bars := testBars(rising(20)...)
+	config := OptimizeConfig{
+		Config:    Config{Symbol: "TEST", InitialCash: 10000},
+		Ranges:    []ParamRange{{Name: "from", Values: []float64{5, 100}}},
+		Objective: ObjectiveTotalReturn,
+	}
+
+	result, err := Optimize(context.Background(), bars, failingFactory, config)
+	if err != nil {
+		t.Fatalf("Optimize: %v", err)
+	}
+	if result.Evaluated != 2 || result.Feasible != 1 {
+		t.Fatalf("evaluated %d with %d feasible, want 2 with 1", result.Evaluated, result.Feasible)
+	}
+
+	failed := result.Trials[1]
+	if failed.Params["from"] != 5 || !strings.Contains(failed.Error, "index out of range") {
+		t.Errorf("failed trial = %+v, want the panic of from=5", failed)
+	}
+}
+
+func TestWalkForwardRecoversFromPanics(t *testing.T) {
+	bars := testBars(rising(20)...)
+	config := WalkForwardConfig{
+		Optimize: OptimizeConfig{
+			Config:    Config{Symbol: "TEST", InitialCash: 10000},
+			Ranges:    []ParamRange{{Name: "from", Values: []float64{10}}},
+			Objective: ObjectiveTotalReturn,
+		},
+		InSample:    10,
+		OutOfSample: 5,
+	}
+
+	// the in-sample runs never reach bar 10, the out-of-sample ones do
+	result, err := WalkForward(context.Background(), bars, failingFactory, config)
+	if err != nil {
+		t.Fatalf("WalkForward: %v", err)
+	}
+	if len(result.Windows) != 2 {
+		t.Fatalf("windows = %d, want 2", len(result.Windows))
+	}
+	for i, window := range result.Windows {
+		if !strings.Contains(window.Error, "index out of range") {
+			t.Errorf("window %d error = %q, want the panic", i, window.Error)
+		}
+	}
+	if result.FinalEquity != 10000 || len(result.Equity) != 10 {
+		t.Errorf("final equity = %g over %d points, want 10000 in cash over 10", result.FinalEquity, len(result.Equity))
+	}
+}
@@ -0,0 +1,33 @@
+package backtest
+
+import "time"
+
+type Side string
+
+const (
+	Buy  Side = "BUY"
+	Sell Side = "SELL"
+)
+
+// Order asks the broker to trade either Quantity shares or, when Quantity is
+// zero, as many shares as Value buys at the fill price.
+type Order struct {
+	Symbol   string  `json:"symbol"`
+	Side     Side    `json:"side"`
+	Quantity float64 `json:"quantity"`
+	Value    float64 `json:"value"`
+	Reason   string  `json:"reason"`
+}
+
+type Fill struct {
+	Date     time.Time `json:"date"`
+	Symbol   string    `json:"symbol"`
+	Side     Side      `json:"side"`
+	Quantity float64   `json:"quantity"`
+	Price    float64   `json:"price"`
+	Value    float64   `json:"value"`
+	Fee      float64   `json:"fee"`
+	Tax      float64   `json:"tax"`
+	Slippage float64   `json:"slippage"`
+	Reason   string    `json:"reason"`
+}
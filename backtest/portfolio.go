@@ -0,0 +1,305 @@
+package backtest
+
+import (
+	"math"
+	"time"
+)
+
+// epsilon absorbs the rounding left over when a position is sold in pieces
+const epsilon = 1e-9
+
+// Position is long when Quantity is positive and short when it is negative.
+// AvgPrice includes the commission paid to open it; for a short it is the
+// proceeds per share after commission and tax.
+type Position struct {
+	Symbol   string  `json:"symbol"`
+	Quantity float64 `json:"quantity"`
+	AvgPrice float64 `json:"avgPrice"`
+}
+
+const (
+	Long  = "long"
+	Short = "short"
+)
+
+// Trade is a round trip: it opens when a position is entered from flat and
+// closes when the position is back to flat. Entry and exit prices are the
+// average fill prices; PnL and Return are net of fees, taxes and borrow fees.
+// Open trades are marked to the latest close.
+type Trade struct {
+	Symbol     string    `json:"symbol"`
+	Direction  string    `json:"direction"`
+	EntryDate  time.Time `json:"entryDate"`
+	ExitDate   time.Time `json:"exitDate"`
+	EntryPrice float64   `json:"entryPrice"`
+	ExitPrice  float64   `json:"exitPrice"`
+	Quantity   float64   `json:"quantity"`
+	Fees       float64   `json:"fees"`
+	PnL        float64   `json:"pnl"`
+	Return     float64   `json:"return"`
+	Reason     string    `json:"reason"`
+	Open       bool      `json:"open"`
+
+	cost       float64
+	openValue  float64
+	closeValue float64
+	closed     float64
+}
+
+// Portfolio is the ledger of cash, positions, fills and trades. Cash goes
+// negative when longs are bought on margin and includes the proceeds of
+// short sales.
+type Portfolio struct {
+	Cash        float64
+	Positions   map[string]*Position
+	RealizedPnL float64
+	Fees        float64
+	BorrowFees  float64
+	Interest    float64
+	Dividends   float64
+	Fills       []Fill
+	Trades      []Trade
+
+	open  map[string]*Trade
+	marks map[string]float64
+}
+
+func NewPortfolio(cash float64) *Portfolio {
+	return &Portfolio{
+		Cash:      cash,
+		Positions: make(map[string]*Position),
+		open:      make(map[string]*Trade),
+		marks:     make(map[string]float64),
+	}
+}
+
+func (p *Portfolio) Position(symbol string) Position {
+	if position, ok := p.Positions[symbol]; ok {
+		return *position
+	}
+	return Position{Symbol: symbol}
+}
+
+// Apply books a fill against cash, the position and its round-trip trade. A
+// fill that takes a position through zero, such as selling more than is
+// held, closes the trade and opens one in the other direction.
+func (p *Portfolio) Apply(fill Fill) {
+	p.Fills = append(p.Fills, fill)
+	p.marks[fill.Symbol] = fill.Price
+
+	held := p.Position(fill.Symbol).Quantity
+	closing := 0.0
+	switch {
+	case fill.Side == Sell && held > epsilon:
+		closing = held
+	case fill.Side == Buy && held < -epsilon:
+		closing = -held
+	}
+
+	if closing <= epsilon || fill.Quantity <= closing+epsilon {
+		p.book(fill)
+		return
+	}
+
+	ratio := closing / fill.Quantity
+	first, second := fill, fill
+	first.Quantity, second.Quantity = closing, fill.Quantity-closing
+	first.Value, second.Value = fill.Value*ratio, fill.Value*(1-ratio)
+	first.Fee, second.Fee = fill.Fee*ratio, fill.Fee*(1-ratio)
+	first.Tax, second.Tax = fill.Tax*ratio, fill.Tax*(1-ratio)
+	first.Slippage, second.Slippage = fill.Slippage*ratio, fill.Slippage*(1-ratio)
+	p.book(first)
+	p.book(second)
+}
+
+func (p *Portfolio) book(fill Fill) {
+	position, ok := p.Positions[fill.Symbol]
+	if !ok {
+		position = &Position{Symbol: fill.Symbol}
+		p.Positions[fill.Symbol] = position
+	}
+
+	trade, ok := p.open[fill.Symbol]
+	if !ok {
+		direction := Long
+		if fill.Side == Sell {
+			direction = Short
+		}
+		trade = &Trade{Symbol: fill.Symbol, Direction: direction, EntryDate: fill.Date}
+		p.open[fill.Symbol] = trade
+	}
+
+	charges := fill.Fee + fill.Tax
+	p.Fees += charges
+	trade.Fees += charges
+
+	quantity := fill.Quantity
+	if fill.Side == Buy {
+		p.Cash -= fill.Value + charges
+	} else {
+		p.Cash += fill.Value - charges
+		quantity = -fill.Quantity
+	}
+
+	long := trade.Direction == Long
+	opening := (fill.Side == Buy) == long
+
+	if opening {
+		net := fill.Value + charges
+		if !long {
+			net = fill.Value - charges
+		}
+		size := math.Abs(position.Quantity)
+		position.AvgPrice = (size*position.AvgPrice + net) / (size + fill.Quantity)
+		position.Quantity += quantity
+
+		trade.Quantity += fill.Quantity
+		trade.cost += fill.Value + charges
+		trade.openValue += fill.Value
+		trade.EntryPrice = trade.openValue / trade.Quantity
+	} else {
+		realized := fill.Value - charges - position.AvgPrice*fill.Quantity
+		if !long {
+			realized = position.AvgPrice*fill.Quantity - fill.Value - charges
+		}
+		p.RealizedPnL += realized
+		position.Quantity += quantity
+
+		trade.closed += fill.Quantity
+		trade.closeValue += fill.Value
+		trade.PnL += realized
+		trade.ExitPrice = trade.closeValue / trade.closed
+		trade.ExitDate = fill.Date
+		trade.Reason = fill.Reason
+	}
+
+	if math.Abs(position.Quantity) < epsilon {
+		if trade.cost > 0 {
+			trade.Return = trade.PnL / trade.cost
+		}
+		p.Trades = append(p.Trades, *trade)
+		delete(p.open, fill.Symbol)
+		delete(p.Positions, fill.Symbol)
+	}
+}
+
+// Mark records the latest prices positions are valued at by MarkedEquity,
+// Exposure and Finance.
+func (p *Portfolio) Mark(prices map[string]float64) {
+	for symbol, price := range prices {
+		if price > 0 {
+			p.marks[symbol] = price
+		}
+	}
+}
+
+// Equity is cash plus every position valued at prices.
+func (p *Portfolio) Equity(prices map[string]float64) float64 {
+	equity := p.Cash
+	for symbol, position := range p.Positions {
+		equity += position.Quantity * prices[symbol]
+	}
+	return equity
+}
+
+// MarkedEquity is Equity at the prices last passed to Mark or filled at.
+func (p *Portfolio) MarkedEquity() float64 {
+	return p.Equity(p.marks)
+}
+
+// Exposure is the marked value of the long positions and of the short
+// positions, both positive.
+func (p *Portfolio) Exposure() (float64, float64) {
+	var long, short float64
+	for symbol, position := range p.Positions {
+		value := position.Quantity * p.marks[symbol]
+		if value > 0 {
+			long += value
+		} else {
+			short -= value
+		}
+	}
+	return long, short
+}
+
+// Finance charges one trading day of borrow fees on the short positions,
+// which count against their trades, and of interest on negative cash.
+func (p *Portfolio) Finance(margin Margin) {
+	for symbol, position := range p.Positions {
+		if position.Quantity >= 0 || margin.BorrowRate <= 0 {
+			continue
+		}
+
+		fee := -position.Quantity * p.marks[symbol] * margin.BorrowRate / TradingDaysPerYear
+		p.Cash -= fee
+		p.BorrowFees += fee
+		if trade, ok := p.open[symbol]; ok {
+			trade.Fees += fee
+			trade.PnL -= fee
+		}
+	}
+
+	if p.Cash < 0 && margin.MarginRate > 0 {
+		interest := -p.Cash * margin.MarginRate / TradingDaysPerYear
+		p.Cash -= interest
+		p.Interest += interest
+	}
+}
+
+// Split multiplies the position in symbol by ratio and divides its prices,
+// so its value does not change.
+func (p *Portfolio) Split(symbol string, ratio float64) {
+	if price, ok := p.marks[symbol]; ok {
+		p.marks[symbol] = price / ratio
+	}
+
+	position, ok := p.Positions[symbol]
+	if !ok {
+		return
+	}
+	position.Quantity *= ratio
+	position.AvgPrice /= ratio
+
+	if trade, ok := p.open[symbol]; ok {
+		trade.Quantity *= ratio
+		trade.closed *= ratio
+		trade.EntryPrice /= ratio
+		trade.ExitPrice /= ratio
+	}
+}
+
+// Distribute pays a dividend of amount per share on the position in symbol.
+// A short position pays it to the lender instead.
+func (p *Portfolio) Distribute(symbol string, amount float64) {
+	position, ok := p.Positions[symbol]
+	if !ok {
+		return
+	}
+
+	paid := position.Quantity * amount
+	p.Cash += paid
+	p.Dividends += paid
+	if trade, ok := p.open[symbol]; ok {
+		trade.PnL += paid
+	}
+}
+
+// OpenTrades returns the trades that are still open, marked to prices.
+func (p *Portfolio) OpenTrades(date time.Time, prices map[string]float64) []Trade {
+	var trades []Trade
+	for symbol, open := range p.open {
+		trade := *open
+		position := p.Positions[symbol]
+		price := prices[symbol]
+
+		trade.Open = true
+		trade.ExitDate = date
+		trade.PnL += (price - position.AvgPrice) * position.Quantity
+		trade.ExitPrice = (trade.closeValue + price*math.Abs(position.Quantity)) / trade.Quantity
+		if trade.cost > 0 {
+			trade.Return = trade.PnL / trade.cost
+		}
+		trades = append(trades, trade)
+	}
+	return trades
+}
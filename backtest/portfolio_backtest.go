@@ -0,0 +1,487 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+var (
+	ErrNoAssets    = errors.New("portfolio needs at least one asset")
+	ErrInvalidTopN = errors.New("topN weighting needs a positive topN")
+)
+
+type Weighting string
+
+const (
+	EqualWeight       Weighting = "equalWeight"
+	InverseVolatility Weighting = "inverseVolatility"
+	TopN              Weighting = "topN"
+)
+
+func ParseWeighting(value string) (Weighting, error) {
+	switch Weighting(value) {
+	case "", EqualWeight:
+		return EqualWeight, nil
+	case InverseVolatility:
+		return InverseVolatility, nil
+	case TopN:
+		return TopN, nil
+	}
+	return "", fmt.Errorf("unknown weighting %q", value)
+}
+
+type Rebalance string
+
+const (
+	Daily     Rebalance = "daily"
+	Weekly    Rebalance = "weekly"
+	Monthly   Rebalance = "monthly"
+	Quarterly Rebalance = "quarterly"
+)
+
+func ParseRebalance(value string) (Rebalance, error) {
+	switch Rebalance(value) {
+	case "", Monthly:
+		return Monthly, nil
+	case Daily, Weekly, Quarterly:
+		return Rebalance(value), nil
+	}
+	return "", fmt.Errorf("unknown rebalance frequency %q", value)
+}
+
+// period numbers the rebalance period date falls in; the portfolio is
+// rebalanced on the first bar of every new period.
+func (r Rebalance) period(date time.Time) int {
+	switch r {
+	case Daily:
+		return date.Year()*1000 + date.YearDay()
+	case Weekly:
+		year, week := date.ISOWeek()
+		return year*100 + week
+	case Quarterly:
+		return date.Year()*10 + (int(date.Month())-1)/3
+	}
+	return date.Year()*100 + int(date.Month())
+}
+
+// Asset is one symbol of a portfolio backtest. Score and Eligible, when set,
+// run parallel to Bars: Score ranks assets (NaN while undefined) and Eligible
+// says whether the asset may be held on that bar. Actions are the splits and
+// dividends still to be applied to Bars, see Config.Actions.
+type Asset struct {
+	Symbol   string
+	Bars     []Bar
+	Score    []float64
+	Eligible []bool
+	Actions  CorporateActions
+}
+
+type PortfolioConfig struct {
+	InitialCash  float64
+	Fill         FillMode
+	Costs        Costs
+	RiskFreeRate float64
+	Weighting    Weighting
+	Rebalance    Rebalance
+	// TopN is how many of the best scored assets topN weighting holds.
+	TopN int
+	// MaxPositions caps how many assets are held at once, keeping the best
+	// scored; zero means no cap.
+	MaxPositions int
+	// MaxWeight caps any one asset's share of equity; what the cap cuts off
+	// stays in cash. Zero means no cap.
+	MaxWeight float64
+	// VolatilityLookback is the number of daily returns inverse volatility
+	// weighting measures over.
+	VolatilityLookback int
+	// Margin works as in Config: it lets the rebalance spend buying power
+	// instead of cash, charges interest and borrow fees at every close and
+	// liquidates the portfolio on a margin call.
+	Margin Margin
+	// Progress, when set, is called after every date.
+	Progress func(done int, total int)
+}
+
+// Attribution is one asset's part of the portfolio result. PnL includes the
+// value still held, Contribution is PnL over the initial cash (the asset
+// contributions add up to the portfolio's total return), and Return is PnL
+// over everything spent buying the asset. Dividends count towards PnL.
+type Attribution struct {
+	Symbol        string  `json:"symbol"`
+	PnL           float64 `json:"pnl"`
+	Contribution  float64 `json:"contribution"`
+	Return        float64 `json:"return"`
+	Fees          float64 `json:"fees"`
+	AverageWeight float64 `json:"averageWeight"`
+	FinalWeight   float64 `json:"finalWeight"`
+	Quantity      float64 `json:"quantity"`
+	MarketValue   float64 `json:"marketValue"`
+	Trades        int     `json:"trades"`
+}
+
+type PortfolioResult struct {
+	Symbols     []string      `json:"symbols"`
+	InitialCash float64       `json:"initialCash"`
+	FinalEquity float64       `json:"finalEquity"`
+	Cash        float64       `json:"cash"`
+	GainLoss    float64       `json:"gainLoss"`
+	Fees        float64       `json:"fees"`
+	BorrowFees  float64       `json:"borrowFees"`
+	Interest    float64       `json:"interest"`
+	Dividends   float64       `json:"dividends"`
+	MarginCalls int           `json:"marginCalls"`
+	Rebalances  int           `json:"rebalances"`
+	Metrics     Metrics       `json:"metrics"`
+	Attribution []Attribution `json:"attribution"`
+	Fills       []Fill        `json:"fills"`
+	Trades      []Trade       `json:"trades"`
+	Equity      []EquityPoint `json:"equity"`
+}
+
+// RunPortfolio backtests a portfolio of assets sharing one cash account. The
+// bars of all assets are merged by date, and on the first date of every
+// rebalance period target weights are computed from the closes and orders
+// are submitted to bring each holding to its target; they fill on each
+// asset's next bar, sells before buys. Holdings are marked to the latest
+// close an asset has, so assets trading on different days can be mixed. An
+// asset's splits and dividends take effect at the open of its first bar on
+// or after their date, and the margin is checked at every close as in Run.
+// RunPortfolio stops with ctx's error when ctx is cancelled.
+func RunPortfolio(ctx context.Context, assets []Asset, config PortfolioConfig) (PortfolioResult, error) {
+	if len(assets) == 0 {
+		return PortfolioResult{}, ErrNoAssets
+	}
+	if config.InitialCash <= 0 {
+		return PortfolioResult{}, ErrInvalidCapital
+	}
+	if config.Weighting == TopN && config.TopN <= 0 {
+		return PortfolioResult{}, ErrInvalidTopN
+	}
+	if err := config.Costs.Validate(); err != nil {
+		return PortfolioResult{}, err
+	}
+	if err := config.Margin.Validate(); err != nil {
+		return PortfolioResult{}, err
+	}
+	if config.VolatilityLookback <= 0 {
+		config.VolatilityLookback = 20
+	}
+
+	// index[symbol][date] is the position of that date in the asset's bars
+	index := make(map[string]map[time.Time]int, len(assets))
+	dateSet := make(map[time.Time]bool)
+	for _, asset := range assets {
+		if len(asset.Bars) == 0 {
+			return PortfolioResult{}, fmt.Errorf("%w for %s", ErrNoBars, asset.Symbol)
+		}
+		if err := asset.Actions.Validate(); err != nil {
+			return PortfolioResult{}, fmt.Errorf("%w for %s", err, asset.Symbol)
+		}
+
+		index[asset.Symbol] = make(map[time.Time]int, len(asset.Bars))
+		for i, bar := range asset.Bars {
+			index[asset.Symbol][bar.Date] = i
+			dateSet[bar.Date] = true
+		}
+	}
+
+	dates := make([]time.Time, 0, len(dateSet))
+	for date := range dateSet {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+
+	portfolio := NewPortfolio(config.InitialCash)
+	broker := NewBroker(config.Fill, config.Costs)
+	broker.Margin = config.Margin
+	prices := make(map[string]float64, len(assets))
+	latest := make(map[string]int, len(assets))
+	weightSums := make(map[string]float64, len(assets))
+	dividendSums := make(map[string]float64, len(assets))
+	called := false
+
+	actions := make(map[string]CorporateActions, len(assets))
+	for _, asset := range assets {
+		actions[asset.Symbol] = asset.Actions.Merge(CorporateActions{})
+	}
+
+	result := PortfolioResult{InitialCash: config.InitialCash}
+	for _, asset := range assets {
+		result.Symbols = append(result.Symbols, asset.Symbol)
+	}
+
+	lastPeriod := -1
+	for n, date := range dates {
+		select {
+		case <-ctx.Done():
+			return PortfolioResult{}, ctx.Err()
+		default:
+		}
+
+		today := make(map[string]Bar, len(assets))
+		for _, asset := range assets {
+			i, ok := index[asset.Symbol][date]
+			if !ok {
+				continue
+			}
+			today[asset.Symbol] = asset.Bars[i]
+			latest[asset.Symbol] = i
+
+			// actions up to the first bar are already in its prices
+			pending := actions[asset.Symbol]
+			for ; len(pending.Splits) > 0 && !pending.Splits[0].Date.After(date); pending.Splits = pending.Splits[1:] {
+				if i > 0 {
+					portfolio.Split(asset.Symbol, pending.Splits[0].Ratio)
+					broker.Split(asset.Symbol, pending.Splits[0].Ratio)
+				}
+			}
+			for ; len(pending.Dividends) > 0 && !pending.Dividends[0].Date.After(date); pending.Dividends = pending.Dividends[1:] {
+				if i > 0 {
+					dividendSums[asset.Symbol] += portfolio.Position(asset.Symbol).Quantity * pending.Dividends[0].Amount
+					portfolio.Distribute(asset.Symbol, pending.Dividends[0].Amount)
+				}
+			}
+			actions[asset.Symbol] = pending
+		}
+
+		broker.ExecuteBars(today, portfolio)
+
+		for symbol, bar := range today {
+			prices[symbol] = bar.Close
+		}
+
+		if period := config.Rebalance.period(date); period != lastPeriod {
+			lastPeriod = period
+			targets := targetWeights(assets, latest, prices, config)
+			rebalance(broker, portfolio, targets, prices)
+			result.Rebalances++
+		}
+
+		portfolio.Mark(prices)
+		portfolio.Finance(config.Margin)
+
+		if config.Margin.MarginCall(portfolio) {
+			if !called {
+				result.MarginCalls++
+			}
+			called = true
+
+			broker.Cancel()
+			for symbol, position := range portfolio.Positions {
+				side := Sell
+				if position.Quantity < 0 {
+					side = Buy
+				}
+				broker.Submit(Order{Symbol: symbol, Side: side, Quantity: math.Abs(position.Quantity), Reason: "margin call"})
+			}
+		} else {
+			called = false
+		}
+
+		total := portfolio.Equity(prices)
+		for symbol, position := range portfolio.Positions {
+			if total > 0 {
+				weightSums[symbol] += position.Quantity * prices[symbol] / total
+			}
+		}
+
+		result.Equity = append(result.Equity, EquityPoint{
+			Date:     date,
+			Cash:     portfolio.Cash,
+			Holdings: total - portfolio.Cash,
+			Equity:   total,
+		})
+
+		if config.Progress != nil {
+			config.Progress(n+1, len(dates))
+		}
+	}
+
+	last := dates[len(dates)-1]
+	result.FinalEquity = portfolio.Equity(prices)
+	result.Cash = portfolio.Cash
+	result.GainLoss = result.FinalEquity - result.InitialCash
+	result.Fees = portfolio.Fees
+	result.BorrowFees = portfolio.BorrowFees
+	result.Interest = portfolio.Interest
+	result.Dividends = portfolio.Dividends
+	result.Fills = portfolio.Fills
+	result.Trades = append(portfolio.Trades, portfolio.OpenTrades(last, prices)...)
+	result.Metrics = ComputeMetrics(result.Equity, result.Trades, config.RiskFreeRate)
+	result.Attribution = attribution(assets, portfolio, prices, weightSums, dividendSums, len(dates), result)
+
+	return result, nil
+}
+
+// targetWeights picks the assets to hold and their share of equity.
+func targetWeights(assets []Asset, latest map[string]int, prices map[string]float64, config PortfolioConfig) map[string]float64 {
+	type candidate struct {
+		symbol string
+		score  float64
+		weight float64
+	}
+
+	var candidates []candidate
+	for _, asset := range assets {
+		i, ok := latest[asset.Symbol]
+		if !ok || prices[asset.Symbol] <= 0 {
+			continue
+		}
+		if asset.Eligible != nil && !asset.Eligible[i] {
+			continue
+		}
+
+		score := math.NaN()
+		if asset.Score != nil {
+			score = asset.Score[i]
+		}
+		if config.Weighting == TopN && math.IsNaN(score) {
+			continue
+		}
+
+		weight := 1.0
+		if config.Weighting == InverseVolatility {
+			volatility := trailingVolatility(asset.Bars[:i+1], config.VolatilityLookback)
+			if volatility <= 0 {
+				continue
+			}
+			weight = 1 / volatility
+		}
+
+		candidates = append(candidates, candidate{symbol: asset.Symbol, score: score, weight: weight})
+	}
+
+	// best scored first, unscored assets keep their order behind them
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].score, candidates[j].score
+		if math.IsNaN(b) {
+			return !math.IsNaN(a)
+		}
+		return a > b
+	})
+
+	limit := config.MaxPositions
+	if config.Weighting == TopN && (limit == 0 || config.TopN < limit) {
+		limit = config.TopN
+	}
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	var total float64
+	for _, c := range candidates {
+		total += c.weight
+	}
+
+	targets := make(map[string]float64, len(candidates))
+	for _, c := range candidates {
+		weight := c.weight / total
+		if config.MaxWeight > 0 && weight > config.MaxWeight {
+			weight = config.MaxWeight
+		}
+		targets[c.symbol] = weight
+	}
+	return targets
+}
+
+// rebalance submits the orders that move every holding to its target weight
+// of the current equity. Assets without a target are sold.
+func rebalance(broker *Broker, portfolio *Portfolio, targets map[string]float64, prices map[string]float64) {
+	equity := portfolio.Equity(prices)
+
+	symbols := make([]string, 0, len(targets)+len(portfolio.Positions))
+	for symbol := range targets {
+		symbols = append(symbols, symbol)
+	}
+	for symbol := range portfolio.Positions {
+		if _, ok := targets[symbol]; !ok {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+
+	for _, symbol := range symbols {
+		price := prices[symbol]
+		if price <= 0 {
+			continue
+		}
+
+		held := portfolio.Position(symbol).Quantity
+		target := targets[symbol] * equity / price
+		switch {
+		case target == 0 && held > 0:
+			broker.Submit(Order{Symbol: symbol, Side: Sell, Quantity: held, Reason: "rebalance: not selected"})
+		case target > held:
+			broker.Submit(Order{Symbol: symbol, Side: Buy, Quantity: target - held, Reason: "rebalance"})
+		case target < held:
+			broker.Submit(Order{Symbol: symbol, Side: Sell, Quantity: held - target, Reason: "rebalance"})
+		}
+	}
+}
+
+// trailingVolatility is the standard deviation of the last lookback daily
+// returns of bars, zero when there are not enough bars.
+func trailingVolatility(bars []Bar, lookback int) float64 {
+	if len(bars) <= lookback {
+		return 0
+	}
+
+	returns := make([]float64, 0, lookback)
+	for i := len(bars) - lookback; i < len(bars); i++ {
+		if previous := bars[i-1].Close; previous > 0 {
+			returns = append(returns, bars[i].Close/previous-1)
+		}
+	}
+
+	_, std := meanStd(returns)
+	return std
+}
+
+func attribution(assets []Asset, portfolio *Portfolio, prices map[string]float64, weightSums map[string]float64, dividendSums map[string]float64, days int, result PortfolioResult) []Attribution {
+	attributions := make([]Attribution, 0, len(assets))
+	for _, asset := range assets {
+		attr := Attribution{Symbol: asset.Symbol}
+
+		var spent, received float64
+		for _, fill := range portfolio.Fills {
+			if fill.Symbol != asset.Symbol {
+				continue
+			}
+			charges := fill.Fee + fill.Tax
+			attr.Fees += charges
+			if fill.Side == Buy {
+				spent += fill.Value + charges
+			} else {
+				received += fill.Value - charges
+			}
+		}
+
+		attr.Quantity = portfolio.Position(asset.Symbol).Quantity
+		attr.MarketValue = attr.Quantity * prices[asset.Symbol]
+		attr.PnL = received + attr.MarketValue + dividendSums[asset.Symbol] - spent
+		attr.Contribution = attr.PnL / result.InitialCash
+		if spent > 0 {
+			attr.Return = attr.PnL / spent
+		}
+		if days > 0 {
+			attr.AverageWeight = weightSums[asset.Symbol] / float64(days)
+		}
+		if result.FinalEquity > 0 {
+			attr.FinalWeight = attr.MarketValue / result.FinalEquity
+		}
+		for _, trade := range result.Trades {
+			if trade.Symbol == asset.Symbol {
+				attr.Trades++
+			}
+		}
+
+		attributions = append(attributions, attr)
+	}
+	return attributions
+}
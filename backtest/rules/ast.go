@@ -0,0 +1,383 @@
+package rules
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Node is any parsed expression; String renders it back in rule syntax.
+type Node interface {
+	String() string
+}
+
+// Value is a numeric expression. It is evaluated once over the whole bar
+// series, and only the values from Start on are defined.
+type Value interface {
+	Node
+	series(data *dataset) Series
+}
+
+// Condition is a boolean expression evaluated on every bar.
+type Condition interface {
+	Node
+	compile(data *dataset) predicate
+}
+
+type Series struct {
+	Values []float64
+	Start  int
+}
+
+func (s Series) at(i int) (float64, bool) {
+	if i < s.Start || i >= len(s.Values) || math.IsNaN(s.Values[i]) {
+		return 0, false
+	}
+	return s.Values[i], true
+}
+
+// State is the position a condition is evaluated against. Stops are only
+// ever true while Holding, and are mirrored when the position is Short.
+type State struct {
+	Holding      bool
+	Short        bool
+	EntryPrice   float64
+	HighestClose float64
+	LowestClose  float64
+}
+
+type predicate func(i int, state State) bool
+
+type Number struct {
+	Value float64
+}
+
+func (n *Number) String() string {
+	return strconv.FormatFloat(n.Value, 'f', -1, 64)
+}
+
+func (n *Number) series(data *dataset) Series {
+	values := make([]float64, data.len())
+	for i := range values {
+		values[i] = n.Value
+	}
+	return Series{Values: values}
+}
+
+// Field is one of the bar columns: open, high, low, close or volume.
+type Field struct {
+	Name string
+}
+
+func (f *Field) String() string {
+	return f.Name
+}
+
+func (f *Field) series(data *dataset) Series {
+	return Series{Values: data.field(f.Name)}
+}
+
+// Indicator is a call such as SMA(close, 20). Source is nil for indicators
+// computed from the high, low and close columns.
+type Indicator struct {
+	Name   string
+	Source Value
+	Params []float64
+}
+
+func (n *Indicator) String() string {
+	var args []string
+	if n.Source != nil {
+		args = append(args, n.Source.String())
+	}
+	for _, param := range n.Params {
+		args = append(args, strconv.FormatFloat(param, 'f', -1, 64))
+	}
+	return n.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (n *Indicator) series(data *dataset) Series {
+	key := n.String()
+	if cached, ok := data.memo[key]; ok {
+		return cached
+	}
+
+	spec := indicators[n.Name]
+
+	var result Series
+	if n.Source == nil {
+		result = spec.compute(data, Series{Values: data.close}, n.Params)
+	} else {
+		result = spec.compute(data, n.Source.series(data), n.Params)
+	}
+
+	data.memo[key] = result
+	return result
+}
+
+type Negate struct {
+	X Value
+}
+
+func (n *Negate) String() string {
+	return "-" + wrap(n.X, precedenceUnary)
+}
+
+func (n *Negate) series(data *dataset) Series {
+	x := n.X.series(data)
+	values := make([]float64, len(x.Values))
+	for i, value := range x.Values {
+		values[i] = -value
+	}
+	return Series{Values: values, Start: x.Start}
+}
+
+// Arithmetic is +, -, * or / between two values. Division by zero yields an
+// undefined value, which makes any comparison on it false.
+type Arithmetic struct {
+	Op    string
+	Left  Value
+	Right Value
+}
+
+func (n *Arithmetic) String() string {
+	precedence := precedenceOf(n)
+	return wrap(n.Left, precedence) + " " + n.Op + " " + wrap(n.Right, precedence+1)
+}
+
+func (n *Arithmetic) series(data *dataset) Series {
+	left, right := n.Left.series(data), n.Right.series(data)
+
+	values := make([]float64, len(left.Values))
+	for i := range values {
+		a, b := left.Values[i], right.Values[i]
+		switch n.Op {
+		case "+":
+			values[i] = a + b
+		case "-":
+			values[i] = a - b
+		case "*":
+			values[i] = a * b
+		case "/":
+			if b == 0 {
+				values[i] = math.NaN()
+			} else {
+				values[i] = a / b
+			}
+		}
+	}
+
+	start := left.Start
+	if right.Start > start {
+		start = right.Start
+	}
+	return Series{Values: values, Start: start}
+}
+
+// Compare is <, <=, >, >=, == or != between two values. It is false while
+// either side is still warming up.
+type Compare struct {
+	Op    string
+	Left  Value
+	Right Value
+}
+
+func (n *Compare) String() string {
+	return n.Left.String() + " " + n.Op + " " + n.Right.String()
+}
+
+func (n *Compare) compile(data *dataset) predicate {
+	left, right := n.Left.series(data), n.Right.series(data)
+
+	return func(i int, state State) bool {
+		a, ok := left.at(i)
+		if !ok {
+			return false
+		}
+		b, ok := right.at(i)
+		if !ok {
+			return false
+		}
+
+		switch n.Op {
+		case "<":
+			return a < b
+		case "<=":
+			return a <= b
+		case ">":
+			return a > b
+		case ">=":
+			return a >= b
+		case "==":
+			return a == b
+		case "!=":
+			return a != b
+		}
+		return false
+	}
+}
+
+// Cross is true on the bar where Left moves from at or below Right to above
+// it (crosses_above), or the other way round (crosses_below).
+type Cross struct {
+	Above bool
+	Left  Value
+	Right Value
+}
+
+func (n *Cross) String() string {
+	op := "crosses_below"
+	if n.Above {
+		op = "crosses_above"
+	}
+	return n.Left.String() + " " + op + " " + n.Right.String()
+}
+
+func (n *Cross) compile(data *dataset) predicate {
+	left, right := n.Left.series(data), n.Right.series(data)
+
+	return func(i int, state State) bool {
+		a0, ok0 := left.at(i - 1)
+		b0, ok1 := right.at(i - 1)
+		a1, ok2 := left.at(i)
+		b1, ok3 := right.at(i)
+		if !ok0 || !ok1 || !ok2 || !ok3 {
+			return false
+		}
+
+		if n.Above {
+			return a0 <= b0 && a1 > b1
+		}
+		return a0 >= b0 && a1 < b1
+	}
+}
+
+// Stop is a position exit: stop_loss and take_profit compare the close with
+// the entry price, trailing_stop with the highest close since entry. Percent
+// is a fraction, so 10% is 0.1.
+type Stop struct {
+	Kind    string
+	Percent float64
+}
+
+func (n *Stop) String() string {
+	return n.Kind + " " + strconv.FormatFloat(n.Percent*100, 'f', -1, 64) + "%"
+}
+
+func (n *Stop) compile(data *dataset) predicate {
+	return func(i int, state State) bool {
+		if !state.Holding {
+			return false
+		}
+
+		price := data.close[i]
+		if state.Short {
+			switch n.Kind {
+			case "stop_loss":
+				return price > state.EntryPrice*(1+n.Percent)
+			case "take_profit":
+				return price < state.EntryPrice*(1-n.Percent)
+			case "trailing_stop":
+				return price > state.LowestClose*(1+n.Percent)
+			}
+			return false
+		}
+
+		switch n.Kind {
+		case "stop_loss":
+			return price < state.EntryPrice*(1-n.Percent)
+		case "take_profit":
+			return price > state.EntryPrice*(1+n.Percent)
+		case "trailing_stop":
+			return price < state.HighestClose*(1-n.Percent)
+		}
+		return false
+	}
+}
+
+// Logical joins two conditions with AND or OR.
+type Logical struct {
+	Op    string
+	Left  Condition
+	Right Condition
+}
+
+func (n *Logical) String() string {
+	precedence := precedenceOf(n)
+	return wrap(n.Left, precedence) + " " + n.Op + " " + wrap(n.Right, precedence)
+}
+
+func (n *Logical) compile(data *dataset) predicate {
+	left, right := n.Left.compile(data), n.Right.compile(data)
+
+	if n.Op == "AND" {
+		return func(i int, state State) bool {
+			return left(i, state) && right(i, state)
+		}
+	}
+	return func(i int, state State) bool {
+		return left(i, state) || right(i, state)
+	}
+}
+
+type Not struct {
+	X Condition
+}
+
+func (n *Not) String() string {
+	return "NOT " + wrap(n.X, precedenceUnary)
+}
+
+func (n *Not) compile(data *dataset) predicate {
+	x := n.X.compile(data)
+	return func(i int, state State) bool {
+		return !x(i, state)
+	}
+}
+
+const (
+	precedenceOr = iota + 1
+	precedenceAnd
+	precedenceCompare
+	precedenceSum
+	precedenceProduct
+	precedenceUnary
+	precedenceAtom
+)
+
+func precedenceOf(node Node) int {
+	switch n := node.(type) {
+	case *Logical:
+		if n.Op == "OR" {
+			return precedenceOr
+		}
+		return precedenceAnd
+	case *Compare, *Cross, *Stop:
+		return precedenceCompare
+	case *Arithmetic:
+		if n.Op == "+" || n.Op == "-" {
+			return precedenceSum
+		}
+		return precedenceProduct
+	case *Negate, *Not:
+		return precedenceUnary
+	}
+	return precedenceAtom
+}
+
+// wrap parenthesises node when it binds looser than its parent
+func wrap(node Node, parent int) string {
+	if precedenceOf(node) < parent {
+		return "(" + node.String() + ")"
+	}
+	return node.String()
+}
+
+// terms splits a condition on its top-level ORs, so a strategy can report
+// which alternative triggered.
+func terms(condition Condition) []Condition {
+	if logical, ok := condition.(*Logical); ok && logical.Op == "OR" {
+		return append(terms(logical.Left), terms(logical.Right)...)
+	}
+	return []Condition{condition}
+}
@@ -0,0 +1,339 @@
+package rules
+
+import (
+	"id/projects/market-data/backtest"
+	"sort"
+
+	"github.com/markcheno/go-talib"
+)
+
+// dataset holds the bar columns and the indicator series computed from them,
+// shared by every condition of a strategy so each indicator runs once.
+type dataset struct {
+	open   []float64
+	high   []float64
+	low    []float64
+	close  []float64
+	volume []float64
+	memo   map[string]Series
+}
+
+func newDataset(bars []backtest.Bar) *dataset {
+	data := &dataset{
+		open:   make([]float64, len(bars)),
+		high:   make([]float64, len(bars)),
+		low:    make([]float64, len(bars)),
+		close:  make([]float64, len(bars)),
+		volume: make([]float64, len(bars)),
+		memo:   make(map[string]Series),
+	}
+
+	for i, bar := range bars {
+		data.open[i] = bar.Open
+		data.high[i] = bar.High
+		data.low[i] = bar.Low
+		data.close[i] = bar.Close
+		data.volume[i] = bar.Volume
+	}
+
+	return data
+}
+
+func (d *dataset) len() int {
+	return len(d.close)
+}
+
+func (d *dataset) field(name string) []float64 {
+	switch name {
+	case "open":
+		return d.open
+	case "high":
+		return d.high
+	case "low":
+		return d.low
+	case "volume":
+		return d.volume
+	}
+	return d.close
+}
+
+var fields = map[string]bool{"open": true, "high": true, "low": true, "close": true, "volume": true}
+
+// Param is one numeric argument of an indicator. Default 0 means the
+// argument is required; Period parameters must be whole numbers.
+type Param struct {
+	Name    string  `json:"name"`
+	Default float64 `json:"default,omitempty"`
+	Period  bool    `json:"period"`
+}
+
+type indicator struct {
+	// source is true for indicators over a single series, which default to
+	// close, and false for those computed from high, low and close.
+	source      bool
+	params      []Param
+	description string
+	lookback    func(params []int) int
+	calculate   func(data *dataset, source []float64, params []float64) []float64
+}
+
+// compute runs the indicator on the defined part of source only, so an
+// indicator of an indicator is not skewed by the warm-up values, and marks
+// the result defined after the indicator's own warm-up.
+func (ind indicator) compute(data *dataset, source Series, params []float64) Series {
+	periods := make([]int, len(params))
+	for i, param := range params {
+		periods[i] = int(param)
+	}
+
+	values := make([]float64, len(source.Values))
+	lookback := ind.lookback(periods)
+	start := source.Start + lookback
+	if start >= len(values) {
+		return Series{Values: values, Start: len(values)}
+	}
+
+	if !ind.source {
+		copy(values, ind.calculate(data, nil, params))
+		return Series{Values: values, Start: lookback}
+	}
+
+	copy(values[source.Start:], ind.calculate(data, source.Values[source.Start:], params))
+	return Series{Values: values, Start: start}
+}
+
+func period(params []int) int {
+	return params[0]
+}
+
+func periodLess1(params []int) int {
+	return params[0] - 1
+}
+
+var indicators = map[string]indicator{
+	"SMA": {
+		source:      true,
+		params:      []Param{{Name: "period", Period: true}},
+		description: "simple moving average",
+		lookback:    periodLess1,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			return talib.Sma(source, int(params[0]))
+		},
+	},
+	"EMA": {
+		source:      true,
+		params:      []Param{{Name: "period", Period: true}},
+		description: "exponential moving average",
+		lookback:    periodLess1,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			return talib.Ema(source, int(params[0]))
+		},
+	},
+	"WMA": {
+		source:      true,
+		params:      []Param{{Name: "period", Period: true}},
+		description: "weighted moving average",
+		lookback:    periodLess1,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			return talib.Wma(source, int(params[0]))
+		},
+	},
+	"RSI": {
+		source:      true,
+		params:      []Param{{Name: "period", Default: 14, Period: true}},
+		description: "relative strength index, 0 to 100",
+		lookback:    period,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			return talib.Rsi(source, int(params[0]))
+		},
+	},
+	"MOM": {
+		source:      true,
+		params:      []Param{{Name: "period", Default: 10, Period: true}},
+		description: "momentum, the change over period bars",
+		lookback:    period,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			return talib.Mom(source, int(params[0]))
+		},
+	},
+	"ROC": {
+		source:      true,
+		params:      []Param{{Name: "period", Default: 10, Period: true}},
+		description: "rate of change over period bars, in percent",
+		lookback:    period,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			return talib.Roc(source, int(params[0]))
+		},
+	},
+	"HIGHEST": {
+		source:      true,
+		params:      []Param{{Name: "period", Period: true}},
+		description: "highest value over the last period bars, including the current one",
+		lookback:    periodLess1,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			return talib.Max(source, int(params[0]))
+		},
+	},
+	"LOWEST": {
+		source:      true,
+		params:      []Param{{Name: "period", Period: true}},
+		description: "lowest value over the last period bars, including the current one",
+		lookback:    periodLess1,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			return talib.Min(source, int(params[0]))
+		},
+	},
+	"STDDEV": {
+		source:      true,
+		params:      []Param{{Name: "period", Period: true}},
+		description: "standard deviation over period bars",
+		lookback:    periodLess1,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			return talib.StdDev(source, int(params[0]), 1)
+		},
+	},
+	"PREV": {
+		source:      true,
+		params:      []Param{{Name: "bars", Default: 1, Period: true}},
+		description: "the value bars bars ago",
+		lookback:    period,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			shift := int(params[0])
+			values := make([]float64, len(source))
+			copy(values[shift:], source[:len(source)-shift])
+			return values
+		},
+	},
+	"MACD": {
+		source:      true,
+		params:      macdParams,
+		description: "MACD line, the fast EMA minus the slow EMA",
+		lookback:    macdLookback,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			line, _, _ := talib.Macd(source, int(params[0]), int(params[1]), int(params[2]))
+			return line
+		},
+	},
+	"MACD_SIGNAL": {
+		source:      true,
+		params:      macdParams,
+		description: "MACD signal line, the EMA of the MACD line",
+		lookback:    macdLookback,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			_, signal, _ := talib.Macd(source, int(params[0]), int(params[1]), int(params[2]))
+			return signal
+		},
+	},
+	"MACD_HIST": {
+		source:      true,
+		params:      macdParams,
+		description: "MACD histogram, the MACD line minus its signal line",
+		lookback:    macdLookback,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			_, _, hist := talib.Macd(source, int(params[0]), int(params[1]), int(params[2]))
+			return hist
+		},
+	},
+	"BB_UPPER": {
+		source:      true,
+		params:      bandParams,
+		description: "upper Bollinger band, the SMA plus deviations standard deviations",
+		lookback:    periodLess1,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			upper, _, _ := talib.BBands(source, int(params[0]), params[1], params[1], talib.SMA)
+			return upper
+		},
+	},
+	"BB_MIDDLE": {
+		source:      true,
+		params:      bandParams,
+		description: "middle Bollinger band, the SMA",
+		lookback:    periodLess1,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			_, middle, _ := talib.BBands(source, int(params[0]), params[1], params[1], talib.SMA)
+			return middle
+		},
+	},
+	"BB_LOWER": {
+		source:      true,
+		params:      bandParams,
+		description: "lower Bollinger band, the SMA minus deviations standard deviations",
+		lookback:    periodLess1,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			_, _, lower := talib.BBands(source, int(params[0]), params[1], params[1], talib.SMA)
+			return lower
+		},
+	},
+	"ATR": {
+		params:      []Param{{Name: "period", Default: 14, Period: true}},
+		description: "average true range",
+		lookback:    period,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			return talib.Atr(data.high, data.low, data.close, int(params[0]))
+		},
+	},
+	"CCI": {
+		params:      []Param{{Name: "period", Default: 20, Period: true}},
+		description: "commodity channel index",
+		lookback:    periodLess1,
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			return talib.Cci(data.high, data.low, data.close, int(params[0]))
+		},
+	},
+	"ADX": {
+		params:      []Param{{Name: "period", Default: 14, Period: true}},
+		description: "average directional index, 0 to 100",
+		lookback: func(params []int) int {
+			return 2*params[0] - 1
+		},
+		calculate: func(data *dataset, source []float64, params []float64) []float64 {
+			return talib.Adx(data.high, data.low, data.close, int(params[0]))
+		},
+	},
+}
+
+var macdParams = []Param{
+	{Name: "fast", Default: 12, Period: true},
+	{Name: "slow", Default: 26, Period: true},
+	{Name: "signal", Default: 9, Period: true},
+}
+
+func macdLookback(params []int) int {
+	slow, signal := params[1], params[2]
+	if params[0] > slow {
+		slow = params[0]
+	}
+	return slow + signal - 2
+}
+
+var bandParams = []Param{
+	{Name: "period", Default: 20, Period: true},
+	{Name: "deviations", Default: 2},
+}
+
+// IndicatorInfo describes an indicator for documentation.
+type IndicatorInfo struct {
+	Name        string  `json:"name"`
+	Source      bool    `json:"source"`
+	Params      []Param `json:"params"`
+	Description string  `json:"description"`
+}
+
+// Indicators lists the functions rules can call, by name.
+func Indicators() []IndicatorInfo {
+	var infos []IndicatorInfo
+	for name, ind := range indicators {
+		infos = append(infos, IndicatorInfo{
+			Name:        name,
+			Source:      ind.source,
+			Params:      ind.params,
+			Description: ind.description,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	return infos
+}
@@ -0,0 +1,99 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+	pos   int
+}
+
+// operators is ordered so two-character operators match before their prefixes
+var operators = []string{"<=", ">=", "==", "!=", "&&", "||", "<", ">", "=", "!", "+", "-", "*", "/"}
+
+// lex splits a rule into tokens. Numbers may carry a trailing "%", which
+// divides them by 100 so "10%" and "0.1" are the same value.
+func lex(input string) ([]token, error) {
+	var tokens []token
+
+	for pos := 0; pos < len(input); {
+		r := rune(input[pos])
+
+		switch {
+		case unicode.IsSpace(r):
+			pos++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: pos})
+			pos++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: pos})
+			pos++
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: pos})
+			pos++
+		case unicode.IsDigit(r) || r == '.':
+			end := pos
+			for end < len(input) && (unicode.IsDigit(rune(input[end])) || input[end] == '.') {
+				end++
+			}
+
+			value, err := strconv.ParseFloat(input[pos:end], 64)
+			if err != nil {
+				return nil, syntaxError(pos, "invalid number %q", input[pos:end])
+			}
+			if end < len(input) && input[end] == '%' {
+				value /= 100
+				end++
+			}
+
+			tokens = append(tokens, token{kind: tokenNumber, text: input[pos:end], value: value, pos: pos})
+			pos = end
+		case unicode.IsLetter(r) || r == '_':
+			end := pos
+			for end < len(input) && (unicode.IsLetter(rune(input[end])) || unicode.IsDigit(rune(input[end])) || input[end] == '_') {
+				end++
+			}
+
+			tokens = append(tokens, token{kind: tokenIdent, text: input[pos:end], pos: pos})
+			pos = end
+		default:
+			matched := ""
+			for _, operator := range operators {
+				if strings.HasPrefix(input[pos:], operator) {
+					matched = operator
+					break
+				}
+			}
+			if matched == "" {
+				return nil, syntaxError(pos, "unexpected character %q", r)
+			}
+
+			tokens = append(tokens, token{kind: tokenOperator, text: matched, pos: pos})
+			pos += len(matched)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(input)}), nil
+}
+
+func syntaxError(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("%w at column %d: %s", ErrSyntax, pos+1, fmt.Sprintf(format, args...))
+}
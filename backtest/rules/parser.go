@@ -0,0 +1,492 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var ErrSyntax = errors.New("invalid rule")
+
+var stops = map[string]bool{"stop_loss": true, "take_profit": true, "trailing_stop": true}
+
+const (
+	// MaxRuleLength is the longest rule or expression, in bytes, Parse accepts
+	MaxRuleLength = 4096
+	// MaxNesting bounds how deep parentheses, NOT, negation and indicator
+	// calls may nest, so a hostile rule cannot exhaust the stack
+	MaxNesting = 64
+	// MaxPeriod is the largest period an indicator may look back over
+	MaxPeriod = 10000
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+// newParser lexes input after checking its length.
+func newParser(input string) (*parser, error) {
+	if len(input) > MaxRuleLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrSyntax, MaxRuleLength)
+	}
+
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens}, nil
+}
+
+// Parse reads a rule such as "RSI(14) < 30 AND close > SMA(200)". Keywords
+// (AND, OR, NOT, crosses_above, crosses_below) and indicator names are case
+// insensitive; the bar fields are open, high, low, close and volume.
+func Parse(rule string) (Condition, error) {
+	p, err := newParser(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, syntaxError(next.pos, "unexpected %q", next.text)
+	}
+
+	condition, ok := node.(Condition)
+	if !ok {
+		return nil, syntaxError(0, "%q is a value, not a condition", node.String())
+	}
+
+	return condition, nil
+}
+
+// ParseValue reads a numeric expression such as "ROC(close, 63)" or
+// "close / SMA(200)".
+func ParseValue(expression string) (Value, error) {
+	p, err := newParser(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, syntaxError(next.pos, "unexpected %q", next.text)
+	}
+
+	value, ok := node.(Value)
+	if !ok {
+		return nil, syntaxError(0, "%q is a condition, not a value", node.String())
+	}
+
+	return value, nil
+}
+
+// enter descends one nesting level; every call must be paired with leave.
+func (p *parser) enter(at int) error {
+	p.depth++
+	if p.depth > MaxNesting {
+		return syntaxError(at, "nested deeper than %d levels", MaxNesting)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword reports whether the next token is one of words, ignoring case, and
+// consumes it if so.
+func (p *parser) keyword(words ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenIdent && t.kind != tokenOperator {
+		return "", false
+	}
+
+	for _, word := range words {
+		if strings.EqualFold(t.text, word) {
+			p.pos++
+			return word, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		at := p.peek().pos
+		if _, ok := p.keyword("OR", "||"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		l, r, err := conditions(at, left, right)
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "OR", Left: l, Right: r}
+	}
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		at := p.peek().pos
+		if _, ok := p.keyword("AND", "&&"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		l, r, err := conditions(at, left, right)
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "AND", Left: l, Right: r}
+	}
+}
+
+func (p *parser) parseNot() (Node, error) {
+	at := p.peek().pos
+	if _, ok := p.keyword("NOT", "!"); !ok {
+		return p.parseComparison()
+	}
+
+	if err := p.enter(at); err != nil {
+		return nil, err
+	}
+	node, err := p.parseNot()
+	p.leave()
+	if err != nil {
+		return nil, err
+	}
+
+	condition, ok := node.(Condition)
+	if !ok {
+		return nil, syntaxError(at, "NOT needs a condition, got %q", node.String())
+	}
+	return &Not{X: condition}, nil
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	if t := p.peek(); t.kind == tokenIdent && stops[strings.ToLower(t.text)] {
+		return p.parseStop()
+	}
+
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	at := p.peek().pos
+	op, ok := p.keyword("<", "<=", ">", ">=", "==", "=", "!=", "crosses_above", "crosses_below")
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	l, r, err := values(at, left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "crosses_above":
+		return &Cross{Above: true, Left: l, Right: r}, nil
+	case "crosses_below":
+		return &Cross{Above: false, Left: l, Right: r}, nil
+	case "=":
+		op = "=="
+	}
+	return &Compare{Op: op, Left: l, Right: r}, nil
+}
+
+// parseStop reads "trailing_stop 10%" or "trailing_stop(10%)".
+func (p *parser) parseStop() (Node, error) {
+	kind := strings.ToLower(p.next().text)
+
+	parenthesised := p.peek().kind == tokenLParen
+	if parenthesised {
+		p.next()
+	}
+
+	t := p.next()
+	if t.kind != tokenNumber {
+		return nil, syntaxError(t.pos, "%s needs a percentage such as 10%%", kind)
+	}
+	if t.value <= 0 || t.value >= 1 {
+		return nil, syntaxError(t.pos, "%s must be between 0%% and 100%%, got %s", kind, t.text)
+	}
+
+	if parenthesised {
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, syntaxError(closing.pos, "expected \")\"")
+		}
+	}
+
+	return &Stop{Kind: kind, Percent: t.value}, nil
+}
+
+func (p *parser) parseSum() (Node, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		at := p.peek().pos
+		op, ok := p.keyword("+", "-")
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+
+		l, r, err := values(at, left, right)
+		if err != nil {
+			return nil, err
+		}
+		left = &Arithmetic{Op: op, Left: l, Right: r}
+	}
+}
+
+func (p *parser) parseProduct() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		at := p.peek().pos
+		op, ok := p.keyword("*", "/")
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		l, r, err := values(at, left, right)
+		if err != nil {
+			return nil, err
+		}
+		left = &Arithmetic{Op: op, Left: l, Right: r}
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	at := p.peek().pos
+	if _, ok := p.keyword("-"); !ok {
+		return p.parsePrimary()
+	}
+
+	if err := p.enter(at); err != nil {
+		return nil, err
+	}
+	node, err := p.parseUnary()
+	p.leave()
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := node.(Value)
+	if !ok {
+		return nil, syntaxError(at, "cannot negate the condition %q", node.String())
+	}
+	if number, ok := value.(*Number); ok {
+		return &Number{Value: -number.Value}, nil
+	}
+	return &Negate{X: value}, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenNumber:
+		return &Number{Value: t.value}, nil
+	case tokenLParen:
+		if err := p.enter(t.pos); err != nil {
+			return nil, err
+		}
+		node, err := p.parseOr()
+		p.leave()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, syntaxError(closing.pos, "expected \")\"")
+		}
+		return node, nil
+	case tokenIdent:
+		if p.peek().kind == tokenLParen {
+			return p.parseCall(t)
+		}
+
+		name := strings.ToLower(t.text)
+		if !fields[name] {
+			return nil, syntaxError(t.pos, "unknown field %q, expected open, high, low, close or volume", t.text)
+		}
+		return &Field{Name: name}, nil
+	case tokenEOF:
+		return nil, syntaxError(t.pos, "unexpected end of rule")
+	}
+
+	return nil, syntaxError(t.pos, "unexpected %q", t.text)
+}
+
+// parseCall reads an indicator call. A first argument that is not a number is
+// the source series; the remaining arguments fill the parameters in order,
+// and parameters left out take their defaults.
+func (p *parser) parseCall(name token) (Node, error) {
+	p.next()
+
+	upper := strings.ToUpper(name.text)
+	spec, ok := indicators[upper]
+	if !ok {
+		return nil, syntaxError(name.pos, "unknown indicator %q", name.text)
+	}
+
+	if err := p.enter(name.pos); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	var args []Node
+	if p.peek().kind != tokenRParen {
+		for {
+			arg, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if closing := p.next(); closing.kind != tokenRParen {
+		return nil, syntaxError(closing.pos, "expected \")\" after the arguments of %s", upper)
+	}
+
+	indicator := &Indicator{Name: upper}
+
+	if len(args) > 0 {
+		if _, isNumber := args[0].(*Number); !isNumber {
+			if !spec.source {
+				return nil, syntaxError(name.pos, "%s is computed from high, low and close and takes no source", upper)
+			}
+
+			source, ok := args[0].(Value)
+			if !ok {
+				return nil, syntaxError(name.pos, "the source of %s must be a value, got %q", upper, args[0].String())
+			}
+			indicator.Source = source
+			args = args[1:]
+		}
+	}
+	if spec.source && indicator.Source == nil {
+		indicator.Source = &Field{Name: "close"}
+	}
+
+	if len(args) > len(spec.params) {
+		return nil, syntaxError(name.pos, "%s takes at most %d parameters", upper, len(spec.params))
+	}
+
+	for i, param := range spec.params {
+		value := param.Default
+		if i < len(args) {
+			number, ok := args[i].(*Number)
+			if !ok {
+				return nil, syntaxError(name.pos, "the %s of %s must be a number", param.Name, upper)
+			}
+			value = number.Value
+		}
+
+		if value <= 0 {
+			return nil, syntaxError(name.pos, "%s needs a positive %s", upper, param.Name)
+		}
+		if param.Period && value != math.Trunc(value) {
+			return nil, syntaxError(name.pos, "the %s of %s must be a whole number", param.Name, upper)
+		}
+		if param.Period && value > MaxPeriod {
+			return nil, syntaxError(name.pos, "the %s of %s must not exceed %d", param.Name, upper, MaxPeriod)
+		}
+		indicator.Params = append(indicator.Params, value)
+	}
+
+	return indicator, nil
+}
+
+func conditions(at int, left Node, right Node) (Condition, Condition, error) {
+	l, ok := left.(Condition)
+	if !ok {
+		return nil, nil, syntaxError(at, "%q is a value, not a condition", left.String())
+	}
+	r, ok := right.(Condition)
+	if !ok {
+		return nil, nil, syntaxError(at, "%q is a value, not a condition", right.String())
+	}
+	return l, r, nil
+}
+
+func values(at int, left Node, right Node) (Value, Value, error) {
+	l, ok := left.(Value)
+	if !ok {
+		return nil, nil, syntaxError(at, "%q is a condition, not a value", left.String())
+	}
+	r, ok := right.(Value)
+	if !ok {
+		return nil, nil, syntaxError(at, "%q is a condition, not a value", right.String())
+	}
+	return l, r, nil
+}
@@ -0,0 +1,37 @@
+package rules
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		ok   bool
+	}{
+		{"plain rule", "RSI(14) < 30 AND close > SMA(200)", true},
+		{"nested at the limit", strings.Repeat("(", MaxNesting-1) + "close > 1" + strings.Repeat(")", MaxNesting-1), true},
+		{"nested parentheses", strings.Repeat("(", MaxNesting+1) + "close > 1" + strings.Repeat(")", MaxNesting+1), false},
+		{"nested NOT", strings.Repeat("NOT ", MaxNesting+1) + "close > 1", false},
+		{"nested negation", "close > " + strings.Repeat("-", MaxNesting+1) + "1", false},
+		{"nested calls", "close > " + strings.Repeat("SMA(", MaxNesting+1) + "close" + strings.Repeat(")", MaxNesting+1), false},
+		{"too long", "close > 1" + strings.Repeat(" ", MaxRuleLength), false},
+		{"largest period", "close > SMA(10000)", true},
+		{"huge period", "close > SMA(1000000000000)", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.rule)
+			if test.ok && err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !test.ok && !errors.Is(err, ErrSyntax) {
+				t.Fatalf("Parse error = %v, want ErrSyntax", err)
+			}
+		})
+	}
+}
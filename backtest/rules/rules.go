@@ -0,0 +1,91 @@
+// Package rules parses declarative trading rules such as
+//
+//	entry: RSI(14) < 30 AND close > SMA(200)
+//	exit:  close < SMA(50) OR trailing_stop 10%
+//	short: RSI(14) > 70 AND close < SMA(200)
+//	cover: close > SMA(50) OR stop_loss 5%
+//
+// into expression trees over go-talib indicators, and runs them as a
+// backtest.Strategy.
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrNoEntry = errors.New("strategy needs an entry or a short rule")
+
+// Definition is a strategy as written by the user. Entry opens long
+// positions that Exit closes, Short opens short positions that Cover closes;
+// either side may be left out. An empty Exit or Cover holds the position
+// until the end of the backtest.
+type Definition struct {
+	Entry string `json:"entry,omitempty" yaml:"entry"`
+	Exit  string `json:"exit,omitempty" yaml:"exit"`
+	Short string `json:"short,omitempty" yaml:"short"`
+	Cover string `json:"cover,omitempty" yaml:"cover"`
+}
+
+// ParseDefinition reads a definition from text, either a YAML document with
+// entry, exit, short and cover keys or the one-line form
+// "entry: ...; exit: ...".
+func ParseDefinition(text string) (Definition, error) {
+	var definition Definition
+	if err := yaml.Unmarshal([]byte(text), &definition); err == nil && (definition.Entry != "" || definition.Short != "") {
+		return definition, nil
+	}
+
+	definition = Definition{}
+	for _, part := range strings.Split(text, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		key, rule, ok := strings.Cut(part, ":")
+		if !ok {
+			return Definition{}, fmt.Errorf("%w: expected \"entry: ...\", \"exit: ...\", \"short: ...\" or \"cover: ...\", got %q", ErrSyntax, part)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "entry":
+			definition.Entry = strings.TrimSpace(rule)
+		case "exit":
+			definition.Exit = strings.TrimSpace(rule)
+		case "short":
+			definition.Short = strings.TrimSpace(rule)
+		case "cover":
+			definition.Cover = strings.TrimSpace(rule)
+		default:
+			return Definition{}, fmt.Errorf("%w: unknown key %q, expected entry, exit, short or cover", ErrSyntax, strings.TrimSpace(key))
+		}
+	}
+
+	if definition.Entry == "" && definition.Short == "" {
+		return Definition{}, ErrNoEntry
+	}
+
+	return definition, nil
+}
+
+// UnmarshalJSON accepts either {"entry": ..., "exit": ...} or a string in
+// one of the forms ParseDefinition reads.
+func (d *Definition) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		definition, err := ParseDefinition(text)
+		if err != nil {
+			return err
+		}
+		*d = definition
+		return nil
+	}
+
+	type plain Definition
+	return json.Unmarshal(data, (*plain)(d))
+}
@@ -0,0 +1,33 @@
+package rules
+
+import (
+	"id/projects/market-data/backtest"
+	"math"
+)
+
+// Evaluate computes value on every bar, NaN while it is still warming up.
+func Evaluate(value Value, bars []backtest.Bar) []float64 {
+	series := value.series(newDataset(bars))
+
+	values := make([]float64, len(series.Values))
+	for i := range values {
+		if v, ok := series.at(i); ok {
+			values[i] = v
+		} else {
+			values[i] = math.NaN()
+		}
+	}
+	return values
+}
+
+// Matches evaluates condition on every bar as if no position were held, so
+// stops never match.
+func Matches(condition Condition, bars []backtest.Bar) []bool {
+	match := condition.compile(newDataset(bars))
+
+	matches := make([]bool, len(bars))
+	for i := range matches {
+		matches[i] = match(i, State{})
+	}
+	return matches
+}
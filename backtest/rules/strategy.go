@@ -0,0 +1,162 @@
+package rules
+
+import "id/projects/market-data/backtest"
+
+// Strategy opens a position sized by the backtest's Sizing when Entry (or
+// Short) holds and no position is open, and closes it when Exit (or Cover)
+// holds. Entry wins when both entry rules hold on the same bar. Short
+// positions are only filled when the backtest's Margin allows shorting.
+type Strategy struct {
+	Entry Condition
+	Exit  Condition
+	Short Condition
+	Cover Condition
+
+	entry        []term
+	exit         []term
+	short        []term
+	cover        []term
+	entryPrice   float64
+	highestClose float64
+	lowestClose  float64
+}
+
+type term struct {
+	rule  string
+	match predicate
+}
+
+// New parses the rules of definition.
+func New(definition Definition) (*Strategy, error) {
+	if definition.Entry == "" && definition.Short == "" {
+		return nil, ErrNoEntry
+	}
+
+	strategy := &Strategy{}
+	for _, rule := range []struct {
+		text      string
+		condition *Condition
+	}{
+		{definition.Entry, &strategy.Entry},
+		{definition.Exit, &strategy.Exit},
+		{definition.Short, &strategy.Short},
+		{definition.Cover, &strategy.Cover},
+	} {
+		if rule.text == "" {
+			continue
+		}
+
+		condition, err := Parse(rule.text)
+		if err != nil {
+			return nil, err
+		}
+		*rule.condition = condition
+	}
+
+	return strategy, nil
+}
+
+// Definition renders the parsed rules back, normalised.
+func (s *Strategy) Definition() Definition {
+	return Definition{
+		Entry: render(s.Entry),
+		Exit:  render(s.Exit),
+		Short: render(s.Short),
+		Cover: render(s.Cover),
+	}
+}
+
+func (s *Strategy) Init(bars []backtest.Bar) error {
+	data := newDataset(bars)
+
+	s.entry = compileTerms(s.Entry, data)
+	s.exit = compileTerms(s.Exit, data)
+	s.short = compileTerms(s.Short, data)
+	s.cover = compileTerms(s.Cover, data)
+	s.entryPrice = 0
+	s.highestClose = 0
+	s.lowestClose = 0
+	return nil
+}
+
+func (s *Strategy) OnBar(ctx *backtest.Context) {
+	i := ctx.Index
+	price := ctx.Bar().Close
+	position := ctx.Position()
+
+	if position.Quantity != 0 {
+		if s.entryPrice == 0 {
+			s.entryPrice = position.AvgPrice
+			s.highestClose = position.AvgPrice
+			s.lowestClose = position.AvgPrice
+		}
+		if price > s.highestClose {
+			s.highestClose = price
+		}
+		if price < s.lowestClose {
+			s.lowestClose = price
+		}
+
+		state := State{
+			Holding:      true,
+			Short:        position.Quantity < 0,
+			EntryPrice:   s.entryPrice,
+			HighestClose: s.highestClose,
+			LowestClose:  s.lowestClose,
+		}
+
+		closing := s.exit
+		if state.Short {
+			closing = s.cover
+		}
+		if rule, ok := firstMatch(closing, i, state); ok {
+			ctx.Close(rule)
+		}
+		return
+	}
+
+	s.entryPrice = 0
+	s.highestClose = 0
+	s.lowestClose = 0
+
+	value := ctx.EntryValue()
+	if value <= 0 {
+		return
+	}
+
+	if rule, ok := firstMatch(s.entry, i, State{}); ok {
+		ctx.BuyValue(value, rule)
+	} else if rule, ok := firstMatch(s.short, i, State{}); ok {
+		ctx.ShortValue(value, rule)
+	}
+}
+
+func render(condition Condition) string {
+	if condition == nil {
+		return ""
+	}
+	return condition.String()
+}
+
+// compileTerms compiles each top-level OR alternative separately, so the
+// trade reason names the alternative that fired rather than the whole rule.
+func compileTerms(condition Condition, data *dataset) []term {
+	if condition == nil {
+		return nil
+	}
+
+	var compiled []term
+	for _, t := range terms(condition) {
+		compiled = append(compiled, term{rule: t.String(), match: t.compile(data)})
+	}
+	return compiled
+}
+
+func firstMatch(compiled []term, i int, state State) (string, bool) {
+	for _, t := range compiled {
+		if t.match(i, state) {
+			return t.rule, true
+		}
+	}
+	return "", false
+}
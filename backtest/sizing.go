@@ -0,0 +1,232 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/markcheno/go-talib"
+)
+
+var ErrInvalidSizing = errors.New("invalid sizing: fractions, amounts, risk and multiples must not be negative")
+
+type SizingModel string
+
+const (
+	// AllIn puts all the buying power into every entry.
+	AllIn SizingModel = "all"
+	// FixedFraction invests Fraction of equity.
+	FixedFraction SizingModel = "fixedFraction"
+	// FixedAmount invests Amount.
+	FixedAmount SizingModel = "fixedAmount"
+	// VolatilityTarget risks Risk of equity per trade, taking the risk to be
+	// ATRMultiple times the ATR.
+	VolatilityTarget SizingModel = "volatility"
+	// Kelly invests KellyFraction of the Kelly fraction estimated from the
+	// closed trades, capped at KellyCap.
+	Kelly SizingModel = "kelly"
+)
+
+func ParseSizingModel(value string) (SizingModel, error) {
+	switch SizingModel(value) {
+	case "", AllIn:
+		return AllIn, nil
+	case FixedFraction, FixedAmount, VolatilityTarget, Kelly:
+		return SizingModel(value), nil
+	}
+	return "", fmt.Errorf("unknown sizing model %q", value)
+}
+
+// Sizing decides how much a strategy puts into a new position. Entries are
+// always capped by the buying power. Settings a model does not use are
+// ignored, and zero values take the defaults noted below.
+//
+// Pyramiding adds another unit, sized the same way, every time the close has
+// moved AddEvery (0.05 is 5%) in favour of the position since the last
+// entry, up to MaxEntries entries per position. MaxEntries 0 or 1 disables
+// it.
+type Sizing struct {
+	Model    SizingModel `json:"model"`
+	Fraction float64     `json:"fraction"`
+	Amount   float64     `json:"amount"`
+	// Risk is the fraction of equity lost if the position moves ATRMultiple
+	// (default 2) times the ATRPeriod (default 14) ATR against it.
+	Risk        float64 `json:"risk"`
+	ATRPeriod   int     `json:"atrPeriod"`
+	ATRMultiple float64 `json:"atrMultiple"`
+	// KellyFraction scales the Kelly fraction (default 1, 0.5 is half Kelly)
+	// and KellyCap caps the result (default 0.25). Until MinTrades (default
+	// 10) trades have closed there is no estimate and Fraction, or KellyCap
+	// when Fraction is zero, is used.
+	KellyFraction float64 `json:"kellyFraction"`
+	KellyCap      float64 `json:"kellyCap"`
+	MinTrades     int     `json:"minTrades"`
+	MaxEntries    int     `json:"maxEntries"`
+	AddEvery      float64 `json:"addEvery"`
+}
+
+func (s Sizing) Validate() error {
+	if _, err := ParseSizingModel(string(s.Model)); err != nil {
+		return err
+	}
+	if s.Fraction < 0 || s.Amount < 0 || s.Risk < 0 || s.ATRPeriod < 0 || s.ATRMultiple < 0 ||
+		s.KellyFraction < 0 || s.KellyCap < 0 || s.MinTrades < 0 || s.MaxEntries < 0 || s.AddEvery < 0 {
+		return ErrInvalidSizing
+	}
+
+	switch s.Model {
+	case FixedFraction:
+		if s.Fraction <= 0 {
+			return fmt.Errorf("%w: fixedFraction needs a positive fraction", ErrInvalidSizing)
+		}
+	case FixedAmount:
+		if s.Amount <= 0 {
+			return fmt.Errorf("%w: fixedAmount needs a positive amount", ErrInvalidSizing)
+		}
+	case VolatilityTarget:
+		if s.Risk <= 0 {
+			return fmt.Errorf("%w: volatility needs a positive risk", ErrInvalidSizing)
+		}
+	}
+	return nil
+}
+
+func (s Sizing) withDefaults() Sizing {
+	if s.Model == "" {
+		s.Model = AllIn
+	}
+	if s.ATRPeriod == 0 {
+		s.ATRPeriod = 14
+	}
+	if s.ATRMultiple == 0 {
+		s.ATRMultiple = 2
+	}
+	if s.KellyFraction == 0 {
+		s.KellyFraction = 1
+	}
+	if s.KellyCap == 0 {
+		s.KellyCap = 0.25
+	}
+	if s.MinTrades == 0 {
+		s.MinTrades = 10
+	}
+	return s
+}
+
+// sizer applies a Sizing to one backtest and keeps track of the entries of
+// the open position for pyramiding.
+type sizer struct {
+	Sizing
+	atr       []float64
+	entries   int
+	lastEntry float64
+	held      float64
+}
+
+func newSizer(sizing Sizing, bars []Bar) *sizer {
+	s := &sizer{Sizing: sizing.withDefaults()}
+	if s.Model == VolatilityTarget && len(bars) > s.ATRPeriod {
+		highs := make([]float64, len(bars))
+		lows := make([]float64, len(bars))
+		for i, bar := range bars {
+			highs[i], lows[i] = bar.High, bar.Low
+		}
+		s.atr = talib.Atr(highs, lows, Closes(bars), s.ATRPeriod)
+	}
+	return s
+}
+
+// value is what a new entry on bar i should be worth.
+func (s *sizer) value(i int, price float64, portfolio *Portfolio, power float64) float64 {
+	equity := portfolio.MarkedEquity()
+
+	var value float64
+	switch s.Model {
+	case FixedFraction:
+		value = equity * s.Fraction
+	case FixedAmount:
+		value = s.Amount
+	case VolatilityTarget:
+		if i < s.ATRPeriod || i >= len(s.atr) || s.atr[i] <= 0 {
+			return 0
+		}
+		value = equity * s.Risk / (s.ATRMultiple * s.atr[i]) * price
+	case Kelly:
+		value = equity * s.kelly(portfolio.Trades)
+	default:
+		value = power
+	}
+
+	return math.Max(math.Min(value, power), 0)
+}
+
+// kelly estimates W - (1 - W) / R from the closed trades, where W is the win
+// rate and R the average winning return over the average losing return.
+func (s *sizer) kelly(trades []Trade) float64 {
+	if len(trades) < s.MinTrades {
+		if s.Fraction > 0 {
+			return math.Min(s.Fraction, s.KellyCap)
+		}
+		return s.KellyCap
+	}
+
+	var wins, gains, losses float64
+	for _, trade := range trades {
+		if trade.Return > 0 {
+			wins++
+			gains += trade.Return
+		} else {
+			losses -= trade.Return
+		}
+	}
+
+	winRate := wins / float64(len(trades))
+	if losses <= 0 {
+		return s.KellyCap
+	}
+	if wins == 0 {
+		return 0
+	}
+
+	lossCount := float64(len(trades)) - wins
+	ratio := (gains / wins) / (losses / lossCount)
+	fraction := (winRate - (1-winRate)/ratio) * s.KellyFraction
+	return math.Max(math.Min(fraction, s.KellyCap), 0)
+}
+
+// track counts the fills that opened or added to the position of symbol.
+func (s *sizer) track(symbol string, fills []Fill, portfolio *Portfolio) {
+	held := portfolio.Position(symbol).Quantity
+	if held == 0 || (held > 0) != (s.held > 0) {
+		s.entries = 0
+		s.lastEntry = 0
+	}
+
+	for _, fill := range fills {
+		if fill.Symbol != symbol || held == 0 {
+			continue
+		}
+		if (fill.Side == Buy) == (held > 0) {
+			s.entries++
+			s.lastEntry = fill.Price
+		}
+	}
+	s.held = held
+}
+
+func (s *sizer) split(ratio float64) {
+	s.held *= ratio
+	s.lastEntry /= ratio
+}
+
+// pyramid reports whether the open position should take another unit at
+// price.
+func (s *sizer) pyramid(held float64, price float64) bool {
+	if s.MaxEntries <= 1 || s.AddEvery <= 0 || held == 0 || s.entries == 0 || s.entries >= s.MaxEntries {
+		return false
+	}
+	if held > 0 {
+		return price >= s.lastEntry*(1+s.AddEvery)
+	}
+	return price <= s.lastEntry*(1-s.AddEvery)
+}
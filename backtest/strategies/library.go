@@ -0,0 +1,268 @@
+// Package strategies is the library of named, parameterised strategies that
+// /simulate can run. Most are templates over the rules language; momentumRSI
+// is the original hand-written strategy.
+package strategies
+
+import (
+	"errors"
+	"fmt"
+	"id/projects/market-data/backtest"
+	"id/projects/market-data/backtest/rules"
+	"math"
+	"sort"
+	"strings"
+)
+
+const Default = "momentumRSI"
+
+var (
+	ErrUnknownStrategy = errors.New("unknown strategy")
+	ErrInvalidParam    = errors.New("invalid strategy parameter")
+)
+
+// Param is one tunable value of a strategy. Fractions such as stop losses are
+// written as 0.05 for 5%; a zero stop disables it. Required parameters have
+// no default and must be given. Max, when set, is the largest value allowed;
+// periods are capped like those of the rules language.
+type Param struct {
+	Name        string  `json:"name"`
+	Default     float64 `json:"default"`
+	Required    bool    `json:"required,omitempty"`
+	Integer     bool    `json:"integer,omitempty"`
+	Fraction    bool    `json:"fraction,omitempty"`
+	Max         float64 `json:"max,omitempty"`
+	Description string  `json:"description"`
+}
+
+type Spec struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Params      []Param `json:"params"`
+	// Rules shows the entry and exit rules with the default parameters, for
+	// strategies built on the rules language.
+	Rules *rules.Definition `json:"rules,omitempty"`
+
+	build func(params map[string]float64) (backtest.Strategy, error)
+}
+
+var stopParams = []Param{
+	{Name: "stopLoss", Fraction: true, Description: "exit when the close falls this far below the entry price, 0 disables"},
+	{Name: "trailingStop", Fraction: true, Description: "exit when the close falls this far below the highest close since entry, 0 disables"},
+}
+
+var library = []Spec{
+	{
+		Name:        "smaCrossover",
+		Description: "Buy when the fast SMA crosses above the slow SMA, sell when it crosses back below.",
+		Params: append([]Param{
+			{Name: "fast", Default: 20, Integer: true, Max: rules.MaxPeriod, Description: "fast SMA period"},
+			{Name: "slow", Default: 50, Integer: true, Max: rules.MaxPeriod, Description: "slow SMA period"},
+		}, stopParams...),
+		build: ruleBuilder(func(p map[string]float64) rules.Definition {
+			return rules.Definition{
+				Entry: fmt.Sprintf("SMA(%g) crosses_above SMA(%g)", p["fast"], p["slow"]),
+				Exit:  fmt.Sprintf("SMA(%g) crosses_below SMA(%g)", p["fast"], p["slow"]),
+			}
+		}),
+	},
+	{
+		Name:        "macdCrossover",
+		Description: "Buy when the MACD line crosses above its signal line, sell when it crosses back below.",
+		Params: append([]Param{
+			{Name: "fast", Default: 12, Integer: true, Max: rules.MaxPeriod, Description: "fast EMA period"},
+			{Name: "slow", Default: 26, Integer: true, Max: rules.MaxPeriod, Description: "slow EMA period"},
+			{Name: "signal", Default: 9, Integer: true, Max: rules.MaxPeriod, Description: "signal line EMA period"},
+		}, stopParams...),
+		build: ruleBuilder(func(p map[string]float64) rules.Definition {
+			args := fmt.Sprintf("%g, %g, %g", p["fast"], p["slow"], p["signal"])
+			return rules.Definition{
+				Entry: fmt.Sprintf("MACD(%s) crosses_above MACD_SIGNAL(%s)", args, args),
+				Exit:  fmt.Sprintf("MACD(%s) crosses_below MACD_SIGNAL(%s)", args, args),
+			}
+		}),
+	},
+	{
+		Name:        "rsiMeanReversion",
+		Description: "Buy when RSI drops below the oversold level, sell when it rises above the overbought level.",
+		Params: append([]Param{
+			{Name: "rsiPeriod", Default: 14, Integer: true, Max: rules.MaxPeriod, Description: "RSI period"},
+			{Name: "oversold", Default: 30, Description: "RSI level to buy below"},
+			{Name: "overbought", Default: 70, Description: "RSI level to sell above"},
+		}, stopParams...),
+		build: ruleBuilder(func(p map[string]float64) rules.Definition {
+			return rules.Definition{
+				Entry: fmt.Sprintf("RSI(%g) < %g", p["rsiPeriod"], p["oversold"]),
+				Exit:  fmt.Sprintf("RSI(%g) > %g", p["rsiPeriod"], p["overbought"]),
+			}
+		}),
+	},
+	{
+		Name:        "bollingerBreakout",
+		Description: "Buy when the close breaks above the upper Bollinger band, sell when it falls back below the middle band.",
+		Params: append([]Param{
+			{Name: "period", Default: 20, Integer: true, Max: rules.MaxPeriod, Description: "moving average period of the bands"},
+			{Name: "deviations", Default: 2, Description: "band width in standard deviations"},
+		}, stopParams...),
+		build: ruleBuilder(func(p map[string]float64) rules.Definition {
+			args := fmt.Sprintf("%g, %g", p["period"], p["deviations"])
+			return rules.Definition{
+				Entry: fmt.Sprintf("close crosses_above BB_UPPER(%s)", args),
+				Exit:  fmt.Sprintf("close crosses_below BB_MIDDLE(%s)", args),
+			}
+		}),
+	},
+	{
+		Name:        "donchianBreakout",
+		Description: "Turtle-style channel breakout: buy on a close above the highest high of the previous entryPeriod bars, sell on a close below the lowest low of the previous exitPeriod bars.",
+		Params: append([]Param{
+			{Name: "entryPeriod", Default: 20, Integer: true, Max: rules.MaxPeriod, Description: "channel length for entries"},
+			{Name: "exitPeriod", Default: 10, Integer: true, Max: rules.MaxPeriod, Description: "channel length for exits"},
+		}, stopParams...),
+		build: ruleBuilder(func(p map[string]float64) rules.Definition {
+			return rules.Definition{
+				Entry: fmt.Sprintf("close > PREV(HIGHEST(high, %g))", p["entryPeriod"]),
+				Exit:  fmt.Sprintf("close < PREV(LOWEST(low, %g))", p["exitPeriod"]),
+			}
+		}),
+	},
+	{
+		Name:        "momentumRSI",
+		Description: "Buy when the close is below buyThreshold times its moving average while RSI is oversold, sell when it rises above sellThreshold times the average or a stop is hit.",
+		Params: []Param{
+			{Name: "buyThreshold", Required: true, Description: "buy below this multiple of the moving average, e.g. 0.95"},
+			{Name: "sellThreshold", Required: true, Description: "sell above this multiple of the moving average, e.g. 1.05"},
+			{Name: "windowSize", Default: 30, Integer: true, Max: rules.MaxPeriod, Description: "moving average period"},
+			{Name: "rsiPeriod", Default: 14, Integer: true, Max: rules.MaxPeriod, Description: "RSI period"},
+			{Name: "oversoldRSI", Default: 30, Description: "RSI level to buy below"},
+			{Name: "stopLoss", Default: 0.05, Fraction: true, Description: "exit when the close falls this far below the entry price"},
+			{Name: "trailingStop", Default: 0.1, Fraction: true, Description: "exit when the close falls this far below the highest close since entry"},
+		},
+		build: func(p map[string]float64) (backtest.Strategy, error) {
+			strategy := backtest.NewMomentumRSI(p["buyThreshold"], p["sellThreshold"])
+			strategy.WindowSize = int(p["windowSize"])
+			strategy.RSIPeriod = int(p["rsiPeriod"])
+			strategy.OversoldRSI = p["oversoldRSI"]
+			strategy.StopLoss = p["stopLoss"]
+			strategy.TrailingStop = p["trailingStop"]
+			return strategy, nil
+		},
+	},
+}
+
+// ruleBuilder turns a rules template into a builder, adding the optional
+// stops to the exit rule.
+func ruleBuilder(template func(params map[string]float64) rules.Definition) func(map[string]float64) (backtest.Strategy, error) {
+	return func(params map[string]float64) (backtest.Strategy, error) {
+		definition := template(params)
+		if stopLoss := params["stopLoss"]; stopLoss > 0 {
+			definition.Exit += fmt.Sprintf(" OR stop_loss(%g)", stopLoss)
+		}
+		if trailingStop := params["trailingStop"]; trailingStop > 0 {
+			definition.Exit += fmt.Sprintf(" OR trailing_stop(%g)", trailingStop)
+		}
+		return rules.New(definition)
+	}
+}
+
+// List returns the library sorted by name, with the rules of the rule-based
+// strategies filled in for their default parameters.
+func List() []Spec {
+	specs := append([]Spec(nil), library...)
+	for i, spec := range specs {
+		defaults, err := spec.Resolve(nil)
+		if err != nil {
+			continue
+		}
+		strategy, err := spec.build(defaults)
+		if err != nil {
+			continue
+		}
+		if ruleStrategy, ok := strategy.(*rules.Strategy); ok {
+			definition := ruleStrategy.Definition()
+			specs[i].Rules = &definition
+		}
+	}
+
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Name < specs[j].Name
+	})
+	return specs
+}
+
+// Lookup finds a strategy by name, ignoring case.
+func Lookup(name string) (Spec, bool) {
+	for _, spec := range library {
+		if strings.EqualFold(spec.Name, name) {
+			return spec, true
+		}
+	}
+	return Spec{}, false
+}
+
+// Param finds one of the strategy's parameters by name.
+func (s Spec) Param(name string) (Param, bool) {
+	for _, param := range s.Params {
+		if param.Name == name {
+			return param, true
+		}
+	}
+	return Param{}, false
+}
+
+// Resolve checks params against the strategy's parameters and fills in the
+// defaults of those left out.
+func (s Spec) Resolve(params map[string]float64) (map[string]float64, error) {
+	known := make(map[string]Param, len(s.Params))
+	for _, param := range s.Params {
+		known[param.Name] = param
+	}
+	for name := range params {
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("%w: %s has no parameter %q", ErrInvalidParam, s.Name, name)
+		}
+	}
+
+	resolved := make(map[string]float64, len(s.Params))
+	for _, param := range s.Params {
+		value, ok := params[param.Name]
+		if !ok {
+			if param.Required {
+				return nil, fmt.Errorf("%w: %s needs %s", ErrInvalidParam, s.Name, param.Name)
+			}
+			value = param.Default
+		}
+
+		switch {
+		case param.Integer && (value < 1 || value != math.Trunc(value)):
+			return nil, fmt.Errorf("%w: %s must be a positive whole number", ErrInvalidParam, param.Name)
+		case param.Fraction && (value < 0 || value >= 1):
+			return nil, fmt.Errorf("%w: %s must be between 0 and 1", ErrInvalidParam, param.Name)
+		case param.Max > 0 && value > param.Max:
+			return nil, fmt.Errorf("%w: %s must not exceed %g", ErrInvalidParam, param.Name, param.Max)
+		}
+		resolved[param.Name] = value
+	}
+
+	return resolved, nil
+}
+
+// Build resolves params for the named strategy and returns it ready to run,
+// along with the parameters it uses.
+func Build(name string, params map[string]float64) (backtest.Strategy, map[string]float64, error) {
+	spec, ok := Lookup(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w %q", ErrUnknownStrategy, name)
+	}
+
+	resolved, err := spec.Resolve(params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	strategy, err := spec.build(resolved)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return strategy, resolved, nil
+}
@@ -0,0 +1,56 @@
+package strategies
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"id/projects/market-data/backtest"
+)
+
+func TestPeriods(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	bars := make([]backtest.Bar, 250)
+	for i := range bars {
+		price := 100 + float64(i%20)
+		bars[i] = backtest.Bar{Date: start.AddDate(0, 0, i), Open: price, High: price, Low: price, Close: price, Volume: 1000}
+	}
+
+	tests := []struct {
+		name     string
+		params   map[string]float64
+		invalid  bool
+		runFails bool
+	}{
+		{"defaults", map[string]float64{}, false, false},
+		{"longer than the bars", map[string]float64{"windowSize": 500}, false, true},
+		{"above the largest period", map[string]float64{"windowSize": 1e9}, true, false},
+		{"RSI above the largest period", map[string]float64{"rsiPeriod": 20000}, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := map[string]float64{"buyThreshold": 0.95, "sellThreshold": 1.05}
+			for name, value := range test.params {
+				params[name] = value
+			}
+
+			strategy, _, err := Build("momentumRSI", params)
+			if test.invalid {
+				if !errors.Is(err, ErrInvalidParam) {
+					t.Fatalf("Build error = %v, want ErrInvalidParam", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+
+			_, err = backtest.Run(context.Background(), bars, strategy, backtest.Config{Symbol: "TEST", InitialCash: 1000000})
+			if test.runFails != (err != nil) {
+				t.Fatalf("Run error = %v, want failure %t", err, test.runFails)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidWindows = errors.New("in-sample and out-of-sample windows must be positive and fit the bars")
+
+type WindowMode string
+
+const (
+	// Rolling windows keep a fixed in-sample length that slides forward.
+	Rolling WindowMode = "rolling"
+	// Anchored windows always start in-sample at the first bar and grow.
+	Anchored WindowMode = "anchored"
+)
+
+func ParseWindowMode(value string) (WindowMode, error) {
+	switch WindowMode(value) {
+	case "", Rolling:
+		return Rolling, nil
+	case Anchored:
+		return Anchored, nil
+	}
+	return "", fmt.Errorf("unknown window mode %q", value)
+}
+
+type WalkForwardConfig struct {
+	// Optimize is the search run on every in-sample window; its Config is
+	// the base backtest configuration.
+	Optimize    OptimizeConfig
+	InSample    int
+	OutOfSample int
+	Mode        WindowMode
+	// Progress, when set, is called after every finished window.
+	Progress func(done int, total int)
+}
+
+type WalkForwardWindow struct {
+	InSampleStart    time.Time          `json:"inSampleStart"`
+	InSampleEnd      time.Time          `json:"inSampleEnd"`
+	OutOfSampleStart time.Time          `json:"outOfSampleStart"`
+	OutOfSampleEnd   time.Time          `json:"outOfSampleEnd"`
+	Params           map[string]float64 `json:"params"`
+	InSampleScore    float64            `json:"inSampleScore"`
+	OutOfSampleScore float64            `json:"outOfSampleScore"`
+	InSample         Metrics            `json:"inSample"`
+	OutOfSample      Metrics            `json:"outOfSample"`
+	Error            string             `json:"error,omitempty"`
+}
+
+// Degradation compares how the optimised parameters did out of sample with
+// how they did on the data they were fitted to. Efficiency is the mean
+// out-of-sample CAGR over the mean in-sample CAGR; near or above 1 means the
+// edge carried over, near 0 or negative that it was mostly curve fitting.
+type Degradation struct {
+	InSampleScore     float64 `json:"inSampleScore"`
+	OutOfSampleScore  float64 `json:"outOfSampleScore"`
+	InSampleCAGR      float64 `json:"inSampleCagr"`
+	OutOfSampleCAGR   float64 `json:"outOfSampleCagr"`
+	InSampleSharpe    float64 `json:"inSampleSharpe"`
+	OutOfSampleSharpe float64 `json:"outOfSampleSharpe"`
+	Efficiency        float64 `json:"efficiency"`
+	ProfitableWindows int     `json:"profitableWindows"`
+}
+
+type WalkForwardResult struct {
+	Mode        WindowMode          `json:"mode"`
+	Objective   Objective           `json:"objective"`
+	InitialCash float64             `json:"initialCash"`
+	FinalEquity float64             `json:"finalEquity"`
+	Windows     []WalkForwardWindow `json:"windows"`
+	Degradation Degradation         `json:"degradation"`
+	Metrics     Metrics             `json:"metrics"`
+	Trades      []Trade             `json:"trades"`
+	Equity      []EquityPoint       `json:"equity"`
+}
+
+// WalkForward optimises on each in-sample window and trades the winning
+// parameters on the out-of-sample window right after it. The out-of-sample
+// runs see their in-sample bars as warm-up only. Each one liquidates at the
+// close of its last bar, paying the exit costs, and the next starts flat with
+// the cash that left, so the stitched equity curve is what re-optimising on
+// schedule would have earned. A window without a feasible parameter set stays
+// in cash.
+func WalkForward(ctx context.Context, bars []Bar, factory StrategyFactory, config WalkForwardConfig) (WalkForwardResult, error) {
+	windows, err := walkForwardWindows(len(bars), config)
+	if err != nil {
+		return WalkForwardResult{}, err
+	}
+
+	base := config.Optimize.Config
+	result := WalkForwardResult{
+		Mode:        config.Mode,
+		Objective:   config.Optimize.Objective,
+		InitialCash: base.InitialCash,
+	}
+
+	cash := base.InitialCash
+	var inSampleScore, outOfSampleScore, inSampleCAGR, outOfSampleCAGR, inSampleSharpe, outOfSampleSharpe float64
+
+	for n, w := range windows {
+		window := WalkForwardWindow{
+			InSampleStart:    bars[w.inStart].Date,
+			InSampleEnd:      bars[w.inEnd-1].Date,
+			OutOfSampleStart: bars[w.inEnd].Date,
+			OutOfSampleEnd:   bars[w.outEnd-1].Date,
+		}
+
+		search := config.Optimize
+		search.Progress = nil
+		optimized, err := Optimize(ctx, bars[w.inStart:w.inEnd], factory, search)
+		if err != nil {
+			return WalkForwardResult{}, err
+		}
+
+		outOfSample := bars[w.inStart:w.outEnd]
+		best, ok := optimized.Best()
+		if ok {
+			window.Params = best.Params
+			window.InSample = best.Metrics
+			window.InSampleScore = best.Score
+
+			run := base
+			run.InitialCash = cash
+			run.WarmUp = w.inEnd - w.inStart
+			run.Liquidate = true
+			run.Progress = nil
+
+			segment, err := runSegment(ctx, outOfSample, factory, best.Params, run)
+			if err == nil {
+				window.OutOfSample = segment.Metrics
+				window.OutOfSampleScore = config.Optimize.Objective.Score(segment.Metrics)
+				result.Trades = append(result.Trades, segment.Trades...)
+				result.Equity = append(result.Equity, segment.Equity...)
+				cash = segment.FinalEquity
+			} else {
+				window.Error = err.Error()
+			}
+		} else {
+			window.Error = "no feasible parameters in sample"
+		}
+
+		if window.Params == nil || window.Error != "" {
+			for _, bar := range bars[w.inEnd:w.outEnd] {
+				result.Equity = append(result.Equity, EquityPoint{Date: bar.Date, Cash: cash, Equity: cash})
+			}
+		}
+
+		inSampleScore += window.InSampleScore
+		outOfSampleScore += window.OutOfSampleScore
+		inSampleCAGR += window.InSample.CAGR
+		outOfSampleCAGR += window.OutOfSample.CAGR
+		inSampleSharpe += window.InSample.Sharpe
+		outOfSampleSharpe += window.OutOfSample.Sharpe
+		if window.OutOfSample.TotalReturn > 0 {
+			result.Degradation.ProfitableWindows++
+		}
+
+		result.Windows = append(result.Windows, window)
+		if config.Progress != nil {
+			config.Progress(n+1, len(windows))
+		}
+	}
+
+	count := float64(len(windows))
+	result.Degradation.InSampleScore = inSampleScore / count
+	result.Degradation.OutOfSampleScore = outOfSampleScore / count
+	result.Degradation.InSampleCAGR = inSampleCAGR / count
+	result.Degradation.OutOfSampleCAGR = outOfSampleCAGR / count
+	result.Degradation.InSampleSharpe = inSampleSharpe / count
+	result.Degradation.OutOfSampleSharpe = outOfSampleSharpe / count
+	if result.Degradation.InSampleCAGR != 0 {
+		result.Degradation.Efficiency = result.Degradation.OutOfSampleCAGR / result.Degradation.InSampleCAGR
+	}
+
+	result.FinalEquity = cash
+	result.Metrics = ComputeMetrics(result.Equity, result.Trades, base.RiskFreeRate)
+
+	return result, nil
+}
+
+// runSegment builds the strategy for params and runs it out of sample. Like
+// runTrial it turns a panic in the strategy into an error, so a bad parameter
+// set fails its window instead of the whole walk-forward.
+func runSegment(ctx context.Context, bars []Bar, factory StrategyFactory, params map[string]float64, config Config) (result Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = Result{}, fmt.Errorf("%v", r)
+		}
+	}()
+
+	strategy, err := factory(params)
+	if err != nil {
+		return Result{}, err
+	}
+	return Run(ctx, bars, strategy, config)
+}
+
+type window struct {
+	inStart int
+	inEnd   int
+	outEnd  int
+}
+
+// walkForwardWindows lays the windows over the bar indices. The last
+// out-of-sample window may be shorter than the others.
+func walkForwardWindows(bars int, config WalkForwardConfig) ([]window, error) {
+	if config.InSample <= 0 || config.OutOfSample <= 0 || config.InSample >= bars {
+		return nil, ErrInvalidWindows
+	}
+
+	var windows []window
+	for inEnd := config.InSample; inEnd < bars; inEnd += config.OutOfSample {
+		inStart := inEnd - config.InSample
+		if config.Mode == Anchored {
+			inStart = 0
+		}
+
+		outEnd := inEnd + config.OutOfSample
+		if outEnd > bars {
+			outEnd = bars
+		}
+
+		windows = append(windows, window{inStart: inStart, inEnd: inEnd, outEnd: outEnd})
+	}
+
+	return windows, nil
+}
@@ -0,0 +1,76 @@
+// Package broker is the boundary between trading code and whoever executes
+// its orders. The paper trading engine is one Broker; Client talks to a
+// broker over HTTP and MockServer serves that API on top of a paper engine,
+// so integration tests can run against a real HTTP round trip.
+package broker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"id/projects/market-data/paper"
+)
+
+// Broker places and manages orders for an account. Orders, positions and
+// reports use the paper trading types so code written against the paper
+// engine moves to a real broker unchanged.
+type Broker interface {
+	Place(ctx context.Context, order paper.Order) (paper.Order, error)
+	Cancel(ctx context.Context, id string) (paper.Order, error)
+	Amend(ctx context.Context, id string, amendment paper.Amendment) (paper.Order, error)
+	Positions(ctx context.Context, accountID string) ([]paper.Position, error)
+	Balance(ctx context.Context, accountID string) (paper.Balance, error)
+	// Executions streams execution reports until ctx is cancelled, when
+	// the channel is closed.
+	Executions(ctx context.Context) (<-chan paper.ExecutionReport, error)
+}
+
+var (
+	_ Broker = (*paper.Engine)(nil)
+	_ Broker = (*Client)(nil)
+)
+
+// errorCodes names the errors that cross the HTTP API so Client can hand
+// back the same sentinel errors the engine returns
+var errorCodes = []struct {
+	err    error
+	code   string
+	status int
+}{
+	{paper.ErrAccountNotFound, "account_not_found", http.StatusNotFound},
+	{paper.ErrOrderNotFound, "order_not_found", http.StatusNotFound},
+	{paper.ErrOrderClosed, "order_closed", http.StatusConflict},
+	{paper.ErrInvalidOrder, "invalid_order", http.StatusBadRequest},
+	{paper.ErrRejected, "rejected", http.StatusUnprocessableEntity},
+	{paper.ErrInvalidCash, "invalid_cash", http.StatusBadRequest},
+}
+
+// Error is an error answered by a broker's HTTP API.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code,omitempty"`
+	Message    string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	for _, known := range errorCodes {
+		if known.code == e.Code {
+			return known.err
+		}
+	}
+	return nil
+}
+
+func toError(err error) *Error {
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			return &Error{StatusCode: known.status, Code: known.code, Message: err.Error()}
+		}
+	}
+	return &Error{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+}
@@ -0,0 +1,161 @@
+package broker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"id/projects/market-data/paper"
+)
+
+// Client is a Broker reached over the HTTP API that MockServer serves.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient talks to the broker at baseURL, using http.DefaultClient when
+// httpClient is nil.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), http: httpClient}
+}
+
+func (c *Client) Place(ctx context.Context, order paper.Order) (paper.Order, error) {
+	var placed paper.Order
+	err := c.do(ctx, http.MethodPost, "/orders", order, &placed)
+	return placed, err
+}
+
+func (c *Client) Cancel(ctx context.Context, id string) (paper.Order, error) {
+	var cancelled paper.Order
+	err := c.do(ctx, http.MethodDelete, "/orders/"+url.PathEscape(id), nil, &cancelled)
+	return cancelled, err
+}
+
+func (c *Client) Amend(ctx context.Context, id string, amendment paper.Amendment) (paper.Order, error) {
+	var amended paper.Order
+	err := c.do(ctx, http.MethodPatch, "/orders/"+url.PathEscape(id), amendment, &amended)
+	return amended, err
+}
+
+func (c *Client) Positions(ctx context.Context, accountID string) ([]paper.Position, error) {
+	var positions []paper.Position
+	err := c.do(ctx, http.MethodGet, "/accounts/"+url.PathEscape(accountID)+"/positions", nil, &positions)
+	return positions, err
+}
+
+func (c *Client) Balance(ctx context.Context, accountID string) (paper.Balance, error) {
+	var balance paper.Balance
+	err := c.do(ctx, http.MethodGet, "/accounts/"+url.PathEscape(accountID)+"/balance", nil, &balance)
+	return balance, err
+}
+
+// Executions reads the newline-delimited JSON report stream. The returned
+// channel is subscribed by the time Executions returns.
+func (c *Client) Executions(ctx context.Context) (<-chan paper.ExecutionReport, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/executions", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+
+	reports := make(chan paper.ExecutionReport)
+	go func() {
+		defer close(reports)
+		defer resp.Body.Close()
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var report paper.ExecutionReport
+			if err := decoder.Decode(&report); err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					log.Printf("broker: execution reports: %v", err)
+				}
+				return
+			}
+
+			select {
+			case reports <- report:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return reports, nil
+}
+
+// CreateAccount opens an account on a MockServer; real brokers open
+// accounts elsewhere, so it is not part of Broker.
+func (c *Client) CreateAccount(ctx context.Context, name string, cash float64) (paper.Account, error) {
+	var account paper.Account
+	err := c.do(ctx, http.MethodPost, "/accounts", accountRequest{Name: name, Cash: cash}, &account)
+	return account, err
+}
+
+// SetQuote sets the quote a MockServer matches orders against.
+func (c *Client) SetQuote(ctx context.Context, quote paper.Quote) error {
+	return c.do(ctx, http.MethodPut, "/quotes/"+url.PathEscape(quote.Symbol), quote, nil)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+	if result == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("broker: decode %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Message == "" {
+		apiErr.Message = fmt.Sprintf("broker: %s", resp.Status)
+	}
+	return apiErr
+}
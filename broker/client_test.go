@@ -0,0 +1,221 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"id/projects/market-data/backtest"
+	"id/projects/market-data/paper"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	mock, err := NewMockServer(backtest.Costs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(mock)
+	t.Cleanup(server.Close)
+	return NewClient(server.URL, server.Client())
+}
+
+func newTestAccount(t *testing.T, client *Client, cash float64) string {
+	t.Helper()
+
+	account, err := client.CreateAccount(context.Background(), "test", cash)
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	return account.ID
+}
+
+func TestClientRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+	accountID := newTestAccount(t, client, 100000)
+
+	if err := client.SetQuote(ctx, paper.Quote{Symbol: "BBCA", Bid: 99, Ask: 100, Last: 100}); err != nil {
+		t.Fatalf("SetQuote: %v", err)
+	}
+
+	bought, err := client.Place(ctx, paper.Order{AccountID: accountID, Symbol: "BBCA", Side: backtest.Buy, Type: paper.Market, TimeInForce: paper.GTC, Quantity: 100})
+	if err != nil {
+		t.Fatalf("Place: %v", err)
+	}
+	if bought.ID == "" || bought.Status != paper.Filled || bought.AvgFillPrice != 100 {
+		t.Fatalf("market order = %q %s @ %g, want filled @ 100", bought.ID, bought.Status, bought.AvgFillPrice)
+	}
+
+	limit, err := client.Place(ctx, paper.Order{AccountID: accountID, Symbol: "BBCA", Side: backtest.Sell, Type: paper.Limit, TimeInForce: paper.GTC, Quantity: 50, LimitPrice: 110})
+	if err != nil {
+		t.Fatalf("Place: %v", err)
+	}
+	if limit.Status != paper.New {
+		t.Fatalf("limit order = %s, want new", limit.Status)
+	}
+
+	amended, err := client.Amend(ctx, limit.ID, paper.Amendment{LimitPrice: 105})
+	if err != nil {
+		t.Fatalf("Amend: %v", err)
+	}
+	if amended.LimitPrice != 105 || amended.Quantity != 50 {
+		t.Errorf("amended = %g @ %g, want 50 @ 105", amended.Quantity, amended.LimitPrice)
+	}
+
+	if err := client.SetQuote(ctx, paper.Quote{Symbol: "BBCA", Bid: 106, Ask: 107, Last: 106}); err != nil {
+		t.Fatalf("SetQuote: %v", err)
+	}
+
+	positions, err := client.Positions(ctx, accountID)
+	if err != nil {
+		t.Fatalf("Positions: %v", err)
+	}
+	if len(positions) != 1 || positions[0].Symbol != "BBCA" || positions[0].Quantity != 50 {
+		t.Errorf("positions = %+v, want 50 BBCA", positions)
+	}
+
+	balance, err := client.Balance(ctx, accountID)
+	if err != nil {
+		t.Fatalf("Balance: %v", err)
+	}
+	if want := 100000 - 100*100 + 50*106.0; balance.Cash != want {
+		t.Errorf("cash = %g, want %g", balance.Cash, want)
+	}
+
+	open, err := client.Place(ctx, paper.Order{AccountID: accountID, Symbol: "BBCA", Side: backtest.Buy, Type: paper.Limit, TimeInForce: paper.GTC, Quantity: 10, LimitPrice: 90})
+	if err != nil {
+		t.Fatalf("Place: %v", err)
+	}
+	cancelled, err := client.Cancel(ctx, open.ID)
+	if err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if cancelled.Status != paper.Cancelled {
+		t.Errorf("cancelled order = %s, want cancelled", cancelled.Status)
+	}
+}
+
+func TestClientExecutions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := newTestClient(t)
+	accountID := newTestAccount(t, client, 100000)
+
+	reports, err := client.Executions(ctx)
+	if err != nil {
+		t.Fatalf("Executions: %v", err)
+	}
+
+	if err := client.SetQuote(ctx, paper.Quote{Symbol: "TLKM", Bid: 99, Ask: 100}); err != nil {
+		t.Fatalf("SetQuote: %v", err)
+	}
+	placed, err := client.Place(ctx, paper.Order{AccountID: accountID, Symbol: "TLKM", Side: backtest.Buy, Type: paper.Market, TimeInForce: paper.GTC, Quantity: 100})
+	if err != nil {
+		t.Fatalf("Place: %v", err)
+	}
+
+	for _, want := range []paper.ReportType{paper.ReportNew, paper.ReportTrade} {
+		select {
+		case report, ok := <-reports:
+			if !ok {
+				t.Fatalf("stream closed before the %s report", want)
+			}
+			if report.Type != want || report.Order.ID != placed.ID {
+				t.Fatalf("report = %s for %q, want %s for %q", report.Type, report.Order.ID, want, placed.ID)
+			}
+			if want == paper.ReportTrade && (report.Execution == nil || report.Execution.Quantity != 100) {
+				t.Errorf("trade execution = %+v, want 100 shares", report.Execution)
+			}
+		case <-ctx.Done():
+			t.Fatalf("no %s report: %v", want, ctx.Err())
+		}
+	}
+
+	cancel()
+	for range reports {
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+	accountID := newTestAccount(t, client, 1000)
+
+	if err := client.SetQuote(ctx, paper.Quote{Symbol: "ASII", Bid: 9, Ask: 10}); err != nil {
+		t.Fatalf("SetQuote: %v", err)
+	}
+	open, err := client.Place(ctx, paper.Order{AccountID: accountID, Symbol: "ASII", Side: backtest.Buy, Type: paper.Limit, TimeInForce: paper.GTC, Quantity: 10, LimitPrice: 5})
+	if err != nil {
+		t.Fatalf("Place: %v", err)
+	}
+	closed, err := client.Place(ctx, paper.Order{AccountID: accountID, Symbol: "ASII", Side: backtest.Buy, Type: paper.Limit, TimeInForce: paper.GTC, Quantity: 10, LimitPrice: 5})
+	if err != nil {
+		t.Fatalf("Place: %v", err)
+	}
+	if _, err := client.Cancel(ctx, closed.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		call   func() error
+		want   error
+		status int
+	}{
+		{"unknown account", func() error {
+			_, err := client.Balance(ctx, "missing")
+			return err
+		}, paper.ErrAccountNotFound, http.StatusNotFound},
+		{"unknown order", func() error {
+			_, err := client.Cancel(ctx, "missing")
+			return err
+		}, paper.ErrOrderNotFound, http.StatusNotFound},
+		{"closed order", func() error {
+			_, err := client.Cancel(ctx, closed.ID)
+			return err
+		}, paper.ErrOrderClosed, http.StatusConflict},
+		{"invalid order", func() error {
+			_, err := client.Place(ctx, paper.Order{AccountID: accountID, Symbol: "ASII", Side: "hold", Type: paper.Market, TimeInForce: paper.GTC, Quantity: 1})
+			return err
+		}, paper.ErrInvalidOrder, http.StatusBadRequest},
+		{"rejected amendment", func() error {
+			_, err := client.Amend(ctx, open.ID, paper.Amendment{Quantity: 1000})
+			return err
+		}, paper.ErrRejected, http.StatusUnprocessableEntity},
+		{"invalid cash", func() error {
+			_, err := client.CreateAccount(ctx, "broke", 0)
+			return err
+		}, paper.ErrInvalidCash, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.call()
+			if !errors.Is(err, test.want) {
+				t.Fatalf("error = %v, want %v", err, test.want)
+			}
+
+			var apiErr *Error
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != test.status {
+				t.Fatalf("error = %#v, want an *Error with status %d", err, test.status)
+			}
+		})
+	}
+
+	t.Run("unknown code", func(t *testing.T) {
+		err := &Error{Code: "teapot", Message: "short and stout"}
+		if err.Unwrap() != nil {
+			t.Errorf("Unwrap = %v, want nil", err.Unwrap())
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package broker
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"id/projects/market-data/backtest"
+	"id/projects/market-data/paper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type accountRequest struct {
+	Name string  `json:"name"`
+	Cash float64 `json:"cash"`
+}
+
+// MockServer serves the broker HTTP API from an in-memory paper engine whose
+// quotes are set through the API, so integration tests decide every price.
+// Start it with httptest.NewServer and talk to it with Client.
+type MockServer struct {
+	engine  *paper.Engine
+	feed    *paper.ManualFeed
+	handler http.Handler
+}
+
+func NewMockServer(costs backtest.Costs) (*MockServer, error) {
+	feed := paper.NewManualFeed()
+	engine, err := paper.NewEngine(feed, costs, "")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &MockServer{engine: engine, feed: feed}
+
+	r := gin.New()
+	r.POST("/accounts", s.createAccount)
+	r.GET("/accounts/:id/positions", s.positions)
+	r.GET("/accounts/:id/balance", s.balance)
+	r.POST("/orders", s.place)
+	r.GET("/orders/:id", s.order)
+	r.PATCH("/orders/:id", s.amend)
+	r.DELETE("/orders/:id", s.cancel)
+	r.PUT("/quotes/:symbol", s.setQuote)
+	r.GET("/executions", s.executions)
+	s.handler = r
+
+	return s, nil
+}
+
+func (s *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Engine is the paper engine behind the server, for tests that want to
+// inspect it directly.
+func (s *MockServer) Engine() *paper.Engine {
+	return s.engine
+}
+
+func (s *MockServer) createAccount(c *gin.Context) {
+	var req accountRequest
+	if !bind(c, &req) {
+		return
+	}
+
+	account, err := s.engine.CreateAccount(req.Name, req.Cash)
+	respond(c, http.StatusCreated, account, err)
+}
+
+func (s *MockServer) positions(c *gin.Context) {
+	positions, err := s.engine.Positions(c.Request.Context(), c.Param("id"))
+	respond(c, http.StatusOK, positions, err)
+}
+
+func (s *MockServer) balance(c *gin.Context) {
+	balance, err := s.engine.Balance(c.Request.Context(), c.Param("id"))
+	respond(c, http.StatusOK, balance, err)
+}
+
+func (s *MockServer) place(c *gin.Context) {
+	var order paper.Order
+	if !bind(c, &order) {
+		return
+	}
+
+	order, err := s.engine.Place(c.Request.Context(), order)
+	respond(c, http.StatusCreated, order, err)
+}
+
+func (s *MockServer) order(c *gin.Context) {
+	order, err := s.engine.Order(c.Param("id"))
+	respond(c, http.StatusOK, order, err)
+}
+
+func (s *MockServer) amend(c *gin.Context) {
+	var amendment paper.Amendment
+	if !bind(c, &amendment) {
+		return
+	}
+
+	order, err := s.engine.Amend(c.Request.Context(), c.Param("id"), amendment)
+	respond(c, http.StatusOK, order, err)
+}
+
+func (s *MockServer) cancel(c *gin.Context) {
+	order, err := s.engine.Cancel(c.Request.Context(), c.Param("id"))
+	respond(c, http.StatusOK, order, err)
+}
+
+// setQuote replaces the quote of a symbol and matches the open orders
+// against it before answering, so the fills are visible right after.
+func (s *MockServer) setQuote(c *gin.Context) {
+	var quote paper.Quote
+	if !bind(c, &quote) {
+		return
+	}
+	quote.Symbol = c.Param("symbol")
+
+	s.feed.Set(quote)
+	if err := s.engine.Match(c.Request.Context()); err != nil {
+		respond(c, http.StatusOK, nil, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// executions streams execution reports as newline-delimited JSON until the
+// client goes away.
+func (s *MockServer) executions(c *gin.Context) {
+	reports, err := s.engine.Executions(c.Request.Context())
+	if err != nil {
+		respond(c, http.StatusOK, nil, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	encoder := json.NewEncoder(c.Writer)
+	for report := range reports {
+		if err := encoder.Encode(report); err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+func bind(c *gin.Context, v interface{}) bool {
+	if err := c.ShouldBindJSON(v); err != nil {
+		c.JSON(http.StatusBadRequest, &Error{Code: "invalid_request", Message: err.Error()})
+		return false
+	}
+	return true
+}
+
+func respond(c *gin.Context, status int, v interface{}, err error) {
+	if err != nil {
+		apiErr := toError(err)
+		c.JSON(apiErr.StatusCode, apiErr)
+		return
+	}
+	c.JSON(status, v)
+}
@@ -1,9 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"id/projects/market-data/helper"
 	"id/projects/market-data/models"
+	"id/projects/market-data/services"
 	"net/http"
 	"sort"
 	"strings"
@@ -16,14 +18,16 @@ import (
 )
 
 type analyzeController struct {
+	newsSentimentService services.NewsSentimentService
 }
 
-func NewAnalyzeController() *analyzeController {
-	return &analyzeController{}
+func NewAnalyzeController(newsSentimentService services.NewsSentimentService) *analyzeController {
+	return &analyzeController{newsSentimentService}
 }
 
 const defaultDate = "2006-01-02"
 const daysToLookBack = 50
+const defaultSentimentWeight = 1.0
 
 type ByRecommendation []*models.RecommendationResponse
 
@@ -159,62 +163,34 @@ func (h *analyzeController) GetAnalyze(c *gin.Context) {
 	}
 
 	// Determine recommendation based on the number of confirmations for buy and sell signals
-	var recommendation string
-	var targetBuy, targetSell float64
+	recommendation, targetBuy, targetSell, explanation := recommend(buyCount, sellCount, latestClose, latestSMA20)
 
-	// Default values for buy and sell targets
-	targetBuy = latestClose
-	targetSell = latestClose
+	var sentimentVote *models.SentimentVote
+	if req.Sentiment {
+		vote, buyVote, sellVote, err := h.sentimentVote(req.Symbol, req.SentimentModel, req.SentimentWeight, req.HalfLife)
+		if err != nil {
+			response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
 
-	if buyCount == 4 && sellCount == 0 {
-		recommendation = "STRONG BUY"
-		targetBuy = latestClose + (latestClose-latestSMA20)*0.1 // 10% above SMA20
-		targetSell = 0                                          // no sell recommendation for STRONG BUY
-	} else if buyCount >= 3 && sellCount <= 1 {
-		recommendation = "BUY"
-		targetBuy = latestClose + (latestClose-latestSMA20)*0.05  // 5% above SMA20
-		targetSell = latestClose - (latestSMA20-latestClose)*0.03 // 3% below SMA20
-	} else if buyCount == 2 && sellCount == 2 {
-		recommendation = "HOLD"
-		targetBuy = 0  // no buy recommendation for HOLD
-		targetSell = 0 // no sell recommendation for HOLD
-	} else if sellCount >= 3 && buyCount <= 1 {
-		recommendation = "SELL"
-		targetBuy = 0                                             // no buy recommendation for SELL
-		targetSell = latestClose - (latestSMA20-latestClose)*0.05 // 5% below SMA20
-	} else if sellCount == 4 && buyCount == 0 {
-		recommendation = "STRONG SELL"
-		targetBuy = 0                                            // no buy recommendation for STRONG SELL
-		targetSell = latestClose - (latestClose-latestSMA20)*0.1 // 10% below SMA20
-	} else {
-		recommendation = "NO RECOMMENDATION"
+		vote.TechnicalRecommendation = recommendation
+		recommendation, targetBuy, targetSell, explanation = recommendWithSentiment(float64(buyCount)+buyVote, float64(sellCount)+sellVote, latestClose, latestSMA20)
+		vote.Recommendation = recommendation
+		vote.Changed = vote.Recommendation != vote.TechnicalRecommendation
+		sentimentVote = vote
 	}
 
 	// Calculate stop-loss order price based on the most recent closing price
 	stopLoss := latestClose * 0.95 // 5% below closing price
 
-	// Explain the recommendation based on the number of confirmations for buy and sell signals
-	var explanation string
-	if buyCount == 4 && sellCount == 0 {
-		explanation = "The stock is showing very strong buy signals from all indicators, and there are no sell signals. This is a good opportunity to buy the stock with a target price of " + fmt.Sprintf("%.2f", targetBuy) + "."
-	} else if buyCount >= 3 && sellCount <= 1 {
-		explanation = "The stock is showing strong buy signals from most indicators, and there are very few sell signals. This is a good opportunity to buy the stock with a target price of " + fmt.Sprintf("%.2f", targetBuy) + "."
-	} else if buyCount == 2 && sellCount == 2 {
-		explanation = "The stock is showing mixed signals from the indicators, and there are no clear buy or sell signals. It may be best to hold off on buying or selling the stock at this time."
-	} else if sellCount >= 3 && buyCount <= 1 {
-		explanation = "The stock is showing strong sell signals from most indicators, and there are very few buy signals. It may be best to sell the stock with a target price of " + fmt.Sprintf("%.2f", targetSell) + "."
-	} else if sellCount == 4 && buyCount == 0 {
-		explanation = "The stock is showing very strong sell signals from all indicators, and there are no buy signals. It may be best to sell the stock with a target price of " + fmt.Sprintf("%.2f", targetSell) + "."
-	} else {
-		explanation = "There is no clear recommendation for this stock based on the current indicators. It may be best to hold off on buying or selling the stock at this time."
-	}
-
 	respFormatter := models.AnalyzeResponse{}
 	respFormatter.Symbol = req.Symbol
 	respFormatter.StartDate = start.Format(defaultDate)
 	respFormatter.EndDate = end.Format(defaultDate)
 	respFormatter.Recommendation = recommendation
 	respFormatter.Explanation = explanation
+	respFormatter.Sentiment = sentimentVote
 
 	quoteFormatter := models.AnalyzeQuote{}
 	quoteFormatter.BuyTarget = targetBuy
@@ -356,51 +332,22 @@ func (h *analyzeController) GetReceommendation(c *gin.Context) {
 		}
 
 		// Determine recommendation based on the number of confirmations for buy and sell signals
-		var recommendation string
-		var targetBuy, targetSell float64
-
-		// Default values for buy and sell targets
-		targetBuy = latestClose
-		targetSell = latestClose
-
-		if buyCount == 4 && sellCount == 0 {
-			recommendation = "STRONG BUY"
-			targetBuy = latestClose + (latestClose-latestSMA20)*0.1 // 10% above SMA20
-			targetSell = 0                                          // no sell recommendation for STRONG BUY
-		} else if buyCount >= 3 && sellCount <= 1 {
-			recommendation = "BUY"
-			targetBuy = latestClose + (latestClose-latestSMA20)*0.05  // 5% above SMA20
-			targetSell = latestClose - (latestSMA20-latestClose)*0.03 // 3% below SMA20
-		} else if buyCount == 2 && sellCount == 2 {
-			recommendation = "HOLD"
-			targetBuy = 0  // no buy recommendation for HOLD
-			targetSell = 0 // no sell recommendation for HOLD
-		} else if sellCount >= 3 && buyCount <= 1 {
-			recommendation = "SELL"
-			targetBuy = 0                                             // no buy recommendation for SELL
-			targetSell = latestClose - (latestSMA20-latestClose)*0.05 // 5% below SMA20
-		} else if sellCount == 4 && buyCount == 0 {
-			recommendation = "STRONG SELL"
-			targetBuy = 0                                            // no buy recommendation for STRONG SELL
-			targetSell = latestClose - (latestClose-latestSMA20)*0.1 // 10% below SMA20
-		} else {
-			recommendation = "NO RECOMMENDATION"
-		}
-
-		// Explain the recommendation based on the number of confirmations for buy and sell signals
-		var explanation string
-		if buyCount == 4 && sellCount == 0 {
-			explanation = "The stock is showing very strong buy signals from all indicators, and there are no sell signals. This is a good opportunity to buy the stock with a target price of " + fmt.Sprintf("%.2f", targetBuy) + "."
-		} else if buyCount >= 3 && sellCount <= 1 {
-			explanation = "The stock is showing strong buy signals from most indicators, and there are very few sell signals. This is a good opportunity to buy the stock with a target price of " + fmt.Sprintf("%.2f", targetBuy) + "."
-		} else if buyCount == 2 && sellCount == 2 {
-			explanation = "The stock is showing mixed signals from the indicators, and there are no clear buy or sell signals. It may be best to hold off on buying or selling the stock at this time."
-		} else if sellCount >= 3 && buyCount <= 1 {
-			explanation = "The stock is showing strong sell signals from most indicators, and there are very few buy signals. It may be best to sell the stock with a target price of " + fmt.Sprintf("%.2f", targetSell) + "."
-		} else if sellCount == 4 && buyCount == 0 {
-			explanation = "The stock is showing very strong sell signals from all indicators, and there are no buy signals. It may be best to sell the stock with a target price of " + fmt.Sprintf("%.2f", targetSell) + "."
-		} else {
-			explanation = "There is no clear recommendation for this stock based on the current indicators. It may be best to hold off on buying or selling the stock at this time."
+		recommendation, targetBuy, targetSell, explanation := recommend(buyCount, sellCount, latestClose, latestSMA20)
+
+		var sentimentVote *models.SentimentVote
+		if req.Sentiment {
+			vote, buyVote, sellVote, err := h.sentimentVote(strings.TrimSpace(symbol), req.SentimentModel, req.SentimentWeight, req.HalfLife)
+			if err != nil {
+				response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+				c.JSON(http.StatusOK, response)
+				return
+			}
+
+			vote.TechnicalRecommendation = recommendation
+			recommendation, targetBuy, targetSell, explanation = recommendWithSentiment(float64(buyCount)+buyVote, float64(sellCount)+sellVote, latestClose, latestSMA20)
+			vote.Recommendation = recommendation
+			vote.Changed = vote.Recommendation != vote.TechnicalRecommendation
+			sentimentVote = vote
 		}
 
 		// Create Stock object and add to stocks slice
@@ -411,6 +358,7 @@ func (h *analyzeController) GetReceommendation(c *gin.Context) {
 			TargetBuy:      targetBuy,
 			TargetSell:     targetSell,
 			Explanation:    explanation,
+			Sentiment:      sentimentVote,
 		}
 		stocks = append(stocks, temp)
 	}
@@ -580,6 +528,153 @@ func (h *analyzeController) GetFundamental(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// recommend turns the number of buy and sell confirmations into a
+// recommendation with its buy/sell targets and explanation.
+func recommend(buyCount int, sellCount int, latestClose float64, latestSMA20 float64) (string, float64, float64, string) {
+	var recommendation string
+	var targetBuy, targetSell float64
+
+	// Default values for buy and sell targets
+	targetBuy = latestClose
+	targetSell = latestClose
+
+	if buyCount == 4 && sellCount == 0 {
+		recommendation = "STRONG BUY"
+		targetBuy = latestClose + (latestClose-latestSMA20)*0.1 // 10% above SMA20
+		targetSell = 0                                          // no sell recommendation for STRONG BUY
+	} else if buyCount >= 3 && sellCount <= 1 {
+		recommendation = "BUY"
+		targetBuy = latestClose + (latestClose-latestSMA20)*0.05  // 5% above SMA20
+		targetSell = latestClose - (latestSMA20-latestClose)*0.03 // 3% below SMA20
+	} else if buyCount == 2 && sellCount == 2 {
+		recommendation = "HOLD"
+		targetBuy = 0  // no buy recommendation for HOLD
+		targetSell = 0 // no sell recommendation for HOLD
+	} else if sellCount >= 3 && buyCount <= 1 {
+		recommendation = "SELL"
+		targetBuy = 0                                             // no buy recommendation for SELL
+		targetSell = latestClose - (latestSMA20-latestClose)*0.05 // 5% below SMA20
+	} else if sellCount == 4 && buyCount == 0 {
+		recommendation = "STRONG SELL"
+		targetBuy = 0                                            // no buy recommendation for STRONG SELL
+		targetSell = latestClose - (latestClose-latestSMA20)*0.1 // 10% below SMA20
+	} else {
+		recommendation = "NO RECOMMENDATION"
+	}
+
+	// Explain the recommendation based on the number of confirmations for buy and sell signals
+	var explanation string
+	if buyCount == 4 && sellCount == 0 {
+		explanation = "The stock is showing very strong buy signals from all indicators, and there are no sell signals. This is a good opportunity to buy the stock with a target price of " + fmt.Sprintf("%.2f", targetBuy) + "."
+	} else if buyCount >= 3 && sellCount <= 1 {
+		explanation = "The stock is showing strong buy signals from most indicators, and there are very few sell signals. This is a good opportunity to buy the stock with a target price of " + fmt.Sprintf("%.2f", targetBuy) + "."
+	} else if buyCount == 2 && sellCount == 2 {
+		explanation = "The stock is showing mixed signals from the indicators, and there are no clear buy or sell signals. It may be best to hold off on buying or selling the stock at this time."
+	} else if sellCount >= 3 && buyCount <= 1 {
+		explanation = "The stock is showing strong sell signals from most indicators, and there are very few buy signals. It may be best to sell the stock with a target price of " + fmt.Sprintf("%.2f", targetSell) + "."
+	} else if sellCount == 4 && buyCount == 0 {
+		explanation = "The stock is showing very strong sell signals from all indicators, and there are no buy signals. It may be best to sell the stock with a target price of " + fmt.Sprintf("%.2f", targetSell) + "."
+	} else {
+		explanation = "There is no clear recommendation for this stock based on the current indicators. It may be best to hold off on buying or selling the stock at this time."
+	}
+
+	return recommendation, targetBuy, targetSell, explanation
+}
+
+// recommendWithSentiment is recommend once the sentiment has voted. It looks
+// at the net score, buys minus sells, so the weighted and possibly fractional
+// sentiment vote moves the recommendation along the same scale as the
+// indicators.
+func recommendWithSentiment(buyCount float64, sellCount float64, latestClose float64, latestSMA20 float64) (string, float64, float64, string) {
+	var recommendation, explanation string
+	var targetBuy, targetSell float64
+
+	net := buyCount - sellCount
+
+	switch {
+	case net >= 4:
+		recommendation = "STRONG BUY"
+		targetBuy = latestClose + (latestClose-latestSMA20)*0.1 // 10% above SMA20
+		targetSell = 0                                          // no sell recommendation for STRONG BUY
+		explanation = "The stock is showing very strong buy signals from nearly all indicators, and there are hardly any sell signals. This is a good opportunity to buy the stock with a target price of " + fmt.Sprintf("%.2f", targetBuy) + "."
+	case net >= 2:
+		recommendation = "BUY"
+		targetBuy = latestClose + (latestClose-latestSMA20)*0.05  // 5% above SMA20
+		targetSell = latestClose - (latestSMA20-latestClose)*0.03 // 3% below SMA20
+		explanation = "The stock is showing strong buy signals from most indicators, and there are very few sell signals. This is a good opportunity to buy the stock with a target price of " + fmt.Sprintf("%.2f", targetBuy) + "."
+	case net <= -4:
+		recommendation = "STRONG SELL"
+		targetBuy = 0                                            // no buy recommendation for STRONG SELL
+		targetSell = latestClose - (latestClose-latestSMA20)*0.1 // 10% below SMA20
+		explanation = "The stock is showing very strong sell signals from nearly all indicators, and there are hardly any buy signals. It may be best to sell the stock with a target price of " + fmt.Sprintf("%.2f", targetSell) + "."
+	case net <= -2:
+		recommendation = "SELL"
+		targetBuy = 0                                             // no buy recommendation for SELL
+		targetSell = latestClose - (latestSMA20-latestClose)*0.05 // 5% below SMA20
+		explanation = "The stock is showing strong sell signals from most indicators, and there are very few buy signals. It may be best to sell the stock with a target price of " + fmt.Sprintf("%.2f", targetSell) + "."
+	case net > -1 && net < 1 && buyCount+sellCount > 0:
+		recommendation = "HOLD"
+		targetBuy = 0  // no buy recommendation for HOLD
+		targetSell = 0 // no sell recommendation for HOLD
+		explanation = "The stock is showing mixed signals from the indicators, and there are no clear buy or sell signals. It may be best to hold off on buying or selling the stock at this time."
+	default:
+		recommendation = "NO RECOMMENDATION"
+		targetBuy = latestClose
+		targetSell = latestClose
+		explanation = "There is no clear recommendation for this stock based on the current indicators. It may be best to hold off on buying or selling the stock at this time."
+	}
+
+	return recommendation, targetBuy, targetSell, explanation
+}
+
+// sentimentVote scores the stored news about symbol and turns the decayed
+// sentiment into an extra buy or sell vote worth weight confirmations.
+func (h *analyzeController) sentimentVote(symbol string, model string, weight float64, halfLife string) (*models.SentimentVote, float64, float64, error) {
+	if weight < 0 {
+		return nil, 0, 0, errors.New("sentiment weight must not be negative")
+	}
+	if weight == 0 {
+		weight = defaultSentimentWeight
+	}
+
+	_, model, ok := h.newsSentimentService.Model(model)
+	if !ok {
+		return nil, 0, 0, errors.New("unknown sentiment model")
+	}
+
+	decay, err := h.newsSentimentService.HalfLife(halfLife)
+	if err != nil {
+		return nil, 0, 0, errors.New("invalid half life, should be a duration such as 72h")
+	}
+
+	statistics, _, err := h.newsSentimentService.SymbolSentiment(symbol, model, decay)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	vote := &models.SentimentVote{}
+	vote.Model = model
+	vote.HalfLife = decay.String()
+	vote.Articles = statistics.Count
+	vote.Score = statistics.DecayedScore
+	vote.Label = services.SentimentLabel(statistics.DecayedScore)
+	vote.Weight = weight
+
+	var buyVote, sellVote float64
+	switch vote.Label {
+	case "positive":
+		vote.Vote = "BUY"
+		buyVote = weight
+	case "negative":
+		vote.Vote = "SELL"
+		sellVote = weight
+	default:
+		vote.Vote = "NONE"
+	}
+
+	return vote, buyVote, sellVote, nil
+}
+
 func exponentialMovingAverage(closePrices []float64, alpha float64) float64 {
 	ema := closePrices[0]
 
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"id/projects/market-data/backtest"
+	"id/projects/market-data/helper"
+	"id/projects/market-data/models"
+	"id/projects/market-data/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type corporateActionController struct {
+	corporateActions services.CorporateActionService
+}
+
+func NewCorporateActionController(corporateActions services.CorporateActionService) *corporateActionController {
+	return &corporateActionController{corporateActions}
+}
+
+func (h *corporateActionController) GetCorporateActions(c *gin.Context) {
+	var req models.CorporateActionRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	actions, err := h.corporateActions.Actions(c.Request.Context(), req.Symbol)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Get corporate actions successfully", http.StatusOK, "SUCCESS", actions)
+	c.JSON(http.StatusOK, response)
+}
+
+// SaveCorporateActions stores splits and dividends entered by hand
+func (h *corporateActionController) SaveCorporateActions(c *gin.Context) {
+	var req models.SaveCorporateActionRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	actions := backtest.CorporateActions{Symbol: req.Symbol}
+	for _, split := range req.Splits {
+		date, err := time.Parse(defaultDate, split.Date)
+		if err != nil {
+			response := helper.APIResponse("Invalid split date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		actions.Splits = append(actions.Splits, backtest.Split{Date: date, Ratio: split.Ratio})
+	}
+	for _, dividend := range req.Dividends {
+		date, err := time.Parse(defaultDate, dividend.Date)
+		if err != nil {
+			response := helper.APIResponse("Invalid dividend date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		actions.Dividends = append(actions.Dividends, backtest.Dividend{Date: date, Amount: dividend.Amount})
+	}
+
+	saved, err := h.corporateActions.Save(actions)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Save corporate actions successfully", http.StatusOK, "SUCCESS", saved)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetPriceHistory returns the daily bars of a symbol as raw, split-adjusted
+// or total-return prices
+func (h *corporateActionController) GetPriceHistory(c *gin.Context) {
+	var req models.PriceHistoryRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	end := time.Now()
+	if req.EndDate != "" {
+		var err error
+		end, err = time.Parse(defaultDate, req.EndDate)
+		if err != nil {
+			response := helper.APIResponse("Invalid end date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+	}
+
+	start := end.AddDate(-1, 0, 0)
+	if req.StartDate != "" {
+		var err error
+		start, err = time.Parse(defaultDate, req.StartDate)
+		if err != nil {
+			response := helper.APIResponse("Invalid start date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+	}
+
+	adjustment, err := backtest.ParseAdjustment(req.Adjustment)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	bars, actions, ok := fetchAdjustedBars(c, h.corporateActions, req.Symbol, start, end, adjustment)
+	if !ok {
+		return
+	}
+
+	respFormatter := models.PriceHistoryResponse{}
+	respFormatter.Symbol = req.Symbol
+	respFormatter.StartDate = start.Format(defaultDate)
+	respFormatter.EndDate = end.Format(defaultDate)
+	respFormatter.Adjustment = adjustment
+	respFormatter.Actions = actions.Between(start, end)
+	respFormatter.Bars = bars
+
+	response := helper.APIResponse("Get price history successfully", http.StatusOK, "SUCCESS", respFormatter)
+	c.JSON(http.StatusOK, response)
+}
+
+// fetchAdjustedBars downloads the bars of symbol from start through end with
+// the given adjustment, together with the symbol's corporate actions. Yahoo
+// adjusts relative to the latest bar, so the bars are fetched through today
+// and cut at end after converting. When it fails it has already written the
+// error response.
+func fetchAdjustedBars(c *gin.Context, corporateActions services.CorporateActionService, symbol string, start time.Time, end time.Time, adjustment backtest.Adjustment) ([]backtest.Bar, backtest.CorporateActions, bool) {
+	actions, err := corporateActions.Actions(c.Request.Context(), symbol)
+	if err != nil {
+		response := helper.APIResponse("Failed to retrieve corporate actions: "+err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return nil, backtest.CorporateActions{}, false
+	}
+	actions.Symbol = symbol
+
+	if adjustment == backtest.TotalReturn {
+		bars, ok := fetchBars(c, symbol, start, end)
+		return bars, actions, ok
+	}
+
+	bars, ok := fetchBars(c, symbol, start, time.Now())
+	if !ok {
+		return nil, backtest.CorporateActions{}, false
+	}
+
+	// go-quote's adjusted quotes only take the close from Yahoo's adjusted
+	// close; open, high and low stay split-adjusted
+	split := backtest.ConvertBars(bars, actions, backtest.TotalReturn, backtest.SplitAdjusted)
+	for i := range split {
+		split[i].Open, split[i].High, split[i].Low = bars[i].Open, bars[i].High, bars[i].Low
+	}
+	converted := backtest.ConvertBars(split, actions, backtest.SplitAdjusted, adjustment)
+
+	cut := len(converted)
+	for cut > 0 && converted[cut-1].Date.After(end) {
+		cut--
+	}
+	if cut == 0 {
+		response := helper.APIResponse("Failed to retrieve stock data for "+symbol, http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return nil, backtest.CorporateActions{}, false
+	}
+
+	return converted[:cut], actions, true
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"errors"
+	"id/projects/market-data/helper"
+	"id/projects/market-data/models"
+	"id/projects/market-data/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jobPaths maps each job type to the endpoint that runs it
+var jobPaths = map[string]string{
+	"simulate":    "/api/v1/simulate",
+	"optimize":    "/api/v1/simulate/optimize",
+	"walkforward": "/api/v1/simulate/walkforward",
+	"portfolio":   "/api/v1/simulate/portfolio",
+}
+
+type jobController struct {
+	jobService services.JobService
+}
+
+func NewJobController(jobService services.JobService) *jobController {
+	return &jobController{jobService}
+}
+
+// SubmitJob queues a simulation; its request is what the synchronous
+// endpoint for the job type takes
+func (h *jobController) SubmitJob(c *gin.Context) {
+	var req models.JobRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	job, err := h.jobService.Submit(req.Type, jobPaths[req.Type], req.Request)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, services.ErrQueueFull) {
+			code = http.StatusServiceUnavailable
+		}
+		response := helper.APIResponse(err.Error(), code, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Submit job successfully", http.StatusAccepted, "SUCCESS", job)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *jobController) GetJobs(c *gin.Context) {
+	jobs := h.jobService.List(c.Query("status"))
+
+	response := helper.APIResponse("Get jobs successfully", http.StatusOK, "SUCCESS", jobs)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *jobController) GetJob(c *gin.Context) {
+	job, err := h.jobService.Get(c.Param("id"))
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusNotFound, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Get job successfully", http.StatusOK, "SUCCESS", job)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *jobController) CancelJob(c *gin.Context) {
+	job, err := h.jobService.Cancel(c.Param("id"))
+	if errors.Is(err, services.ErrJobNotFound) {
+		response := helper.APIResponse(err.Error(), http.StatusNotFound, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusConflict, "FAILED", job)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Cancel job successfully", http.StatusOK, "SUCCESS", job)
+	c.JSON(http.StatusOK, response)
+}
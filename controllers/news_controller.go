@@ -1,25 +1,30 @@
 package controllers
 
 import (
+	"fmt"
 	"id/projects/market-data/helper"
 	"id/projects/market-data/models"
 	"id/projects/market-data/services"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/dghubble/sling"
 	"github.com/gin-gonic/gin"
 )
 
+const defaultNewsLimit = 20
+
 type newsController struct {
-	sentimentService services.SentimenService
+	newsStore            services.NewsStore
+	newsSentimentService services.NewsSentimentService
 }
 
-func NewNewsController(sentimentService services.SentimenService) *newsController {
-	return &newsController{sentimentService}
+func NewNewsController(newsStore services.NewsStore, newsSentimentService services.NewsSentimentService) *newsController {
+	return &newsController{newsStore, newsSentimentService}
 }
 
-func (h *newsController) GetSentiment(c *gin.Context) {
-	var req models.QuoteRequest
+func (h *newsController) GetNews(c *gin.Context) {
+	var req models.NewsListRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		errors := helper.FormatValidationError(err)
@@ -30,41 +35,236 @@ func (h *newsController) GetSentiment(c *gin.Context) {
 		return
 	}
 
-	yahooAPI := sling.New().Base("https://finance.yahoo.com/")
-	newsAPIPath := "_finance_api/resource/searchassist"
-	newsAPIParams := &models.NewsAPIParams{
-		SearchTerm: req.Symbol,
+	sentimentService, model, ok := h.newsSentimentService.Model(req.Model)
+	if !ok {
+		response := helper.APIResponse("Unknown sentiment model", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
 	}
-	newsAPIResponse := new(models.NewsResponse)
 
-	_, err := yahooAPI.Get(newsAPIPath).
-		QueryStruct(newsAPIParams).
-		ReceiveSuccess(newsAPIResponse)
-	if err != nil {
-		response := helper.APIResponse("Failed to fetch news articles", http.StatusUnprocessableEntity, "FAILED", nil)
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultNewsLimit
+	}
+
+	query := services.NewsQuery{
+		Symbol: req.Symbol,
+		Offset: (req.Page - 1) * req.Limit,
+		Limit:  req.Limit,
+	}
+
+	if req.StartDate != "" {
+		start, err := time.Parse(defaultDate, req.StartDate)
+		if err != nil {
+			response := helper.APIResponse("Invalid start date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		query.Start = start
+	}
+
+	if req.EndDate != "" {
+		end, err := time.Parse(defaultDate, req.EndDate)
+		if err != nil {
+			response := helper.APIResponse("Invalid end date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		// Include everything published on the end date
+		query.End = end.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	if req.Sources != "" {
+		query.Sources = strings.Split(req.Sources, ",")
+	}
+
+	newsArticles, total := h.newsStore.Search(query)
+
+	articles := make([]models.NewsItem, 0, len(newsArticles))
+	for _, article := range newsArticles {
+		articles = append(articles, models.NewsItem{
+			ID:          article.ID,
+			Title:       article.Title,
+			Body:        article.Body,
+			Link:        article.Link,
+			Source:      article.Source,
+			PublishedAt: article.PublishedAt,
+			Symbols:     article.Symbols,
+			Sentiment:   services.ScoreArticle(sentimentService, article),
+		})
+	}
+
+	respFormatter := models.NewsListResponse{}
+	respFormatter.Symbol = req.Symbol
+	respFormatter.Model = model
+	respFormatter.Page = req.Page
+	respFormatter.Limit = req.Limit
+	respFormatter.Total = total
+	respFormatter.TotalPages = (total + req.Limit - 1) / req.Limit
+	respFormatter.Articles = articles
+
+	response := helper.APIResponse("Get news successfully", http.StatusOK, "SUCCESS", respFormatter)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *newsController) GetSentiment(c *gin.Context) {
+	var req models.SentimentRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	_, model, ok := h.newsSentimentService.Model(req.Model)
+	if !ok {
+		response := helper.APIResponse("Unknown sentiment model", http.StatusBadRequest, "FAILED", nil)
 		c.JSON(http.StatusOK, response)
 		return
 	}
 
-	var overallSentiment int
-	for _, article := range newsAPIResponse.Articles {
-		articleSentiment := h.sentimentService.SentimentAnalysis(article.Title + " " + article.Body)
-		overallSentiment += articleSentiment
+	halfLife, err := h.newsSentimentService.HalfLife(req.HalfLife)
+	if err != nil {
+		response := helper.APIResponse("Invalid half life, should be a duration such as 72h", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
 	}
 
-	var sentiment string
-	if overallSentiment > 0 {
-		sentiment = "positive"
-	} else if overallSentiment < 0 {
-		sentiment = "negative"
-	} else {
-		sentiment = "neutral"
+	statistics, articles, err := h.newsSentimentService.SymbolSentiment(req.Symbol, model, halfLife)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
 	}
 
 	respFormatter := models.SentimentResponse{}
 	respFormatter.Symbol = req.Symbol
-	respFormatter.Sentiment = sentiment
+	respFormatter.Model = model
+	respFormatter.HalfLife = halfLife.String()
+	respFormatter.Sentiment = services.SentimentLabel(statistics.DecayedScore)
+	respFormatter.Statistics = statistics
+	respFormatter.Articles = articles
 
 	response := helper.APIResponse("Sentiment quote successfully", http.StatusOK, "SUCCESS", respFormatter)
 	c.JSON(http.StatusOK, response)
 }
+
+func (h *newsController) GetSentimentTimeSeries(c *gin.Context) {
+	var req models.SentimentTimeSeriesRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	sentimentService, model, ok := h.newsSentimentService.Model(req.Model)
+	if !ok {
+		response := helper.APIResponse("Unknown sentiment model", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	halfLife, err := h.newsSentimentService.HalfLife(req.HalfLife)
+	if err != nil {
+		response := helper.APIResponse("Invalid half life, should be a duration such as 72h", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	start, err := time.Parse(defaultDate, req.StartDate)
+	if err != nil {
+		response := helper.APIResponse("Invalid start date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	end, err := time.Parse(defaultDate, req.EndDate)
+	if err != nil {
+		response := helper.APIResponse("Invalid end date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if end.Before(start) {
+		response := helper.APIResponse("End date must not be before start date", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if end.After(start.AddDate(0, 0, services.MaxSentimentTimeSeriesDays-1)) {
+		response := helper.APIResponse(fmt.Sprintf("Date range must not exceed %d days", services.MaxSentimentTimeSeriesDays), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	newsArticles := h.newsStore.FindBySymbol(req.Symbol)
+
+	articles := make([]models.ArticleSentiment, 0, len(newsArticles))
+	for _, article := range newsArticles {
+		articles = append(articles, services.ScoreArticle(sentimentService, article))
+	}
+
+	points := services.SentimentTimeSeries(articles, start, end, halfLife)
+	trend, slope := services.SentimentTrend(points)
+
+	respFormatter := models.SentimentTimeSeriesResponse{}
+	respFormatter.Symbol = req.Symbol
+	respFormatter.Model = model
+	respFormatter.HalfLife = halfLife.String()
+	respFormatter.StartDate = start.Format(defaultDate)
+	respFormatter.EndDate = end.Format(defaultDate)
+	respFormatter.Trend = trend
+	respFormatter.Slope = slope
+	respFormatter.Points = points
+
+	response := helper.APIResponse("Sentiment time series successfully", http.StatusOK, "SUCCESS", respFormatter)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *newsController) TrainSentiment(c *gin.Context) {
+	var req models.SentimentTrainRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Only the Bayesian model learns from samples, so train it unless told otherwise
+	if req.Model == "" {
+		req.Model = "bayes"
+	}
+
+	sentimentService, _, ok := h.newsSentimentService.Model(req.Model)
+	if !ok {
+		response := helper.APIResponse("Unknown sentiment model", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	err := sentimentService.Train(req.Samples)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	respFormatter := models.SentimentTrainResponse{}
+	respFormatter.Trained = len(req.Samples)
+	respFormatter.Learned = sentimentService.Learned()
+
+	response := helper.APIResponse("Train sentiment successfully", http.StatusOK, "SUCCESS", respFormatter)
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"errors"
+	"id/projects/market-data/helper"
+	"id/projects/market-data/models"
+	"id/projects/market-data/paper"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type paperController struct {
+	engine *paper.Engine
+}
+
+func NewPaperController(engine *paper.Engine) *paperController {
+	return &paperController{engine}
+}
+
+func (h *paperController) CreateAccount(c *gin.Context) {
+	var req models.PaperAccountRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	account, err := h.engine.CreateAccount(req.Name, req.Cash)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Create account successfully", http.StatusCreated, "SUCCESS", account)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *paperController) GetAccounts(c *gin.Context) {
+	response := helper.APIResponse("Get accounts successfully", http.StatusOK, "SUCCESS", h.engine.Accounts())
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *paperController) GetAccount(c *gin.Context) {
+	account, err := h.engine.Account(c.Param("id"))
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusNotFound, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Get account successfully", http.StatusOK, "SUCCESS", account)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetPositions marks the open positions of an account to the latest quotes
+func (h *paperController) GetPositions(c *gin.Context) {
+	valuation, err := h.engine.Valuation(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusNotFound, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	positions := []paper.PositionValue{}
+	for _, position := range valuation.Positions {
+		if position.Quantity > 0 {
+			positions = append(positions, position)
+		}
+	}
+
+	response := helper.APIResponse("Get positions successfully", http.StatusOK, "SUCCESS", positions)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetPnL returns the realised and unrealised P&L of an account, in total and
+// by symbol
+func (h *paperController) GetPnL(c *gin.Context) {
+	valuation, err := h.engine.Valuation(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusNotFound, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Get P&L successfully", http.StatusOK, "SUCCESS", valuation)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *paperController) GetBalance(c *gin.Context) {
+	balance, err := h.engine.Balance(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusNotFound, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Get balance successfully", http.StatusOK, "SUCCESS", balance)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *paperController) PlaceOrder(c *gin.Context) {
+	var req models.PaperOrderRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	order, err := parseOrder(req)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	order, err = h.engine.Place(c.Request.Context(), order)
+	if err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, paper.ErrAccountNotFound) {
+			code = http.StatusNotFound
+		} else if !errors.Is(err, paper.ErrInvalidOrder) {
+			code = http.StatusInternalServerError
+		}
+		response := helper.APIResponse(err.Error(), code, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// a rejected order is still recorded, so the caller can see why
+	if order.Status == paper.Rejected {
+		response := helper.APIResponse("Order rejected: "+order.Reason, http.StatusUnprocessableEntity, "FAILED", order)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Place order successfully", http.StatusCreated, "SUCCESS", order)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetOrders lists orders, optionally of one account (?accountId=) and in one
+// status (?status=)
+func (h *paperController) GetOrders(c *gin.Context) {
+	orders := h.engine.Orders(c.Query("accountId"), paper.Status(c.Query("status")))
+
+	response := helper.APIResponse("Get orders successfully", http.StatusOK, "SUCCESS", orders)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *paperController) GetOrder(c *gin.Context) {
+	order, err := h.engine.Order(c.Param("id"))
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusNotFound, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Get order successfully", http.StatusOK, "SUCCESS", order)
+	c.JSON(http.StatusOK, response)
+}
+
+// AmendOrder changes the quantity or prices of an open order
+func (h *paperController) AmendOrder(c *gin.Context) {
+	var req models.PaperAmendRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	amendment := paper.Amendment{Quantity: req.Quantity, LimitPrice: req.LimitPrice, StopPrice: req.StopPrice}
+	order, err := h.engine.Amend(c.Request.Context(), c.Param("id"), amendment)
+	if err != nil {
+		code := http.StatusBadRequest
+		switch {
+		case errors.Is(err, paper.ErrOrderNotFound):
+			code = http.StatusNotFound
+		case errors.Is(err, paper.ErrOrderClosed):
+			code = http.StatusConflict
+		case errors.Is(err, paper.ErrRejected):
+			code = http.StatusUnprocessableEntity
+		}
+		response := helper.APIResponse(err.Error(), code, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Amend order successfully", http.StatusOK, "SUCCESS", order)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *paperController) CancelOrder(c *gin.Context) {
+	order, err := h.engine.Cancel(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, paper.ErrOrderNotFound) {
+		response := helper.APIResponse(err.Error(), http.StatusNotFound, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusConflict, "FAILED", order)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Cancel order successfully", http.StatusOK, "SUCCESS", order)
+	c.JSON(http.StatusOK, response)
+}
+
+func parseOrder(req models.PaperOrderRequest) (paper.Order, error) {
+	side, err := paper.ParseSide(req.Side)
+	if err != nil {
+		return paper.Order{}, err
+	}
+
+	orderType, err := paper.ParseOrderType(req.Type)
+	if err != nil {
+		return paper.Order{}, err
+	}
+
+	timeInForce, err := paper.ParseTimeInForce(req.TimeInForce)
+	if err != nil {
+		return paper.Order{}, err
+	}
+
+	return paper.Order{
+		AccountID:   req.AccountID,
+		Symbol:      req.Symbol,
+		Side:        side,
+		Type:        orderType,
+		TimeInForce: timeInForce,
+		Quantity:    req.Quantity,
+		LimitPrice:  req.LimitPrice,
+		StopPrice:   req.StopPrice,
+	}, nil
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"errors"
+	"id/projects/market-data/helper"
+	"id/projects/market-data/models"
+	"id/projects/market-data/portfolio"
+	"id/projects/market-data/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type portfolioController struct {
+	portfolioService services.PortfolioService
+}
+
+func NewPortfolioController(portfolioService services.PortfolioService) *portfolioController {
+	return &portfolioController{portfolioService}
+}
+
+func (h *portfolioController) CreatePortfolio(c *gin.Context) {
+	var req models.PortfolioRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	p, err := h.portfolioService.Create(req.Name, req.Currency)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusInternalServerError, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Create portfolio successfully", http.StatusCreated, "SUCCESS", p)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *portfolioController) GetPortfolios(c *gin.Context) {
+	response := helper.APIResponse("Get portfolios successfully", http.StatusOK, "SUCCESS", h.portfolioService.List())
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *portfolioController) GetPortfolio(c *gin.Context) {
+	p, err := h.portfolioService.Get(c.Param("id"))
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusNotFound, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Get portfolio successfully", http.StatusOK, "SUCCESS", p)
+	c.JSON(http.StatusOK, response)
+}
+
+// AddTransactions records buys, sells, dividends and fees; a batch that
+// would sell more than was held is refused as a whole
+func (h *portfolioController) AddTransactions(c *gin.Context) {
+	var req models.TransactionRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	transactions := make([]portfolio.Transaction, 0, len(req.Transactions))
+	for _, input := range req.Transactions {
+		transactionType, err := portfolio.ParseTransactionType(input.Type)
+		if err != nil {
+			response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		date, err := time.Parse(defaultDate, input.Date)
+		if err != nil {
+			response := helper.APIResponse("Invalid transaction date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		transactions = append(transactions, portfolio.Transaction{
+			Type:     transactionType,
+			Symbol:   input.Symbol,
+			Date:     date,
+			Quantity: input.Quantity,
+			Price:    input.Price,
+			Fee:      input.Fee,
+			Amount:   input.Amount,
+			Currency: input.Currency,
+			Note:     input.Note,
+		})
+	}
+
+	p, err := h.portfolioService.AddTransactions(c.Param("id"), transactions)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), portfolioErrorCode(err), "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Add transactions successfully", http.StatusCreated, "SUCCESS", p)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *portfolioController) DeleteTransaction(c *gin.Context) {
+	p, err := h.portfolioService.DeleteTransaction(c.Param("id"), c.Param("transactionId"))
+	if err != nil {
+		response := helper.APIResponse(err.Error(), portfolioErrorCode(err), "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := helper.APIResponse("Delete transaction successfully", http.StatusOK, "SUCCESS", p)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetHoldings values the holdings at the latest quotes with their cost
+// basis (?method=fifo or average), P&L and daily change
+func (h *portfolioController) GetHoldings(c *gin.Context) {
+	valuation, ok := h.valuation(c)
+	if !ok {
+		return
+	}
+
+	response := helper.APIResponse("Get holdings successfully", http.StatusOK, "SUCCESS", valuation)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetAllocation splits the market value of a portfolio by sector and by
+// currency
+func (h *portfolioController) GetAllocation(c *gin.Context) {
+	valuation, ok := h.valuation(c)
+	if !ok {
+		return
+	}
+
+	respFormatter := models.AllocationResponse{
+		PortfolioID: valuation.PortfolioID,
+		Currency:    valuation.Currency,
+		MarketValue: valuation.MarketValue,
+		Sectors:     valuation.Allocation.Sectors,
+		Currencies:  valuation.Allocation.Currencies,
+	}
+
+	response := helper.APIResponse("Get allocation successfully", http.StatusOK, "SUCCESS", respFormatter)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *portfolioController) valuation(c *gin.Context) (portfolio.Valuation, bool) {
+	method, err := portfolio.ParseCostMethod(c.Query("method"))
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return portfolio.Valuation{}, false
+	}
+
+	valuation, err := h.portfolioService.Valuation(c.Request.Context(), c.Param("id"), method)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), portfolioErrorCode(err), "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return portfolio.Valuation{}, false
+	}
+
+	return valuation, true
+}
+
+func portfolioErrorCode(err error) int {
+	switch {
+	case errors.Is(err, portfolio.ErrPortfolioNotFound), errors.Is(err, portfolio.ErrTransactionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, portfolio.ErrInvalidTransaction):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
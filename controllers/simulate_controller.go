@@ -1,22 +1,43 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"id/projects/market-data/backtest"
+	"id/projects/market-data/backtest/rules"
+	"id/projects/market-data/backtest/strategies"
 	"id/projects/market-data/helper"
 	"id/projects/market-data/models"
+	"id/projects/market-data/services"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/markcheno/go-quote"
-	"github.com/markcheno/go-talib"
+)
+
+const (
+	defaultOptimizeSamples = 100
+	defaultOptimizeTop     = 20
+	defaultInSampleBars    = 252
+	defaultOutOfSampleBars = 63
+	// assets are ranked by their three month rate of change unless a request
+	// sets its own signal
+	defaultPortfolioSignal = "ROC(close, 63)"
 )
 
 type simulateController struct {
+	benchmark        string
+	corporateActions services.CorporateActionService
 }
 
-func NewSimulateController() *simulateController {
-	return &simulateController{}
+// NewSimulateController takes the index simulations are compared against
+// when a request does not name one, e.g. "JKSE" for ^JKSE.
+func NewSimulateController(benchmark string, corporateActions services.CorporateActionService) *simulateController {
+	return &simulateController{benchmark, corporateActions}
 }
 
 func (h *simulateController) GetSimulate(c *gin.Context) {
@@ -31,141 +52,733 @@ func (h *simulateController) GetSimulate(c *gin.Context) {
 		return
 	}
 
-	start, err := time.Parse(defaultDate, req.StartDate)
+	var strategy backtest.Strategy
+	var strategyName string
+	var params map[string]float64
+	var definition *rules.Definition
+	if req.Strategy != nil {
+		ruleStrategy, err := rules.New(*req.Strategy)
+		if err != nil {
+			response := helper.APIResponse("Invalid strategy: "+err.Error(), http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		normalized := ruleStrategy.Definition()
+		strategy, strategyName, definition = ruleStrategy, "rules", &normalized
+	} else {
+		strategyName = req.StrategyName
+		if strategyName == "" {
+			strategyName = strategies.Default
+		}
+
+		params = make(map[string]float64, len(req.Params)+2)
+		for name, value := range req.Params {
+			params[name] = value
+		}
+
+		// the default strategy still takes its thresholds from buyPrice and sellPrice
+		if strings.EqualFold(strategyName, strategies.Default) {
+			if _, ok := params["buyThreshold"]; !ok {
+				// threshold value that represents the minimum price at which we should buy shares
+				buyPriceThreshold, err := strconv.ParseFloat(req.BuyPrice, 64)
+				if err != nil {
+					response := helper.APIResponse("Invalid buy price", http.StatusBadRequest, "FAILED", nil)
+					c.JSON(http.StatusOK, response)
+					return
+				}
+				params["buyThreshold"] = buyPriceThreshold
+			}
+
+			if _, ok := params["sellThreshold"]; !ok {
+				// threshold value that represents the maximum price at which we should sell shares
+				sellPriceThreshold, err := strconv.ParseFloat(req.SellPrice, 64)
+				if err != nil {
+					response := helper.APIResponse("Invalid sell price", http.StatusBadRequest, "FAILED", nil)
+					c.JSON(http.StatusOK, response)
+					return
+				}
+				params["sellThreshold"] = sellPriceThreshold
+			}
+		}
+
+		built, resolved, err := strategies.Build(strategyName, params)
+		if err != nil {
+			response := helper.APIResponse("Invalid strategy: "+err.Error(), http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		if ruleStrategy, ok := built.(*rules.Strategy); ok {
+			normalized := ruleStrategy.Definition()
+			definition = &normalized
+		}
+		spec, _ := strategies.Lookup(strategyName)
+		strategy, strategyName, params = built, spec.Name, resolved
+	}
+
+	input, ok := h.loadBacktest(c, req.BacktestRequest)
+	if !ok {
+		return
+	}
+	start, end, bars, config := input.Start, input.End, input.Bars, input.Config
+	config.Progress = services.Progress(c.Request.Context())
+
+	result, err := backtest.Run(c.Request.Context(), bars, strategy, config)
 	if err != nil {
-		response := helper.APIResponse("Invalid start date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
 		c.JSON(http.StatusOK, response)
 		return
 	}
 
-	end, err := time.Parse(defaultDate, req.EndDate)
+	if exportCSV(c, req.ExportRequest, exportName(req.Symbol, start, end), result.Trades, result.Equity) {
+		return
+	}
+
+	buyAndHold, err := backtest.Benchmark(c.Request.Context(), req.Symbol, bars, result.Equity, config)
 	if err != nil {
-		response := helper.APIResponse("Invalid end date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
 		c.JSON(http.StatusOK, response)
 		return
 	}
 
-	cash, err := strconv.ParseFloat(req.Cash, 64)
-	if err != nil {
-		response := helper.APIResponse("Invalid Cash", http.StatusBadRequest, "FAILED", nil)
+	benchmarkIndex := req.Benchmark
+	if benchmarkIndex == "" {
+		benchmarkIndex = h.benchmark
+	}
+
+	var benchmark *backtest.BenchmarkResult
+	if benchmarkIndex != "" {
+		benchmarkSymbol := indexSymbol(benchmarkIndex)
+
+		index, err := fetchQuote(c.Request.Context(), benchmarkSymbol, start, end)
+		if err != nil || len(index.Close) == 0 {
+			response := helper.APIResponse("Failed to retrieve benchmark data", http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		indexResult, err := backtest.Benchmark(c.Request.Context(), benchmarkSymbol, backtest.BarsFromQuote(index), result.Equity, config)
+		if err != nil {
+			response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		benchmark = &indexResult
+	}
+
+	respFormatter := models.SimulationResponse{}
+	respFormatter.Symbol = req.Symbol
+	respFormatter.StartDate = start.Format(defaultDate)
+	respFormatter.EndDate = end.Format(defaultDate)
+	respFormatter.InitialCash = result.InitialCash
+	respFormatter.FinalEquity = result.FinalEquity
+	respFormatter.Cash = result.Cash
+	respFormatter.Shares = result.Shares
+	respFormatter.GainLoss = result.GainLoss
+	respFormatter.TotalCost = result.CostBasis
+	respFormatter.Fees = result.Fees
+	respFormatter.Adjustment = input.Adjustment
+	respFormatter.BorrowFees = result.BorrowFees
+	respFormatter.Interest = result.Interest
+	respFormatter.Dividends = result.Dividends
+	respFormatter.MarginCalls = result.MarginCalls
+	respFormatter.Strategy = strategyName
+	respFormatter.Params = params
+	respFormatter.Rules = definition
+	respFormatter.Metrics = result.Metrics
+	respFormatter.BuyAndHold = buyAndHold
+	respFormatter.Benchmark = benchmark
+	respFormatter.Fills = result.Fills
+	respFormatter.Trades = result.Trades
+	respFormatter.Equity = result.Equity
+
+	response := helper.APIResponse("Simulate quote successfully", http.StatusOK, "SUCCESS", respFormatter)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetOptimize searches a library strategy's parameters for the best backtest
+func (h *simulateController) GetOptimize(c *gin.Context) {
+	var req models.OptimizeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
 		c.JSON(http.StatusOK, response)
 		return
 	}
 
-	// threshold value that represents the minimum price at which we should buy shares
-	buyPriceThreshold, err := strconv.ParseFloat(req.BuyPrice, 64)
+	spec, search, ok := searchConfig(c, req.SearchRequest)
+	if !ok {
+		return
+	}
+	ranges := search.Ranges
+
+	axes := req.Heatmap
+	if len(axes) == 0 && len(ranges) >= 2 {
+		axes = []string{ranges[0].Name, ranges[1].Name}
+	}
+	if len(axes) != 0 {
+		if len(axes) != 2 || !hasRange(ranges, axes[0]) || !hasRange(ranges, axes[1]) {
+			response := helper.APIResponse("Invalid heatmap, should name two of the ranged parameters", http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+	}
+
+	top := req.Top
+	if top <= 0 {
+		top = defaultOptimizeTop
+	}
+
+	input, ok := h.loadBacktest(c, req.BacktestRequest)
+	if !ok {
+		return
+	}
+
+	search.Config = input.Config
+	search.Progress = services.Progress(c.Request.Context())
+	result, err := backtest.Optimize(c.Request.Context(), input.Bars, libraryFactory(spec), search)
 	if err != nil {
-		response := helper.APIResponse("Invalid buy price", http.StatusBadRequest, "FAILED", nil)
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	respFormatter := models.OptimizeResponse{}
+	respFormatter.Symbol = req.Symbol
+	respFormatter.StartDate = input.Start.Format(defaultDate)
+	respFormatter.EndDate = input.End.Format(defaultDate)
+	respFormatter.Strategy = spec.Name
+	respFormatter.Objective = result.Objective
+	respFormatter.Search = result.Search
+	respFormatter.Evaluated = result.Evaluated
+	respFormatter.Feasible = result.Feasible
+	respFormatter.Trials = result.Trials
+	if len(respFormatter.Trials) > top {
+		respFormatter.Trials = respFormatter.Trials[:top]
+	}
+	if best, ok := result.Best(); ok {
+		respFormatter.Best = &best
+	}
+	if len(axes) == 2 {
+		heatmap := backtest.NewHeatmap(result.Trials, axes[0], axes[1])
+		respFormatter.Heatmap = &heatmap
+	}
+
+	response := helper.APIResponse("Optimize strategy successfully", http.StatusOK, "SUCCESS", respFormatter)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetWalkForward re-optimises a library strategy on rolling or anchored
+// in-sample windows and trades each result on the window that follows
+func (h *simulateController) GetWalkForward(c *gin.Context) {
+	var req models.WalkForwardRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
 		c.JSON(http.StatusOK, response)
 		return
 	}
 
-	// threshold value that represents the maximum price at which we should sell shares
-	sellPriceThreshold, err := strconv.ParseFloat(req.SellPrice, 64)
+	spec, search, ok := searchConfig(c, req.SearchRequest)
+	if !ok {
+		return
+	}
+
+	mode, err := backtest.ParseWindowMode(req.Mode)
 	if err != nil {
-		response := helper.APIResponse("Invalid sell price", http.StatusBadRequest, "FAILED", nil)
+		response := helper.APIResponse("Invalid mode, should be rolling or anchored", http.StatusBadRequest, "FAILED", nil)
 		c.JSON(http.StatusOK, response)
 		return
 	}
 
-	quote, err := quote.NewQuoteFromYahoo(req.Symbol, start.Format(defaultDate), end.Format(defaultDate), quote.Daily, true)
+	inSample := req.InSample
+	if inSample == 0 {
+		inSample = defaultInSampleBars
+	}
+	outOfSample := req.OutOfSample
+	if outOfSample == 0 {
+		outOfSample = defaultOutOfSampleBars
+	}
+
+	input, ok := h.loadBacktest(c, req.BacktestRequest)
+	if !ok {
+		return
+	}
+
+	search.Config = input.Config
+	result, err := backtest.WalkForward(c.Request.Context(), input.Bars, libraryFactory(spec), backtest.WalkForwardConfig{
+		Optimize:    search,
+		InSample:    inSample,
+		OutOfSample: outOfSample,
+		Mode:        mode,
+		Progress:    services.Progress(c.Request.Context()),
+	})
 	if err != nil {
 		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
 		c.JSON(http.StatusOK, response)
+		return
+	}
 
+	if exportCSV(c, req.ExportRequest, exportName(req.Symbol+"_walkforward", input.Start, input.End), result.Trades, result.Equity) {
 		return
 	}
 
-	// Check if the stock data is empty
-	if len(quote.Close) == 0 {
-		response := helper.APIResponse("Failed to retrieve stock data", http.StatusBadRequest, "FAILED", nil)
+	respFormatter := models.WalkForwardResponse{}
+	respFormatter.Symbol = req.Symbol
+	respFormatter.StartDate = input.Start.Format(defaultDate)
+	respFormatter.EndDate = input.End.Format(defaultDate)
+	respFormatter.Strategy = spec.Name
+	respFormatter.InSample = inSample
+	respFormatter.OutOfSample = outOfSample
+	respFormatter.WalkForwardResult = result
+
+	response := helper.APIResponse("Walk forward strategy successfully", http.StatusOK, "SUCCESS", respFormatter)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetPortfolioSimulate backtests several symbols sharing one cash account,
+// rebalanced periodically to target weights
+func (h *simulateController) GetPortfolioSimulate(c *gin.Context) {
+	var req models.PortfolioSimulationRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors := helper.FormatValidationError(err)
+		errorMessage := gin.H{"errors": errors}
+
+		response := helper.APIResponse("Unable to process request", http.StatusUnprocessableEntity, "FAILED", errorMessage)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, symbol := range strings.Split(req.Symbols, ",") {
+		symbol = strings.TrimSpace(symbol)
+		if symbol != "" && !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		response := helper.APIResponse("Invalid symbols, should be a comma separated list", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	weighting, err := backtest.ParseWeighting(req.Weighting)
+	if err != nil {
+		response := helper.APIResponse("Invalid weighting, should be equalWeight, inverseVolatility or topN", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	rebalance, err := backtest.ParseRebalance(req.Rebalance)
+	if err != nil {
+		response := helper.APIResponse("Invalid rebalance, should be daily, weekly, monthly or quarterly", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	signalRule := req.Signal
+	if signalRule == "" {
+		signalRule = defaultPortfolioSignal
+	}
+	signal, err := rules.ParseValue(signalRule)
+	if err != nil {
+		response := helper.APIResponse("Invalid signal: "+err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	var filter rules.Condition
+	if req.Filter != "" {
+		filter, err = rules.Parse(req.Filter)
+		if err != nil {
+			response := helper.APIResponse("Invalid filter: "+err.Error(), http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return
+		}
+	}
+
+	if req.Sizing != (backtest.Sizing{}) {
+		response := helper.APIResponse("Sizing is not supported for portfolios, the target weights size the positions", http.StatusBadRequest, "FAILED", nil)
 		c.JSON(http.StatusOK, response)
 		return
 	}
 
-	// Perform technical analysis using go-talib
-	closePrices := make([]float64, len(quote.Close))
-	for i, price := range quote.Close {
-		closePrices[i] = price
+	input, ok := parseBacktest(c, models.BacktestRequest{
+		StartDate:    req.StartDate,
+		EndDate:      req.EndDate,
+		Cash:         req.Cash,
+		Fill:         req.Fill,
+		RiskFreeRate: req.RiskFreeRate,
+		Costs:        req.Costs,
+		Margin:       req.Margin,
+		Adjustment:   req.Adjustment,
+	})
+	if !ok {
+		return
+	}
+
+	assets := make([]backtest.Asset, 0, len(symbols))
+	for _, symbol := range symbols {
+		var bars []backtest.Bar
+		var actions backtest.CorporateActions
+		if input.Adjustment == backtest.TotalReturn {
+			bars, ok = fetchBars(c, symbol, input.Start, input.End)
+		} else {
+			bars, actions, ok = fetchAdjustedBars(c, h.corporateActions, symbol, input.Start, input.End, input.Adjustment)
+			actions = actions.For(input.Adjustment)
+		}
+		if !ok {
+			return
+		}
+
+		asset := backtest.Asset{Symbol: symbol, Bars: bars, Score: rules.Evaluate(signal, bars), Actions: actions}
+		if filter != nil {
+			asset.Eligible = rules.Matches(filter, bars)
+		}
+		assets = append(assets, asset)
 	}
 
-	result, err := simulateTrading(closePrices, cash, buyPriceThreshold, sellPriceThreshold)
+	result, err := backtest.RunPortfolio(c.Request.Context(), assets, backtest.PortfolioConfig{
+		InitialCash:        input.Config.InitialCash,
+		Fill:               input.Config.Fill,
+		Costs:              input.Config.Costs,
+		RiskFreeRate:       input.Config.RiskFreeRate,
+		Weighting:          weighting,
+		Rebalance:          rebalance,
+		TopN:               req.TopN,
+		MaxPositions:       req.MaxPositions,
+		MaxWeight:          req.MaxWeight,
+		VolatilityLookback: req.VolatilityLookback,
+		Margin:             input.Config.Margin,
+		Progress:           services.Progress(c.Request.Context()),
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if exportCSV(c, req.ExportRequest, exportName("portfolio", input.Start, input.End), result.Trades, result.Equity) {
 		return
 	}
 
-	response := helper.APIResponse("Simulate quote successfully", http.StatusOK, "SUCCESS", result)
+	respFormatter := models.PortfolioSimulationResponse{}
+	respFormatter.StartDate = input.Start.Format(defaultDate)
+	respFormatter.EndDate = input.End.Format(defaultDate)
+	respFormatter.Weighting = weighting
+	respFormatter.Rebalance = rebalance
+	respFormatter.Signal = signal.String()
+	if filter != nil {
+		respFormatter.Filter = filter.String()
+	}
+	respFormatter.PortfolioResult = result
+
+	response := helper.APIResponse("Simulate portfolio successfully", http.StatusOK, "SUCCESS", respFormatter)
 	c.JSON(http.StatusOK, response)
 }
 
-func simulateTrading(closePrices []float64, initialCash float64, buyThreshold float64, sellThreshold float64) (models.SimulationResponse, error) {
-	// window size is the number of days used to calculate the historical average price, which is used to determine whether to buy or sell shares
-	// Compute the historical average price, and RSI
-	windowSize := 30
-	averagePrices := talib.Sma(closePrices, windowSize)
-	rsi := talib.Rsi(closePrices, 14)
-
-	// Use momentum-based strategy with RSI, stop-loss, and trailing
-	cash := initialCash
-	shares := 0.0
-	totalCost := 0.0
-	stopLoss := 0.95    // sell if price drops 5% below purchase price
-	trailingStop := 0.1 // sell if price drops 10% below highest price since purchase
-	highestPrice := 0.0
-	for i, stockPrice := range closePrices {
-		if i < windowSize {
-			continue
-		}
-		momentum := stockPrice / averagePrices[i-windowSize]
-		if momentum > sellThreshold && shares > 0 {
-			lotsToSell := shares
-			cash += lotsToSell * stockPrice
-			shares -= lotsToSell
-			if shares > 0 {
-				totalCost -= lotsToSell * totalCost / shares
-			} else {
-				totalCost = 0.0
-			}
-		} else if momentum < buyThreshold && cash > 0 {
-			// Only buy if RSI is below 30 (oversold)
-			if rsi[i] < 30 {
-				lotsToBuy := cash / stockPrice
-				shares += lotsToBuy
-				totalCost += lotsToBuy * stockPrice
-				cash -= lotsToBuy * stockPrice
-				highestPrice = stockPrice
-			}
-		} else if shares > 0 {
-			// Check for stop-loss and trailing stop orders
-			if stockPrice < totalCost*stopLoss {
-				lotsToSell := shares
-				cash += lotsToSell * stockPrice
-				shares = 0
-				totalCost = 0.0
-			} else if stockPrice > highestPrice {
-				highestPrice = stockPrice
-			} else if stockPrice < highestPrice*(1-trailingStop) {
-				lotsToSell := shares
-				cash += lotsToSell * stockPrice
-				shares = 0
-				totalCost = 0.0
-			}
+// searchConfig checks the strategy and search fields of a request and turns
+// them into an OptimizeConfig without the backtest Config. When it fails it
+// has already written the error response.
+func searchConfig(c *gin.Context, req models.SearchRequest) (strategies.Spec, backtest.OptimizeConfig, bool) {
+	spec, ok := strategies.Lookup(req.StrategyName)
+	if !ok {
+		response := helper.APIResponse("Invalid strategy: unknown strategy "+req.StrategyName, http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return strategies.Spec{}, backtest.OptimizeConfig{}, false
+	}
+
+	search, err := backtest.ParseSearch(req.Search)
+	if err != nil {
+		response := helper.APIResponse("Invalid search, should be grid or random", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return strategies.Spec{}, backtest.OptimizeConfig{}, false
+	}
+
+	objective, err := backtest.ParseObjective(req.Objective)
+	if err != nil {
+		response := helper.APIResponse("Invalid objective, should be sharpe, sortino, calmar, cagr, totalReturn, profitFactor or maxDrawdown", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return strategies.Spec{}, backtest.OptimizeConfig{}, false
+	}
+
+	samples := req.Samples
+	if samples == 0 {
+		samples = defaultOptimizeSamples
+	}
+
+	ranges := make([]backtest.ParamRange, len(req.Ranges))
+	for i, paramRange := range req.Ranges {
+		param, ok := spec.Param(paramRange.Name)
+		if !ok {
+			response := helper.APIResponse(fmt.Sprintf("Invalid range: %s has no parameter %q", spec.Name, paramRange.Name), http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return strategies.Spec{}, backtest.OptimizeConfig{}, false
+		}
+		paramRange.Integer = param.Integer
+		ranges[i] = paramRange
+	}
+
+	return spec, backtest.OptimizeConfig{
+		Fixed:       req.Params,
+		Ranges:      ranges,
+		Search:      search,
+		Samples:     samples,
+		Seed:        req.Seed,
+		Objective:   objective,
+		MaxDrawdown: req.MaxDrawdown,
+	}, true
+}
+
+// libraryFactory builds fresh instances of a library strategy for the optimiser
+func libraryFactory(spec strategies.Spec) backtest.StrategyFactory {
+	return func(params map[string]float64) (backtest.Strategy, error) {
+		strategy, _, err := strategies.Build(spec.Name, params)
+		return strategy, err
+	}
+}
+
+func hasRange(ranges []backtest.ParamRange, name string) bool {
+	for _, paramRange := range ranges {
+		if paramRange.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// backtestInput is what the backtesting endpoints derive from a BacktestRequest
+type backtestInput struct {
+	Start      time.Time
+	End        time.Time
+	Adjustment backtest.Adjustment
+	Bars       []backtest.Bar
+	Config     backtest.Config
+}
+
+// loadBacktest parses the fields every backtesting endpoint shares and fetches
+// the bars, along with the splits and dividends the backtest has to apply to
+// unadjusted prices. When it fails it has already written the error response.
+func (h *simulateController) loadBacktest(c *gin.Context, req models.BacktestRequest) (backtestInput, bool) {
+	input, ok := parseBacktest(c, req)
+	if !ok {
+		return backtestInput{}, false
+	}
+
+	if input.Adjustment == backtest.TotalReturn {
+		input.Bars, ok = fetchBars(c, req.Symbol, input.Start, input.End)
+		if !ok {
+			return backtestInput{}, false
+		}
+		return input, true
+	}
+
+	bars, actions, ok := fetchAdjustedBars(c, h.corporateActions, req.Symbol, input.Start, input.End, input.Adjustment)
+	if !ok {
+		return backtestInput{}, false
+	}
+	input.Bars = bars
+	input.Config.Actions = actions.For(input.Adjustment)
+
+	return input, true
+}
+
+// parseBacktest is loadBacktest without fetching the bars.
+func parseBacktest(c *gin.Context, req models.BacktestRequest) (backtestInput, bool) {
+	start, err := time.Parse(defaultDate, req.StartDate)
+	if err != nil {
+		response := helper.APIResponse("Invalid start date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return backtestInput{}, false
+	}
+
+	end, err := time.Parse(defaultDate, req.EndDate)
+	if err != nil {
+		response := helper.APIResponse("Invalid end date format, should be YYYY-MM-DD", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return backtestInput{}, false
+	}
+
+	cash, err := strconv.ParseFloat(req.Cash, 64)
+	if err != nil {
+		response := helper.APIResponse("Invalid Cash", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return backtestInput{}, false
+	}
+
+	fill, err := backtest.ParseFillMode(req.Fill)
+	if err != nil {
+		response := helper.APIResponse("Invalid fill, should be nextOpen or nextClose", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return backtestInput{}, false
+	}
+
+	var riskFreeRate float64
+	if req.RiskFreeRate != "" {
+		riskFreeRate, err = strconv.ParseFloat(req.RiskFreeRate, 64)
+		if err != nil {
+			response := helper.APIResponse("Invalid risk free rate", http.StatusBadRequest, "FAILED", nil)
+			c.JSON(http.StatusOK, response)
+			return backtestInput{}, false
 		}
 	}
 
-	// gainLoss represents the total amount of profit or loss made by the trading algorithm during the simulation
-	// totalCost represents the total cost of all the shares bought during the simulation.
-	// It is possible for the cash variable to be equal to the gainLoss variable at the end of the simulation. This can happen if all the shares that were bought during the simulation have been sold, and there is no remaining cash or shares at the end of the simulation.
-	// When this happens, the gainLoss variable represents the total profit or loss of the trading strategy, which takes into account both the cash and the shares held during the simulation. If the gainLoss variable is equal to the cash variable, it means that all the available funds have been fully utilized during the simulation, and there is no remaining profit or loss to be realized.
-	gainLoss := cash + shares*closePrices[len(closePrices)-1] - totalCost
+	if err := req.Margin.Validate(); err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return backtestInput{}, false
+	}
 
-	result := models.SimulationResponse{
-		Cash:      cash,
-		Shares:    shares,
-		GainLoss:  gainLoss,
-		TotalCost: totalCost,
+	if err := req.Sizing.Validate(); err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return backtestInput{}, false
+	}
+
+	adjustment, err := backtest.ParseAdjustment(req.Adjustment)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return backtestInput{}, false
+	}
+
+	return backtestInput{
+		Start:      start,
+		End:        end,
+		Adjustment: adjustment,
+		Config: backtest.Config{
+			Symbol:       req.Symbol,
+			InitialCash:  cash,
+			Fill:         fill,
+			Costs:        req.Costs,
+			RiskFreeRate: riskFreeRate,
+			Margin:       req.Margin,
+			Sizing:       req.Sizing,
+		},
+	}, true
+}
+
+// fetchBars downloads the daily bars of symbol. When it fails it has already
+// written the error response.
+func fetchBars(c *gin.Context, symbol string, start time.Time, end time.Time) ([]backtest.Bar, bool) {
+	stock, err := fetchQuote(c.Request.Context(), symbol, start, end)
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return nil, false
+	}
+
+	// Check if the stock data is empty
+	if len(stock.Close) == 0 {
+		response := helper.APIResponse("Failed to retrieve stock data for "+symbol, http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return nil, false
 	}
-	return result, nil
 
+	return backtest.BarsFromQuote(stock), true
+}
+
+// fetchQuote downloads the adjusted daily quote of symbol, giving up when ctx
+// is cancelled. go-quote takes no context, so an abandoned download still
+// finishes in the background.
+func fetchQuote(ctx context.Context, symbol string, start time.Time, end time.Time) (quote.Quote, error) {
+	type fetched struct {
+		stock quote.Quote
+		err   error
+	}
+
+	done := make(chan fetched, 1)
+	go func() {
+		stock, err := quote.NewQuoteFromYahoo(symbol, start.Format(defaultDate), end.Format(defaultDate), quote.Daily, true)
+		done <- fetched{stock, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return quote.Quote{}, ctx.Err()
+	case result := <-done:
+		return result.stock, result.err
+	}
+}
+
+// exportCSV answers with the trade blotter or the equity curve as a CSV
+// download when the request asks for CSV, and reports whether it did.
+func exportCSV(c *gin.Context, req models.ExportRequest, name string, trades []backtest.Trade, equity []backtest.EquityPoint) bool {
+	format := req.Format
+	if format == "" {
+		format = c.Query("format")
+	}
+	if format == "" && strings.Contains(c.GetHeader("Accept"), "text/csv") {
+		format = "csv"
+	}
+
+	switch strings.ToLower(format) {
+	case "", "json":
+		return false
+	case "csv":
+	default:
+		response := helper.APIResponse("Invalid format, should be json or csv", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return true
+	}
+
+	export := req.Export
+	if export == "" {
+		export = c.DefaultQuery("export", "trades")
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch strings.ToLower(export) {
+	case "trades":
+		err = backtest.WriteTradesCSV(&buf, trades)
+	case "equity":
+		err = backtest.WriteEquityCSV(&buf, equity)
+	default:
+		response := helper.APIResponse("Invalid export, should be trades or equity", http.StatusBadRequest, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return true
+	}
+	if err != nil {
+		response := helper.APIResponse(err.Error(), http.StatusInternalServerError, "FAILED", nil)
+		c.JSON(http.StatusOK, response)
+		return true
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"_"+strings.ToLower(export)+".csv"))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	return true
+}
+
+func exportName(name string, start time.Time, end time.Time) string {
+	return strings.ReplaceAll(name, "^", "") + "_" + start.Format(defaultDate) + "_" + end.Format(defaultDate)
+}
+
+// GetStrategies documents the strategy library and the indicators rules can use
+func (h *simulateController) GetStrategies(c *gin.Context) {
+	respFormatter := models.StrategyListResponse{
+		Strategies: strategies.List(),
+		Indicators: rules.Indicators(),
+	}
+
+	response := helper.APIResponse("Get strategies successfully", http.StatusOK, "SUCCESS", respFormatter)
+	c.JSON(http.StatusOK, response)
+}
+
+// indexSymbol prefixes an index name with "^" the way Yahoo expects, as GetIndex does
+func indexSymbol(index string) string {
+	if strings.HasPrefix(index, "^") {
+		return index
+	}
+	return "^" + index
 }
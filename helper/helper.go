@@ -2,8 +2,13 @@ package helper
 
 import (
 	"encoding/json"
-	"github.com/go-playground/validator/v10"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
 
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 )
 
 type Response struct {
@@ -12,7 +17,7 @@ type Response struct {
 }
 
 type Meta struct {
-	Code    int `json:"code"`
+	Code    int    `json:"code"`
 	Status  string `json:"status"`
 	Message string `json:"message"`
 }
@@ -35,7 +40,12 @@ func APIResponse(message string, code int, status string, data interface{}) Resp
 func FormatValidationError(err error) []string {
 	var errors []string
 
-	for _, e := range err.(validator.ValidationErrors) {
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return append(errors, err.Error())
+	}
+
+	for _, e := range validationErrors {
 		errors = append(errors, e.Error())
 	}
 
@@ -54,3 +64,77 @@ func Marshaler(a interface{}) (map[string]interface{}, error) {
 	}
 	return b, nil
 }
+
+func GetEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func GetEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(GetEnv(key, strconv.FormatBool(fallback)))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func AdminOnly(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" || c.GetHeader("X-Admin-Token") != token {
+			response := APIResponse("Unauthorized", http.StatusUnauthorized, "FAILED", nil)
+			c.AbortWithStatusJSON(http.StatusOK, response)
+			return
+		}
+		c.Next()
+	}
+}
+
+// WriteFileAtomic stores v as JSON at path. The data goes to a temporary
+// file that is synced before it is renamed over path, and the directory is
+// synced after, so neither a crash nor a power loss leaves path truncated.
+func WriteFileAtomic(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// the rename itself is only durable once the directory is synced
+	directory, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer directory.Close()
+	return directory.Sync()
+}
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"context"
+	"id/projects/market-data/backtest"
 	"id/projects/market-data/controllers"
+	"id/projects/market-data/helper"
+	"id/projects/market-data/paper"
 	"id/projects/market-data/services"
+	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,18 +18,163 @@ import (
 func main() {
 	r := gin.Default()
 
-	sentimentService := services.NewSentimenService()
+	tokenizer := services.NewTokenizer(
+		strings.Split(helper.GetEnv("SENTIMENT_LANGUAGES", "en,id"), ","),
+		helper.GetEnvBool("SENTIMENT_STEM", true),
+		helper.GetEnvBool("SENTIMENT_BIGRAMS", false),
+	)
+
+	sentimentService, err := services.NewSentimenService(
+		helper.GetEnv("SENTIMENT_MODEL_PATH", "data/sentiment_model.gob"),
+		helper.GetEnv("SENTIMENT_CORPUS_PATH", "data/sentiment_corpus.csv"),
+		tokenizer,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	lexiconSentimentService, err := services.NewLexiconSentimentService(helper.GetEnv("SENTIMENT_LEXICON_PATH", "data/sentiment_lexicon.csv"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sentimentServices := map[string]services.SentimenService{
+		"bayes":   sentimentService,
+		"lexicon": lexiconSentimentService,
+	}
+
+	newsStore, err := services.NewNewsStore(helper.GetEnv("NEWS_STORE_PATH", "data/news.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	symbolDictionary, err := services.NewSymbolDictionary(helper.GetEnv("NEWS_SYMBOLS_PATH", "data/symbols.csv"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	newsPollInterval, err := time.ParseDuration(helper.GetEnv("NEWS_POLL_INTERVAL", "15m"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var newsFeeds []string
+	for _, feed := range strings.Split(helper.GetEnv("NEWS_FEEDS", ""), ",") {
+		if feed = strings.TrimSpace(feed); feed != "" {
+			newsFeeds = append(newsFeeds, feed)
+		}
+	}
+
+	newsIngestService := services.NewNewsIngestService(newsFeeds, newsStore, symbolDictionary, newsPollInterval)
+	go newsIngestService.Run(context.Background())
+
+	sentimentHalfLife, err := time.ParseDuration(helper.GetEnv("SENTIMENT_HALF_LIFE", "72h"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	newsSentimentService := services.NewNewsSentimentService(newsStore, sentimentServices, helper.GetEnv("SENTIMENT_MODEL", "lexicon"), sentimentHalfLife)
+
+	corporateActionTTL, err := time.ParseDuration(helper.GetEnv("CORPORATE_ACTIONS_TTL", "24h"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	corporateActionService, err := services.NewCorporateActionService(helper.GetEnv("CORPORATE_ACTIONS_PATH", "data/corporate_actions.json"), corporateActionTTL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jobWorkers, err := strconv.Atoi(helper.GetEnv("JOB_WORKERS", "2"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jobQueueSize, err := strconv.Atoi(helper.GetEnv("JOB_QUEUE_SIZE", "100"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jobRetention, err := time.ParseDuration(helper.GetEnv("JOB_RETENTION", "168h"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jobMaxJobs, err := strconv.Atoi(helper.GetEnv("JOB_MAX_JOBS", "1000"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jobService, err := services.NewJobService(helper.GetEnv("JOB_STORE_PATH", "data/jobs.json"), jobWorkers, jobQueueSize, jobRetention, jobMaxJobs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	paperCommission, err := strconv.ParseFloat(helper.GetEnv("PAPER_COMMISSION", "0"), 64)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	paperSellTax, err := strconv.ParseFloat(helper.GetEnv("PAPER_SELL_TAX", "0"), 64)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	paperLotSize, err := strconv.ParseFloat(helper.GetEnv("PAPER_LOT_SIZE", "0"), 64)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	paperCosts := backtest.Costs{
+		BuyCommission:  backtest.Commission{Percent: paperCommission},
+		SellCommission: backtest.Commission{Percent: paperCommission},
+		SellTax:        paperSellTax,
+		LotSize:        paperLotSize,
+	}
+
+	paperEngine, err := paper.NewEngine(services.NewYahooQuoteFeed(), paperCosts, helper.GetEnv("PAPER_STORE_PATH", "data/paper.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	paperMatchInterval, err := time.ParseDuration(helper.GetEnv("PAPER_MATCH_INTERVAL", "5s"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	go paperEngine.Run(context.Background(), paperMatchInterval)
+
+	sectorDictionary, err := services.NewSectorDictionary(helper.GetEnv("PORTFOLIO_SECTORS_PATH", "data/sectors.csv"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	portfolioService, err := services.NewPortfolioService(helper.GetEnv("PORTFOLIO_STORE_PATH", "data/portfolios.json"), helper.GetEnv("PORTFOLIO_CURRENCY", "IDR"), sectorDictionary)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	quoteController := controllers.NewQuoteController()
-	analyzeController := controllers.NewAnalyzeController()
-	sentimentController := controllers.NewNewsController(sentimentService)
-	simulateController := controllers.NewSimulateController()
+	analyzeController := controllers.NewAnalyzeController(newsSentimentService)
+	sentimentController := controllers.NewNewsController(newsStore, newsSentimentService)
+	simulateController := controllers.NewSimulateController(helper.GetEnv("SIMULATE_BENCHMARK", "JKSE"), corporateActionService)
+	corporateActionController := controllers.NewCorporateActionController(corporateActionService)
+	jobController := controllers.NewJobController(jobService)
+	paperController := controllers.NewPaperController(paperEngine)
+	portfolioController := controllers.NewPortfolioController(portfolioService)
+
+	adminOnly := helper.AdminOnly(helper.GetEnv("ADMIN_TOKEN", ""))
 
 	router := r.Group("/api/v1")
 	{
 		// Quote
 		router.GET("/quote", quoteController.GetQuote)
 		router.GET("/index", quoteController.GetIndex)
+		router.GET("/quote/history", corporateActionController.GetPriceHistory)
+
+		// Corporate actions
+		router.GET("/corporate-actions", corporateActionController.GetCorporateActions)
+		router.POST("/corporate-actions", adminOnly, corporateActionController.SaveCorporateActions)
 
 		// Analyze
 		router.GET("/analyze", analyzeController.GetAnalyze)
@@ -30,11 +183,48 @@ func main() {
 		router.GET("/analyze/fundamental", analyzeController.GetFundamental)
 
 		// News
+		router.GET("/news", sentimentController.GetNews)
 		router.GET("/news/sentiment", sentimentController.GetSentiment)
+		router.GET("/news/sentiment/timeseries", sentimentController.GetSentimentTimeSeries)
+		router.POST("/news/sentiment/train", adminOnly, sentimentController.TrainSentiment)
 
 		// SImulate
 		router.GET("/simulate", simulateController.GetSimulate)
+		router.GET("/simulate/strategies", simulateController.GetStrategies)
+		router.GET("/simulate/optimize", simulateController.GetOptimize)
+		router.GET("/simulate/walkforward", simulateController.GetWalkForward)
+		router.GET("/simulate/portfolio", simulateController.GetPortfolioSimulate)
+
+		// Jobs
+		router.POST("/jobs", jobController.SubmitJob)
+		router.GET("/jobs", jobController.GetJobs)
+		router.GET("/jobs/:id", jobController.GetJob)
+		router.DELETE("/jobs/:id", jobController.CancelJob)
+
+		// Paper trading
+		router.POST("/accounts", paperController.CreateAccount)
+		router.GET("/accounts", paperController.GetAccounts)
+		router.GET("/accounts/:id", paperController.GetAccount)
+		router.GET("/accounts/:id/positions", paperController.GetPositions)
+		router.GET("/accounts/:id/pnl", paperController.GetPnL)
+		router.GET("/accounts/:id/balance", paperController.GetBalance)
+		router.POST("/orders", paperController.PlaceOrder)
+		router.GET("/orders", paperController.GetOrders)
+		router.GET("/orders/:id", paperController.GetOrder)
+		router.PATCH("/orders/:id", paperController.AmendOrder)
+		router.DELETE("/orders/:id", paperController.CancelOrder)
+
+		// Portfolio
+		router.POST("/portfolios", portfolioController.CreatePortfolio)
+		router.GET("/portfolios", portfolioController.GetPortfolios)
+		router.GET("/portfolios/:id", portfolioController.GetPortfolio)
+		router.POST("/portfolios/:id/transactions", portfolioController.AddTransactions)
+		router.DELETE("/portfolios/:id/transactions/:transactionId", portfolioController.DeleteTransaction)
+		router.GET("/portfolios/:id/holdings", portfolioController.GetHoldings)
+		router.GET("/portfolios/:id/allocation", portfolioController.GetAllocation)
 	}
 
+	jobService.Start(context.Background(), r)
+
 	r.Run(":8080")
 }
@@ -1,33 +1,43 @@
 package models
 
 type AnalyzeRequest struct {
-	Symbol    string `json:"symbol"`
-	StartDate string `json:"startDate"`
-	EndDate   string `json:"endDate"`
+	Symbol          string  `json:"symbol"`
+	StartDate       string  `json:"startDate"`
+	EndDate         string  `json:"endDate"`
+	Sentiment       bool    `json:"sentiment"`
+	SentimentModel  string  `json:"sentimentModel"`
+	SentimentWeight float64 `json:"sentimentWeight" binding:"min=0"`
+	HalfLife        string  `json:"halfLife"`
 }
 
 type RecommendationRequest struct {
-	Symbols   string `json:"symbols"`
-	StartDate string `json:"startDate"`
-	EndDate   string `json:"endDate"`
+	Symbols         string  `json:"symbols"`
+	StartDate       string  `json:"startDate"`
+	EndDate         string  `json:"endDate"`
+	Sentiment       bool    `json:"sentiment"`
+	SentimentModel  string  `json:"sentimentModel"`
+	SentimentWeight float64 `json:"sentimentWeight" binding:"min=0"`
+	HalfLife        string  `json:"halfLife"`
 }
 
 type AnalyzeResponse struct {
-	Symbol         string       `json:"symbol"`
-	StartDate      string       `json:"startDate"`
-	EndDate        string       `json:"endDate"`
-	Recommendation string       `json:"recommendation"`
-	Explanation    string       `json:"explanation"`
-	AnalyzeQuote   AnalyzeQuote `json:"analyze"`
+	Symbol         string         `json:"symbol"`
+	StartDate      string         `json:"startDate"`
+	EndDate        string         `json:"endDate"`
+	Recommendation string         `json:"recommendation"`
+	Explanation    string         `json:"explanation"`
+	Sentiment      *SentimentVote `json:"sentiment,omitempty"`
+	AnalyzeQuote   AnalyzeQuote   `json:"analyze"`
 }
 
 type RecommendationResponse struct {
-	Symbol         string  `json:"symbol"`
-	Recommendation string  `json:"recommendation"`
-	LatestClose    float64 `json:"latestClose"`
-	TargetBuy      float64 `json:"targetBuy"`
-	TargetSell     float64 `json:"targetSell"`
-	Explanation    string  `json:"explanation"`
+	Symbol         string         `json:"symbol"`
+	Recommendation string         `json:"recommendation"`
+	LatestClose    float64        `json:"latestClose"`
+	TargetBuy      float64        `json:"targetBuy"`
+	TargetSell     float64        `json:"targetSell"`
+	Explanation    string         `json:"explanation"`
+	Sentiment      *SentimentVote `json:"sentiment,omitempty"`
 }
 
 type ForcestResponse struct {
@@ -59,3 +69,16 @@ type AnalyzeQuote struct {
 	CCI           float64 `json:"cci"`
 	ChaikinAD     float64 `json:"chaikinAD"`
 }
+
+type SentimentVote struct {
+	Model                   string  `json:"model"`
+	HalfLife                string  `json:"halfLife"`
+	Articles                int     `json:"articles"`
+	Score                   float64 `json:"score"`
+	Label                   string  `json:"label"`
+	Weight                  float64 `json:"weight"`
+	Vote                    string  `json:"vote"`
+	TechnicalRecommendation string  `json:"technicalRecommendation"`
+	Recommendation          string  `json:"recommendation"`
+	Changed                 bool    `json:"changed"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "id/projects/market-data/backtest"
+
+type CorporateActionRequest struct {
+	Symbol string `json:"symbol" binding:"required"`
+}
+
+type SplitInput struct {
+	Date  string  `json:"date" binding:"required"`
+	Ratio float64 `json:"ratio" binding:"required,gt=0"`
+}
+
+type DividendInput struct {
+	Date   string  `json:"date" binding:"required"`
+	Amount float64 `json:"amount" binding:"min=0"`
+}
+
+// SaveCorporateActionRequest adds splits and dividends by hand, dated
+// YYYY-MM-DD, for symbols whose Yahoo history is missing or wrong.
+type SaveCorporateActionRequest struct {
+	Symbol    string          `json:"symbol" binding:"required"`
+	Splits    []SplitInput    `json:"splits" binding:"dive"`
+	Dividends []DividendInput `json:"dividends" binding:"dive"`
+}
+
+type PriceHistoryRequest struct {
+	Symbol     string `json:"symbol" binding:"required"`
+	StartDate  string `json:"startDate"`
+	EndDate    string `json:"endDate"`
+	Adjustment string `json:"adjustment"`
+}
+
+type PriceHistoryResponse struct {
+	Symbol     string                    `json:"symbol"`
+	StartDate  string                    `json:"startDate"`
+	EndDate    string                    `json:"endDate"`
+	Adjustment backtest.Adjustment       `json:"adjustment"`
+	Actions    backtest.CorporateActions `json:"actions"`
+	Bars       []backtest.Bar            `json:"bars"`
+}
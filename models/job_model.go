@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job is a simulation run in the background. Request is the body the
+// synchronous endpoint for Type takes, and Result the data it would have
+// answered with. Progress goes from 0 to 100.
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Path       string          `json:"path"`
+	Status     string          `json:"status"`
+	Progress   float64         `json:"progress"`
+	Message    string          `json:"message,omitempty"`
+	Request    json.RawMessage `json:"request"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+}
+
+func (j Job) Done() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+type JobRequest struct {
+	Type    string          `json:"type" binding:"required,oneof=simulate optimize walkforward portfolio"`
+	Request json.RawMessage `json:"request" binding:"required"`
+}
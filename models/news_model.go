@@ -1,19 +1,141 @@
 package models
 
+import "time"
+
 type NewsArticle struct {
-	Title string `json:"title"`
-	Body  string `json:"body"`
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Body        string    `json:"body"`
+	Link        string    `json:"link"`
+	Source      string    `json:"source"`
+	PublishedAt time.Time `json:"publishedAt"`
+	FetchedAt   time.Time `json:"fetchedAt"`
+	Symbols     []string  `json:"symbols"`
+}
+
+type SentimentRequest struct {
+	Symbol   string `json:"symbol"`
+	Model    string `json:"model"`
+	HalfLife string `json:"halfLife"`
 }
 
-type NewsResponse struct {
-	Articles []NewsArticle `json:"articles"`
+type SentimentTimeSeriesRequest struct {
+	Symbol    string `json:"symbol"`
+	Model     string `json:"model"`
+	HalfLife  string `json:"halfLife"`
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
 }
 
-type NewsAPIParams struct {
-	SearchTerm string `url:"searchTerm"`
+type NewsListRequest struct {
+	Symbol    string `json:"symbol" binding:"required"`
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+	Sources   string `json:"sources"`
+	Model     string `json:"model"`
+	Page      int    `json:"page" binding:"omitempty,min=1,max=100000"`
+	Limit     int    `json:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type NewsItem struct {
+	ID          string           `json:"id"`
+	Title       string           `json:"title"`
+	Body        string           `json:"body"`
+	Link        string           `json:"link"`
+	Source      string           `json:"source"`
+	PublishedAt time.Time        `json:"publishedAt"`
+	Symbols     []string         `json:"symbols"`
+	Sentiment   ArticleSentiment `json:"sentiment"`
+}
+
+type NewsListResponse struct {
+	Symbol     string     `json:"symbol"`
+	Model      string     `json:"model"`
+	Page       int        `json:"page"`
+	Limit      int        `json:"limit"`
+	Total      int        `json:"total"`
+	TotalPages int        `json:"totalPages"`
+	Articles   []NewsItem `json:"articles"`
+}
+
+// LexiconScore counts the words of every Loughran-McDonald category in a
+// text.
+type LexiconScore struct {
+	Positive    int `json:"positive"`
+	Negative    int `json:"negative"`
+	Uncertainty int `json:"uncertainty"`
+	Litigious   int `json:"litigious"`
+}
+
+// ArticleSentiment is an article scored by a sentiment model. Categories is
+// only set by the lexicon model.
+type ArticleSentiment struct {
+	Title       string        `json:"title"`
+	Source      string        `json:"source"`
+	PublishedAt time.Time     `json:"publishedAt"`
+	Score       int           `json:"score"`
+	Probability float64       `json:"probability"`
+	Label       string        `json:"label"`
+	Categories  *LexiconScore `json:"categories,omitempty"`
+}
+
+// SentimentStatistics aggregates scored articles. UncertaintyWords and
+// LitigiousWords are only counted by the lexicon model.
+type SentimentStatistics struct {
+	Count            int     `json:"count"`
+	Positive         int     `json:"positive"`
+	Negative         int     `json:"negative"`
+	Neutral          int     `json:"neutral"`
+	TotalScore       float64 `json:"totalScore"`
+	MeanScore        float64 `json:"meanScore"`
+	WeightedScore    float64 `json:"weightedScore"`
+	DecayedScore     float64 `json:"decayedScore"`
+	MeanProbability  float64 `json:"meanProbability"`
+	PositiveRatio    float64 `json:"positiveRatio"`
+	NegativeRatio    float64 `json:"negativeRatio"`
+	UncertaintyWords int     `json:"uncertaintyWords,omitempty"`
+	LitigiousWords   int     `json:"litigiousWords,omitempty"`
 }
 
 type SentimentResponse struct {
-	Symbol    string `json:"symbol"`
-	Sentiment string `json:"sentiment"`
+	Symbol     string              `json:"symbol"`
+	Model      string              `json:"model"`
+	HalfLife   string              `json:"halfLife"`
+	Sentiment  string              `json:"sentiment"`
+	Statistics SentimentStatistics `json:"statistics"`
+	Articles   []ArticleSentiment  `json:"articles"`
+}
+
+type SentimentTrainSample struct {
+	Label string `json:"label" binding:"required,oneof=positive negative"`
+	Text  string `json:"text" binding:"required"`
+}
+
+type SentimentTrainRequest struct {
+	Model   string                 `json:"model"`
+	Samples []SentimentTrainSample `json:"samples" binding:"required,min=1,dive"`
+}
+
+type SentimentTrainResponse struct {
+	Trained int `json:"trained"`
+	Learned int `json:"learned"`
+}
+
+type SentimentPoint struct {
+	Date          string  `json:"date"`
+	Count         int     `json:"count"`
+	MeanScore     float64 `json:"meanScore"`
+	WeightedScore float64 `json:"weightedScore"`
+	DecayedScore  float64 `json:"decayedScore"`
+}
+
+type SentimentTimeSeriesResponse struct {
+	Symbol    string           `json:"symbol"`
+	Model     string           `json:"model"`
+	HalfLife  string           `json:"halfLife"`
+	StartDate string           `json:"startDate"`
+	EndDate   string           `json:"endDate"`
+	Trend     string           `json:"trend"`
+	Slope     float64          `json:"slope"`
+	Points    []SentimentPoint `json:"points"`
 }
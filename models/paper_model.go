@@ -0,0 +1,27 @@
+package models
+
+type PaperAccountRequest struct {
+	Name string  `json:"name"`
+	Cash float64 `json:"cash" binding:"required,gt=0"`
+}
+
+// PaperOrderRequest places an order on a paper trading account. Type is
+// market (default), limit, stop or stopLimit and TimeInForce is day
+// (default), gtc, ioc or fok.
+type PaperOrderRequest struct {
+	AccountID   string  `json:"accountId" binding:"required"`
+	Symbol      string  `json:"symbol" binding:"required"`
+	Side        string  `json:"side" binding:"required"`
+	Type        string  `json:"type"`
+	TimeInForce string  `json:"timeInForce"`
+	Quantity    float64 `json:"quantity" binding:"required,gt=0"`
+	LimitPrice  float64 `json:"limitPrice" binding:"min=0"`
+	StopPrice   float64 `json:"stopPrice" binding:"min=0"`
+}
+
+// PaperAmendRequest changes an open order; fields left out keep their value.
+type PaperAmendRequest struct {
+	Quantity   float64 `json:"quantity" binding:"min=0"`
+	LimitPrice float64 `json:"limitPrice" binding:"min=0"`
+	StopPrice  float64 `json:"stopPrice" binding:"min=0"`
+}
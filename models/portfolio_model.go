@@ -0,0 +1,35 @@
+package models
+
+import "id/projects/market-data/portfolio"
+
+type PortfolioRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Currency string `json:"currency"`
+}
+
+// TransactionInput records a buy, sell, dividend or fee dated YYYY-MM-DD.
+// Buys and sells take quantity, price and fee; dividends and fees take
+// amount.
+type TransactionInput struct {
+	Type     string  `json:"type" binding:"required"`
+	Symbol   string  `json:"symbol"`
+	Date     string  `json:"date" binding:"required"`
+	Quantity float64 `json:"quantity" binding:"min=0"`
+	Price    float64 `json:"price" binding:"min=0"`
+	Fee      float64 `json:"fee" binding:"min=0"`
+	Amount   float64 `json:"amount" binding:"min=0"`
+	Currency string  `json:"currency"`
+	Note     string  `json:"note"`
+}
+
+type TransactionRequest struct {
+	Transactions []TransactionInput `json:"transactions" binding:"required,min=1,dive"`
+}
+
+type AllocationResponse struct {
+	PortfolioID string                      `json:"portfolioId"`
+	Currency    string                      `json:"currency"`
+	MarketValue float64                     `json:"marketValue"`
+	Sectors     []portfolio.AllocationEntry `json:"sectors"`
+	Currencies  []portfolio.AllocationEntry `json:"currencies"`
+}
@@ -1,17 +1,160 @@
 package models
 
+import (
+	"id/projects/market-data/backtest"
+	"id/projects/market-data/backtest/rules"
+	"id/projects/market-data/backtest/strategies"
+)
+
+// BacktestRequest holds the fields shared by every backtesting endpoint.
+type BacktestRequest struct {
+	Symbol       string          `json:"symbol"`
+	StartDate    string          `json:"startDate"`
+	EndDate      string          `json:"endDate"`
+	Cash         string          `json:"cash"`
+	Fill         string          `json:"fill"`
+	RiskFreeRate string          `json:"riskFreeRate"`
+	Costs        backtest.Costs  `json:"costs"`
+	Margin       backtest.Margin `json:"margin"`
+	Sizing       backtest.Sizing `json:"sizing"`
+	Adjustment   string          `json:"adjustment"`
+}
+
+// ExportRequest asks for a CSV download instead of the JSON response: the
+// trade blotter (export "trades", the default) or the equity curve
+// ("equity"). Format may also be given as a query parameter, or replaced by
+// an "Accept: text/csv" header.
+type ExportRequest struct {
+	Format string `json:"format"`
+	Export string `json:"export"`
+}
+
 type SimulationRequest struct {
-	Symbol    string `json:"symbol"`
-	StartDate string `json:"startDate"`
-	EndDate   string `json:"endDate"`
-	Cash      string `json:"cash"`
-	BuyPrice  string `json:"buyPrice"`
-	SellPrice string `json:"sellPrice"`
+	BacktestRequest
+	ExportRequest
+	BuyPrice     string             `json:"buyPrice"`
+	SellPrice    string             `json:"sellPrice"`
+	Benchmark    string             `json:"benchmark"`
+	Strategy     *rules.Definition  `json:"strategy"`
+	StrategyName string             `json:"strategyName"`
+	Params       map[string]float64 `json:"params"`
 }
 
 type SimulationResponse struct {
-	Cash      float64 `json:"cash"`
-	Shares    float64 `json:"shares"`
-	GainLoss  float64 `json:"gainLoss"`
-	TotalCost float64 `json:"totalCost"`
+	Symbol      string                    `json:"symbol"`
+	StartDate   string                    `json:"startDate"`
+	EndDate     string                    `json:"endDate"`
+	InitialCash float64                   `json:"initialCash"`
+	FinalEquity float64                   `json:"finalEquity"`
+	Cash        float64                   `json:"cash"`
+	Shares      float64                   `json:"shares"`
+	GainLoss    float64                   `json:"gainLoss"`
+	TotalCost   float64                   `json:"totalCost"`
+	Fees        float64                   `json:"fees"`
+	Adjustment  backtest.Adjustment       `json:"adjustment"`
+	BorrowFees  float64                   `json:"borrowFees"`
+	Interest    float64                   `json:"interest"`
+	Dividends   float64                   `json:"dividends"`
+	MarginCalls int                       `json:"marginCalls"`
+	Strategy    string                    `json:"strategy"`
+	Params      map[string]float64        `json:"params,omitempty"`
+	Rules       *rules.Definition         `json:"rules,omitempty"`
+	Metrics     backtest.Metrics          `json:"metrics"`
+	BuyAndHold  backtest.BenchmarkResult  `json:"buyAndHold"`
+	Benchmark   *backtest.BenchmarkResult `json:"benchmark,omitempty"`
+	Fills       []backtest.Fill           `json:"fills"`
+	Trades      []backtest.Trade          `json:"trades"`
+	Equity      []backtest.EquityPoint    `json:"equity"`
+}
+
+type StrategyListResponse struct {
+	Strategies []strategies.Spec     `json:"strategies"`
+	Indicators []rules.IndicatorInfo `json:"indicators"`
+}
+
+// SearchRequest describes a parameter search over a library strategy.
+type SearchRequest struct {
+	StrategyName string                `json:"strategyName" binding:"required"`
+	Params       map[string]float64    `json:"params"`
+	Ranges       []backtest.ParamRange `json:"ranges" binding:"required"`
+	Search       string                `json:"search"`
+	Samples      int                   `json:"samples"`
+	Seed         int64                 `json:"seed"`
+	Objective    string                `json:"objective"`
+	MaxDrawdown  float64               `json:"maxDrawdown"`
+}
+
+type OptimizeRequest struct {
+	BacktestRequest
+	SearchRequest
+	Heatmap []string `json:"heatmap"`
+	Top     int      `json:"top"`
+}
+
+type OptimizeResponse struct {
+	Symbol    string             `json:"symbol"`
+	StartDate string             `json:"startDate"`
+	EndDate   string             `json:"endDate"`
+	Strategy  string             `json:"strategy"`
+	Objective backtest.Objective `json:"objective"`
+	Search    backtest.Search    `json:"search"`
+	Evaluated int                `json:"evaluated"`
+	Feasible  int                `json:"feasible"`
+	Best      *backtest.Trial    `json:"best"`
+	Trials    []backtest.Trial   `json:"trials"`
+	Heatmap   *backtest.Heatmap  `json:"heatmap,omitempty"`
+}
+
+type WalkForwardRequest struct {
+	BacktestRequest
+	SearchRequest
+	ExportRequest
+	InSample    int    `json:"inSample"`
+	OutOfSample int    `json:"outOfSample"`
+	Mode        string `json:"mode"`
+}
+
+type WalkForwardResponse struct {
+	Symbol      string `json:"symbol"`
+	StartDate   string `json:"startDate"`
+	EndDate     string `json:"endDate"`
+	Strategy    string `json:"strategy"`
+	InSample    int    `json:"inSample"`
+	OutOfSample int    `json:"outOfSample"`
+	backtest.WalkForwardResult
+}
+
+// PortfolioSimulationRequest takes the margin and adjustment of a
+// BacktestRequest. Sizing is only there to be rejected: the target weights
+// size every position.
+type PortfolioSimulationRequest struct {
+	Symbols            string          `json:"symbols" binding:"required"`
+	StartDate          string          `json:"startDate"`
+	EndDate            string          `json:"endDate"`
+	Cash               string          `json:"cash"`
+	Fill               string          `json:"fill"`
+	RiskFreeRate       string          `json:"riskFreeRate"`
+	Costs              backtest.Costs  `json:"costs"`
+	Weighting          string          `json:"weighting"`
+	Rebalance          string          `json:"rebalance"`
+	TopN               int             `json:"topN"`
+	MaxPositions       int             `json:"maxPositions"`
+	MaxWeight          float64         `json:"maxWeight"`
+	VolatilityLookback int             `json:"volatilityLookback"`
+	Signal             string          `json:"signal"`
+	Filter             string          `json:"filter"`
+	Margin             backtest.Margin `json:"margin"`
+	Sizing             backtest.Sizing `json:"sizing"`
+	Adjustment         string          `json:"adjustment"`
+	ExportRequest
+}
+
+type PortfolioSimulationResponse struct {
+	StartDate string             `json:"startDate"`
+	EndDate   string             `json:"endDate"`
+	Weighting backtest.Weighting `json:"weighting"`
+	Rebalance backtest.Rebalance `json:"rebalance"`
+	Signal    string             `json:"signal"`
+	Filter    string             `json:"filter,omitempty"`
+	backtest.PortfolioResult
 }
@@ -0,0 +1,77 @@
+package paper
+
+import "time"
+
+type Position struct {
+	Symbol      string  `json:"symbol"`
+	Quantity    float64 `json:"quantity"`
+	AvgPrice    float64 `json:"avgPrice"`
+	RealizedPnL float64 `json:"realizedPnl"`
+}
+
+// Account is a cash account; it cannot go short or borrow. Positions that
+// were closed stay listed with their realised P&L and a zero quantity.
+type Account struct {
+	ID          string               `json:"id"`
+	Name        string               `json:"name"`
+	InitialCash float64              `json:"initialCash"`
+	Cash        float64              `json:"cash"`
+	RealizedPnL float64              `json:"realizedPnl"`
+	Fees        float64              `json:"fees"`
+	Positions   map[string]*Position `json:"positions"`
+	CreatedAt   time.Time            `json:"createdAt"`
+}
+
+func (a *Account) copy() Account {
+	c := *a
+	c.Positions = make(map[string]*Position, len(a.Positions))
+	for symbol, position := range a.Positions {
+		p := *position
+		c.Positions[symbol] = &p
+	}
+	return c
+}
+
+func (a *Account) position(symbol string) *Position {
+	position, ok := a.Positions[symbol]
+	if !ok {
+		position = &Position{Symbol: symbol}
+		a.Positions[symbol] = position
+	}
+	return position
+}
+
+// PositionValue is a position marked to the latest quote.
+type PositionValue struct {
+	Position
+	Last             float64 `json:"last"`
+	MarketValue      float64 `json:"marketValue"`
+	CostBasis        float64 `json:"costBasis"`
+	UnrealizedPnL    float64 `json:"unrealizedPnl"`
+	UnrealizedReturn float64 `json:"unrealizedReturn"`
+	Error            string  `json:"error,omitempty"`
+}
+
+// Valuation is an account marked to the latest quotes.
+type Valuation struct {
+	AccountID     string          `json:"accountId"`
+	Cash          float64         `json:"cash"`
+	MarketValue   float64         `json:"marketValue"`
+	Equity        float64         `json:"equity"`
+	RealizedPnL   float64         `json:"realizedPnl"`
+	UnrealizedPnL float64         `json:"unrealizedPnl"`
+	TotalPnL      float64         `json:"totalPnl"`
+	Return        float64         `json:"return"`
+	Fees          float64         `json:"fees"`
+	Positions     []PositionValue `json:"positions"`
+}
+
+// Balance is the cash of an account. Available leaves out the cash set aside
+// for open buy orders.
+type Balance struct {
+	AccountID   string  `json:"accountId"`
+	Cash        float64 `json:"cash"`
+	Available   float64 `json:"available"`
+	MarketValue float64 `json:"marketValue"`
+	Equity      float64 `json:"equity"`
+}
@@ -0,0 +1,718 @@
+package paper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"id/projects/market-data/backtest"
+	"id/projects/market-data/helper"
+)
+
+const epsilon = 1e-9
+
+// Engine keeps the accounts and orders, matches open orders against the
+// quote feed and persists everything as JSON at path. Fees and lot sizes
+// follow Costs; slippage is left to the spread between bid and ask.
+type Engine struct {
+	feed        QuoteFeed
+	costs       backtest.Costs
+	path        string
+	accounts    map[string]*Account
+	orders      map[string]*Order
+	quotes      map[string]Quote
+	subscribers map[chan ExecutionReport]struct{}
+	mu          sync.Mutex
+}
+
+// engineState is what is stored at path. Quotes are the latest seen for
+// every symbol, which open market orders are reserved at.
+type engineState struct {
+	Accounts map[string]*Account `json:"accounts"`
+	Orders   map[string]*Order   `json:"orders"`
+	Quotes   map[string]Quote    `json:"quotes,omitempty"`
+}
+
+func NewEngine(feed QuoteFeed, costs backtest.Costs, path string) (*Engine, error) {
+	if err := costs.Validate(); err != nil {
+		return nil, err
+	}
+
+	e := &Engine{
+		feed:        feed,
+		costs:       costs,
+		path:        path,
+		accounts:    make(map[string]*Account),
+		orders:      make(map[string]*Order),
+		quotes:      make(map[string]Quote),
+		subscribers: make(map[chan ExecutionReport]struct{}),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open paper trading store: %w", err)
+	}
+
+	var state engineState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("read paper trading store: %w", err)
+	}
+	if state.Accounts != nil {
+		e.accounts = state.Accounts
+	}
+	if state.Orders != nil {
+		e.orders = state.Orders
+	}
+	if state.Quotes != nil {
+		e.quotes = state.Quotes
+	}
+
+	return e, nil
+}
+
+func (e *Engine) CreateAccount(name string, cash float64) (Account, error) {
+	if cash <= 0 {
+		return Account{}, ErrInvalidCash
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Account{}, err
+	}
+
+	account := &Account{
+		ID:          id,
+		Name:        name,
+		InitialCash: cash,
+		Cash:        cash,
+		Positions:   make(map[string]*Position),
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.accounts[id] = account
+	return account.copy(), e.write()
+}
+
+func (e *Engine) Account(id string) (Account, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	account, ok := e.accounts[id]
+	if !ok {
+		return Account{}, ErrAccountNotFound
+	}
+	return account.copy(), nil
+}
+
+func (e *Engine) Accounts() []Account {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	accounts := make([]Account, 0, len(e.accounts))
+	for _, account := range e.accounts {
+		accounts = append(accounts, account.copy())
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].CreatedAt.Before(accounts[j].CreatedAt)
+	})
+	return accounts
+}
+
+// Positions returns the open positions of an account by symbol.
+func (e *Engine) Positions(ctx context.Context, accountID string) ([]Position, error) {
+	account, err := e.Account(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := []Position{}
+	for _, position := range account.Positions {
+		if position.Quantity > epsilon {
+			positions = append(positions, *position)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Symbol < positions[j].Symbol
+	})
+	return positions, nil
+}
+
+// Balance returns the cash of an account, what of it is free for new orders
+// and its equity at the latest quotes.
+func (e *Engine) Balance(ctx context.Context, accountID string) (Balance, error) {
+	valuation, err := e.Valuation(ctx, accountID)
+	if err != nil {
+		return Balance{}, err
+	}
+
+	e.mu.Lock()
+	available := e.availableCash(e.accounts[accountID], "")
+	e.mu.Unlock()
+
+	return Balance{
+		AccountID:   accountID,
+		Cash:        valuation.Cash,
+		Available:   available,
+		MarketValue: valuation.MarketValue,
+		Equity:      valuation.Equity,
+	}, nil
+}
+
+func (e *Engine) Order(id string) (Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	order, ok := e.orders[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+	return order.copy(), nil
+}
+
+// Orders returns the orders of accountID, or of every account when it is
+// empty, with status unless that is empty too, newest first.
+func (e *Engine) Orders(accountID string, status Status) []Order {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	orders := []Order{}
+	for _, order := range e.orders {
+		if (accountID == "" || order.AccountID == accountID) && (status == "" || order.Status == status) {
+			orders = append(orders, order.copy())
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].CreatedAt.After(orders[j].CreatedAt)
+	})
+	return orders
+}
+
+// Place checks order against its account and matches it right away against
+// the latest quote. An order the account cannot honour is stored as
+// rejected rather than returned as an error; errors are for orders that are
+// malformed or name an unknown account.
+func (e *Engine) Place(ctx context.Context, order Order) (Order, error) {
+	// orders may come straight off the wire, so the enums are checked too
+	side, err := ParseSide(string(order.Side))
+	if err != nil {
+		return Order{}, err
+	}
+	orderType, err := ParseOrderType(string(order.Type))
+	if err != nil {
+		return Order{}, err
+	}
+	timeInForce, err := ParseTimeInForce(string(order.TimeInForce))
+	if err != nil {
+		return Order{}, err
+	}
+
+	order.Symbol = strings.ToUpper(strings.TrimSpace(order.Symbol))
+	order.Side, order.Type, order.TimeInForce = side, orderType, timeInForce
+	if err := order.validate(e.costs); err != nil {
+		return Order{}, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Order{}, err
+	}
+
+	quote, quoteErr := e.feed.Quote(ctx, order.Symbol)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	account, ok := e.accounts[order.AccountID]
+	if !ok {
+		return Order{}, ErrAccountNotFound
+	}
+	if quoteErr == nil {
+		e.remember(order.Symbol, quote)
+	}
+
+	now := time.Now().UTC()
+	o := &order
+	o.ID = id
+	o.Status = New
+	o.FilledQuantity, o.AvgFillPrice, o.Fees = 0, 0, 0
+	o.Triggered, o.Reason, o.Executions = false, "", nil
+	o.CreatedAt, o.UpdatedAt = now, now
+
+	if quoteErr != nil && o.Type == Market {
+		e.close(o, Rejected, "no quote: "+quoteErr.Error())
+	} else if reason := e.check(account, o); reason != "" {
+		e.close(o, Rejected, reason)
+	}
+
+	e.orders[id] = o
+	if o.Open() {
+		e.report(ReportNew, o, nil)
+	}
+
+	if o.Open() && quoteErr == nil {
+		e.match(o, quote)
+	}
+
+	return o.copy(), e.write()
+}
+
+// Amend changes the quantity or prices of an open order; zero fields are
+// left as they are. The quantity may not drop to what has already filled,
+// and the amended order must still be covered by the account. The order is
+// matched again right away, as its new price may make it marketable.
+func (e *Engine) Amend(ctx context.Context, id string, amendment Amendment) (Order, error) {
+	if amendment.Quantity < 0 || amendment.LimitPrice < 0 || amendment.StopPrice < 0 {
+		return Order{}, fmt.Errorf("%w: quantity and prices must not be negative", ErrInvalidOrder)
+	}
+
+	current, err := e.Order(id)
+	if err != nil {
+		return Order{}, err
+	}
+	quote, quoteErr := e.feed.Quote(ctx, current.Symbol)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	order, ok := e.orders[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+	if !order.Open() {
+		return order.copy(), ErrOrderClosed
+	}
+	if quoteErr == nil {
+		e.remember(order.Symbol, quote)
+	}
+
+	amended := order.copy()
+	if amendment.Quantity > 0 {
+		amended.Quantity = amendment.Quantity
+	}
+	if amendment.LimitPrice > 0 {
+		amended.LimitPrice = amendment.LimitPrice
+	}
+	if amendment.StopPrice > 0 {
+		amended.StopPrice = amendment.StopPrice
+	}
+
+	if amended.Quantity <= amended.FilledQuantity+epsilon {
+		return order.copy(), fmt.Errorf("%w: quantity must stay above the %g already filled", ErrInvalidOrder, amended.FilledQuantity)
+	}
+	if err := amended.validate(e.costs); err != nil {
+		return order.copy(), err
+	}
+	if reason := e.check(e.accounts[order.AccountID], &amended); reason != "" {
+		return order.copy(), fmt.Errorf("%w: %s", ErrRejected, reason)
+	}
+
+	order.Quantity, order.LimitPrice, order.StopPrice = amended.Quantity, amended.LimitPrice, amended.StopPrice
+	order.UpdatedAt = time.Now().UTC()
+	e.report(ReportReplaced, order, nil)
+
+	if quoteErr == nil {
+		e.match(order, quote)
+	}
+
+	return order.copy(), e.write()
+}
+
+// Cancel cancels what is left of an open order.
+func (e *Engine) Cancel(ctx context.Context, id string) (Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	order, ok := e.orders[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+	if !order.Open() {
+		return order.copy(), ErrOrderClosed
+	}
+
+	e.close(order, Cancelled, "cancelled by request")
+	return order.copy(), e.write()
+}
+
+// Match matches every open order against the latest quote of its symbol.
+// A symbol whose quote cannot be fetched is skipped; the first such error is
+// returned after the others were matched.
+func (e *Engine) Match(ctx context.Context) error {
+	e.mu.Lock()
+	symbols := make(map[string]bool)
+	for _, order := range e.orders {
+		if order.Open() {
+			symbols[order.Symbol] = true
+		}
+	}
+	e.mu.Unlock()
+
+	if len(symbols) == 0 {
+		return nil
+	}
+
+	quotes := make(map[string]Quote, len(symbols))
+	var firstErr error
+	for symbol := range symbols {
+		quote, err := e.feed.Quote(ctx, symbol)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("quote %s: %w", symbol, err)
+			}
+			continue
+		}
+		quotes[symbol] = quote
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for symbol, quote := range quotes {
+		e.remember(symbol, quote)
+	}
+
+	var open []*Order
+	for _, order := range e.orders {
+		if _, ok := quotes[order.Symbol]; ok && order.Open() {
+			open = append(open, order)
+		}
+	}
+	// first come, first served
+	sort.Slice(open, func(i, j int) bool {
+		return open[i].CreatedAt.Before(open[j].CreatedAt)
+	})
+	for _, order := range open {
+		e.match(order, quotes[order.Symbol])
+	}
+
+	if err := e.write(); err != nil {
+		return err
+	}
+	return firstErr
+}
+
+// Run matches the open orders every interval until ctx is cancelled.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Match(ctx); err != nil {
+				log.Printf("paper trading: %v", err)
+			}
+		}
+	}
+}
+
+// Valuation marks the positions of an account to the latest quotes. A
+// position whose quote cannot be fetched is valued at its average price.
+func (e *Engine) Valuation(ctx context.Context, accountID string) (Valuation, error) {
+	account, err := e.Account(accountID)
+	if err != nil {
+		return Valuation{}, err
+	}
+
+	valuation := Valuation{
+		AccountID:   account.ID,
+		Cash:        account.Cash,
+		RealizedPnL: account.RealizedPnL,
+		Fees:        account.Fees,
+		Positions:   []PositionValue{},
+	}
+
+	symbols := make([]string, 0, len(account.Positions))
+	for symbol := range account.Positions {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	for _, symbol := range symbols {
+		position := account.Positions[symbol]
+		value := PositionValue{Position: *position, Last: position.AvgPrice}
+
+		if position.Quantity > epsilon {
+			quote, err := e.feed.Quote(ctx, symbol)
+			if err != nil {
+				value.Error = err.Error()
+			} else if last := quote.last(); last > 0 {
+				value.Last = last
+			}
+
+			value.MarketValue = position.Quantity * value.Last
+			value.CostBasis = position.Quantity * position.AvgPrice
+			value.UnrealizedPnL = value.MarketValue - value.CostBasis
+			if value.CostBasis > 0 {
+				value.UnrealizedReturn = value.UnrealizedPnL / value.CostBasis
+			}
+		}
+
+		valuation.MarketValue += value.MarketValue
+		valuation.UnrealizedPnL += value.UnrealizedPnL
+		valuation.Positions = append(valuation.Positions, value)
+	}
+
+	valuation.Equity = valuation.Cash + valuation.MarketValue
+	valuation.TotalPnL = valuation.RealizedPnL + valuation.UnrealizedPnL
+	if account.InitialCash > 0 {
+		valuation.Return = (valuation.Equity - account.InitialCash) / account.InitialCash
+	}
+
+	return valuation, nil
+}
+
+// match fills as much of order as quote allows. It must be called with the
+// lock held.
+func (e *Engine) match(order *Order, quote Quote) {
+	if !order.Open() {
+		return
+	}
+
+	if order.TimeInForce == Day && nextDay(order.CreatedAt, quote.Time) {
+		e.close(order, Cancelled, "expired at the end of the day")
+		return
+	}
+
+	e.fill(order, quote)
+
+	if order.Open() && (order.TimeInForce == IOC || order.TimeInForce == FOK) {
+		e.close(order, Cancelled, "not filled immediately")
+	}
+}
+
+func (e *Engine) fill(order *Order, quote Quote) {
+	if (order.Type == Stop || order.Type == StopLimit) && !order.Triggered {
+		last := quote.last()
+		if last <= 0 {
+			return
+		}
+		if (order.Side == backtest.Buy && last >= order.StopPrice) || (order.Side == backtest.Sell && last <= order.StopPrice) {
+			order.Triggered = true
+			order.UpdatedAt = time.Now().UTC()
+		} else {
+			return
+		}
+	}
+
+	price, size := quote.buyPrice(), quote.AskSize
+	if order.Side == backtest.Sell {
+		price, size = quote.sellPrice(), quote.BidSize
+	}
+	if price <= 0 {
+		return
+	}
+
+	if order.Type == Limit || order.Type == StopLimit {
+		if (order.Side == backtest.Buy && price > order.LimitPrice) || (order.Side == backtest.Sell && price < order.LimitPrice) {
+			return
+		}
+	}
+
+	quantity := order.Remaining()
+	if size > 0 && size < quantity {
+		quantity = e.costs.Lots(size)
+	}
+
+	if order.TimeInForce == FOK && quantity < order.Remaining()-epsilon {
+		return
+	}
+
+	account := e.accounts[order.AccountID]
+	position := account.position(order.Symbol)
+
+	limited := ""
+	if order.Side == backtest.Buy {
+		if affordable := e.costs.Affordable(e.availableCash(account, order.ID), price); quantity > affordable {
+			quantity, limited = affordable, "insufficient cash"
+		}
+	} else if quantity > position.Quantity {
+		quantity, limited = e.costs.Lots(position.Quantity), "insufficient position"
+	}
+
+	if limited != "" && order.TimeInForce == FOK {
+		e.close(order, Cancelled, limited)
+		return
+	}
+
+	if quantity > epsilon {
+		e.book(order, account, position, quantity, price)
+	}
+
+	if limited != "" && order.Open() {
+		e.close(order, Cancelled, limited)
+	}
+}
+
+func (e *Engine) book(order *Order, account *Account, position *Position, quantity float64, price float64) {
+	value := quantity * price
+	fee, tax := e.costs.Fee(order.Side, value)
+	now := time.Now().UTC()
+
+	if order.Side == backtest.Buy {
+		account.Cash -= value + fee
+		position.AvgPrice = (position.Quantity*position.AvgPrice + value + fee) / (position.Quantity + quantity)
+		position.Quantity += quantity
+	} else {
+		realized := value - fee - tax - position.AvgPrice*quantity
+		account.Cash += value - fee - tax
+		account.RealizedPnL += realized
+		position.RealizedPnL += realized
+		position.Quantity -= quantity
+		if position.Quantity < epsilon {
+			position.Quantity = 0
+		}
+	}
+	account.Fees += fee + tax
+
+	id, err := newID()
+	if err != nil {
+		id = fmt.Sprintf("%s-%d", order.ID, len(order.Executions)+1)
+	}
+	order.Executions = append(order.Executions, Execution{
+		ID:        id,
+		OrderID:   order.ID,
+		AccountID: order.AccountID,
+		Symbol:    order.Symbol,
+		Side:      order.Side,
+		Quantity:  quantity,
+		Price:     price,
+		Fee:       fee,
+		Tax:       tax,
+		Time:      now,
+	})
+
+	order.AvgFillPrice = (order.AvgFillPrice*order.FilledQuantity + price*quantity) / (order.FilledQuantity + quantity)
+	order.FilledQuantity += quantity
+	order.Fees += fee + tax
+	order.UpdatedAt = now
+	order.Status = PartiallyFilled
+	if order.Remaining() < epsilon {
+		order.Status = Filled
+	}
+
+	e.report(ReportTrade, order, &order.Executions[len(order.Executions)-1])
+}
+
+func (e *Engine) close(order *Order, status Status, reason string) {
+	order.Status = status
+	order.Reason = reason
+	order.UpdatedAt = time.Now().UTC()
+
+	if status == Rejected {
+		e.report(ReportRejected, order, nil)
+	} else {
+		e.report(ReportCancelled, order, nil)
+	}
+}
+
+// check returns why the account cannot cover what is left of order, or ""
+// when it can.
+func (e *Engine) check(account *Account, order *Order) string {
+	if order.Side == backtest.Sell {
+		if order.Remaining() > e.availableQuantity(account, order.Symbol, order.ID)+epsilon {
+			return "insufficient position, short selling is not allowed"
+		}
+		return ""
+	}
+
+	if e.reserve(order) > e.availableCash(account, order.ID)+epsilon {
+		return "insufficient cash"
+	}
+	return ""
+}
+
+// availableCash is the cash not set aside for open buy orders, leaving out
+// the order with ID except.
+func (e *Engine) availableCash(account *Account, except string) float64 {
+	cash := account.Cash
+	for _, order := range e.orders {
+		if order.ID == except || order.AccountID != account.ID || order.Side != backtest.Buy || !order.Open() {
+			continue
+		}
+		cash -= e.reserve(order)
+	}
+	return cash
+}
+
+// reserve is what the rest of a buy order may cost, fees included. It is
+// priced at the limit or stop price, or for a market order at the latest
+// quote of its symbol.
+func (e *Engine) reserve(order *Order) float64 {
+	price := order.LimitPrice
+	if price == 0 {
+		price = order.StopPrice
+	}
+	if price == 0 {
+		price = e.quotes[order.Symbol].buyPrice()
+	}
+
+	value := order.Remaining() * price
+	fee, _ := e.costs.Fee(backtest.Buy, value)
+	return value + fee
+}
+
+// remember keeps quote as the latest of symbol unless a newer one was seen.
+// It must be called with the lock held.
+func (e *Engine) remember(symbol string, quote Quote) {
+	if seen, ok := e.quotes[symbol]; ok && quote.Time.Before(seen.Time) {
+		return
+	}
+	e.quotes[symbol] = quote
+}
+
+// availableQuantity is the position not already offered by open sell
+// orders, leaving out the order with ID except.
+func (e *Engine) availableQuantity(account *Account, symbol string, except string) float64 {
+	held := 0.0
+	if position, ok := account.Positions[symbol]; ok {
+		held = position.Quantity
+	}
+	for _, order := range e.orders {
+		if order.ID != except && order.AccountID == account.ID && order.Symbol == symbol && order.Side == backtest.Sell && order.Open() {
+			held -= order.Remaining()
+		}
+	}
+	return held
+}
+
+// nextDay reports whether quoted falls on a later day than placed, in the
+// quote's time zone.
+func nextDay(placed time.Time, quoted time.Time) bool {
+	if quoted.IsZero() {
+		return false
+	}
+	py, pm, pd := placed.In(quoted.Location()).Date()
+	qy, qm, qd := quoted.Date()
+	return time.Date(qy, qm, qd, 0, 0, 0, 0, time.UTC).After(time.Date(py, pm, pd, 0, 0, 0, 0, time.UTC))
+}
+
+func (e *Engine) write() error {
+	if e.path == "" {
+		return nil
+	}
+
+	if err := helper.WriteFileAtomic(e.path, engineState{Accounts: e.accounts, Orders: e.orders, Quotes: e.quotes}); err != nil {
+		return fmt.Errorf("save paper trading store: %w", err)
+	}
+	return nil
+}
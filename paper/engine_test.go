@@ -0,0 +1,253 @@
+package paper
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"id/projects/market-data/backtest"
+)
+
+func newTestEngine(t *testing.T, costs backtest.Costs, cash float64) (*Engine, *ManualFeed, string) {
+	t.Helper()
+
+	feed := NewManualFeed()
+	engine, err := NewEngine(feed, costs, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account, err := engine.CreateAccount("test", cash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine, feed, account.ID
+}
+
+func place(t *testing.T, engine *Engine, order Order) Order {
+	t.Helper()
+
+	placed, err := engine.Place(context.Background(), order)
+	if err != nil {
+		t.Fatalf("Place: %v", err)
+	}
+	return placed
+}
+
+func match(t *testing.T, engine *Engine) {
+	t.Helper()
+
+	if err := engine.Match(context.Background()); err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+}
+
+func order(t *testing.T, engine *Engine, id string) Order {
+	t.Helper()
+
+	o, err := engine.Order(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func near(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestMarketBuyFillsAtTheAsk(t *testing.T) {
+	costs := backtest.Costs{BuyCommission: backtest.Commission{Percent: 0.001}}
+	engine, feed, accountID := newTestEngine(t, costs, 10000)
+	feed.Set(Quote{Symbol: "BBCA", Bid: 99, Ask: 100, Last: 99.5})
+
+	o := place(t, engine, Order{AccountID: accountID, Symbol: "bbca", Side: backtest.Buy, Type: Market, TimeInForce: GTC, Quantity: 50})
+	if o.Status != Filled || o.FilledQuantity != 50 || o.AvgFillPrice != 100 {
+		t.Fatalf("order = %s %g @ %g, want filled 50 @ 100", o.Status, o.FilledQuantity, o.AvgFillPrice)
+	}
+
+	account, _ := engine.Account(accountID)
+	if !near(account.Cash, 10000-5000-5) {
+		t.Errorf("cash = %g, want 4995", account.Cash)
+	}
+	if position := account.Positions["BBCA"]; position == nil || position.Quantity != 50 || !near(position.AvgPrice, 100.1) {
+		t.Errorf("position = %+v, want 50 @ 100.1", position)
+	}
+}
+
+func TestPartialFillsAcrossQuotes(t *testing.T) {
+	engine, feed, accountID := newTestEngine(t, backtest.Costs{}, 100000)
+	feed.Set(Quote{Symbol: "TLKM", Bid: 99, Ask: 100, AskSize: 300})
+
+	o := place(t, engine, Order{AccountID: accountID, Symbol: "TLKM", Side: backtest.Buy, Type: Limit, TimeInForce: GTC, Quantity: 500, LimitPrice: 102})
+	if o.Status != PartiallyFilled || o.FilledQuantity != 300 {
+		t.Fatalf("order = %s %g, want partially filled 300", o.Status, o.FilledQuantity)
+	}
+
+	feed.Set(Quote{Symbol: "TLKM", Bid: 101, Ask: 102})
+	match(t, engine)
+
+	o = order(t, engine, o.ID)
+	if o.Status != Filled || o.FilledQuantity != 500 || len(o.Executions) != 2 {
+		t.Fatalf("order = %s %g in %d executions, want filled 500 in 2", o.Status, o.FilledQuantity, len(o.Executions))
+	}
+	if want := (300*100 + 200*102) / 500.0; !near(o.AvgFillPrice, want) {
+		t.Errorf("average fill price = %g, want %g", o.AvgFillPrice, want)
+	}
+}
+
+func TestStopTriggersOnTheLastPrice(t *testing.T) {
+	engine, feed, accountID := newTestEngine(t, backtest.Costs{}, 100000)
+	feed.Set(Quote{Symbol: "ASII", Bid: 99, Ask: 101, Last: 100})
+	place(t, engine, Order{AccountID: accountID, Symbol: "ASII", Side: backtest.Buy, Type: Market, TimeInForce: GTC, Quantity: 100})
+
+	stopBuy := place(t, engine, Order{AccountID: accountID, Symbol: "ASII", Side: backtest.Buy, Type: Stop, TimeInForce: GTC, Quantity: 10, StopPrice: 110})
+	stopSell := place(t, engine, Order{AccountID: accountID, Symbol: "ASII", Side: backtest.Sell, Type: Stop, TimeInForce: GTC, Quantity: 100, StopPrice: 90})
+	if stopBuy.Status != New || stopSell.Status != New {
+		t.Fatalf("stops = %s and %s before their price, want both new", stopBuy.Status, stopSell.Status)
+	}
+
+	feed.Set(Quote{Symbol: "ASII", Bid: 111, Ask: 112, Last: 111})
+	match(t, engine)
+	if o := order(t, engine, stopBuy.ID); o.Status != Filled || !o.Triggered || o.AvgFillPrice != 112 {
+		t.Errorf("stop buy = %s triggered %t @ %g, want filled @ 112", o.Status, o.Triggered, o.AvgFillPrice)
+	}
+	if o := order(t, engine, stopSell.ID); o.Status != New {
+		t.Errorf("stop sell = %s above its stop, want new", o.Status)
+	}
+
+	feed.Set(Quote{Symbol: "ASII", Bid: 88, Ask: 89, Last: 89})
+	match(t, engine)
+	if o := order(t, engine, stopSell.ID); o.Status != Filled || o.AvgFillPrice != 88 {
+		t.Errorf("stop sell = %s @ %g, want filled @ 88", o.Status, o.AvgFillPrice)
+	}
+}
+
+func TestDayOrdersExpire(t *testing.T) {
+	engine, feed, accountID := newTestEngine(t, backtest.Costs{}, 100000)
+	now := time.Now()
+	feed.Set(Quote{Symbol: "BBRI", Bid: 99, Ask: 100, Time: now})
+
+	day := place(t, engine, Order{AccountID: accountID, Symbol: "BBRI", Side: backtest.Buy, Type: Limit, TimeInForce: Day, Quantity: 10, LimitPrice: 90})
+	gtc := place(t, engine, Order{AccountID: accountID, Symbol: "BBRI", Side: backtest.Buy, Type: Limit, TimeInForce: GTC, Quantity: 10, LimitPrice: 90})
+
+	feed.Set(Quote{Symbol: "BBRI", Bid: 99, Ask: 100, Time: now.Add(time.Minute)})
+	match(t, engine)
+	if o := order(t, engine, day.ID); o.Status != New {
+		t.Fatalf("day order = %s on the same day, want new", o.Status)
+	}
+
+	feed.Set(Quote{Symbol: "BBRI", Bid: 99, Ask: 100, Time: now.Add(24 * time.Hour)})
+	match(t, engine)
+	if o := order(t, engine, day.ID); o.Status != Cancelled {
+		t.Errorf("day order = %s the next day, want cancelled", o.Status)
+	}
+	if o := order(t, engine, gtc.ID); o.Status != New {
+		t.Errorf("gtc order = %s the next day, want new", o.Status)
+	}
+}
+
+func TestImmediateOrders(t *testing.T) {
+	engine, feed, accountID := newTestEngine(t, backtest.Costs{}, 100000)
+	feed.Set(Quote{Symbol: "UNVR", Bid: 99, Ask: 100, AskSize: 30})
+
+	ioc := place(t, engine, Order{AccountID: accountID, Symbol: "UNVR", Side: backtest.Buy, Type: Market, TimeInForce: IOC, Quantity: 50})
+	if ioc.Status != Cancelled || ioc.FilledQuantity != 30 {
+		t.Errorf("ioc = %s %g, want cancelled after filling 30", ioc.Status, ioc.FilledQuantity)
+	}
+
+	fok := place(t, engine, Order{AccountID: accountID, Symbol: "UNVR", Side: backtest.Buy, Type: Market, TimeInForce: FOK, Quantity: 50})
+	if fok.Status != Cancelled || fok.FilledQuantity != 0 {
+		t.Errorf("fok = %s %g, want cancelled without a fill", fok.Status, fok.FilledQuantity)
+	}
+
+	fok = place(t, engine, Order{AccountID: accountID, Symbol: "UNVR", Side: backtest.Buy, Type: Market, TimeInForce: FOK, Quantity: 30})
+	if fok.Status != Filled {
+		t.Errorf("fok = %s for what the ask offers, want filled", fok.Status)
+	}
+}
+
+func TestProfitAndLoss(t *testing.T) {
+	costs := backtest.Costs{
+		BuyCommission:  backtest.Commission{Percent: 0.001},
+		SellCommission: backtest.Commission{Percent: 0.002},
+		SellTax:        0.001,
+	}
+	engine, feed, accountID := newTestEngine(t, costs, 100000)
+	feed.Set(Quote{Symbol: "ANTM", Bid: 100, Ask: 100, Last: 100})
+	place(t, engine, Order{AccountID: accountID, Symbol: "ANTM", Side: backtest.Buy, Type: Market, TimeInForce: GTC, Quantity: 200})
+
+	feed.Set(Quote{Symbol: "ANTM", Bid: 120, Ask: 120, Last: 120})
+	place(t, engine, Order{AccountID: accountID, Symbol: "ANTM", Side: backtest.Sell, Type: Market, TimeInForce: GTC, Quantity: 100})
+
+	valuation, err := engine.Valuation(context.Background(), accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// bought 200 at 100.1 a share with the commission, sold 100 at 120 less 0.3%
+	realized := 100*120*(1-0.003) - 100*100.1
+	unrealized := 100*120 - 100*100.1
+	if !near(valuation.RealizedPnL, realized) {
+		t.Errorf("realized = %g, want %g", valuation.RealizedPnL, realized)
+	}
+	if !near(valuation.UnrealizedPnL, unrealized) {
+		t.Errorf("unrealized = %g, want %g", valuation.UnrealizedPnL, unrealized)
+	}
+	if !near(valuation.Fees, 20+24+12) {
+		t.Errorf("fees = %g, want 56", valuation.Fees)
+	}
+	if !near(valuation.Equity, 100000+realized+unrealized) {
+		t.Errorf("equity = %g, want %g", valuation.Equity, 100000+realized+unrealized)
+	}
+}
+
+func TestOpenOrdersReserveCash(t *testing.T) {
+	engine, feed, accountID := newTestEngine(t, backtest.Costs{}, 10000)
+	feed.Set(Quote{Symbol: "BMRI", Bid: 59, Ask: 60, AskSize: 50})
+
+	// the 50 shares the ask cannot fill stay reserved at the ask
+	market := place(t, engine, Order{AccountID: accountID, Symbol: "BMRI", Side: backtest.Buy, Type: Market, TimeInForce: GTC, Quantity: 100})
+	if market.Status != PartiallyFilled {
+		t.Fatalf("market order = %s, want partially filled", market.Status)
+	}
+
+	balance, err := engine.Balance(context.Background(), accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(balance.Available, 10000-3000-3000) {
+		t.Errorf("available = %g, want 4000", balance.Available)
+	}
+
+	limit := place(t, engine, Order{AccountID: accountID, Symbol: "BMRI", Side: backtest.Buy, Type: Limit, TimeInForce: GTC, Quantity: 90, LimitPrice: 50})
+	if limit.Status != Rejected {
+		t.Errorf("limit order = %s for more than is available, want rejected", limit.Status)
+	}
+}
+
+func TestBuysAreCappedByAvailableCash(t *testing.T) {
+	engine, feed, accountID := newTestEngine(t, backtest.Costs{LotSize: 1}, 10000)
+	feed.Set(Quote{Symbol: "ADRO", Bid: 99, Ask: 100, Last: 100})
+
+	limit := place(t, engine, Order{AccountID: accountID, Symbol: "ADRO", Side: backtest.Buy, Type: Limit, TimeInForce: GTC, Quantity: 40, LimitPrice: 90})
+	stop := place(t, engine, Order{AccountID: accountID, Symbol: "ADRO", Side: backtest.Buy, Type: Stop, TimeInForce: GTC, Quantity: 60, StopPrice: 105})
+
+	// the stop fills above its stop price and may only spend what the limit order left
+	feed.Set(Quote{Symbol: "ADRO", Bid: 109, Ask: 110, Last: 106})
+	match(t, engine)
+
+	o := order(t, engine, stop.ID)
+	if o.FilledQuantity != 58 || o.Status != Cancelled || o.Reason != "insufficient cash" {
+		t.Errorf("stop = %s %g (%s), want cancelled after 58 for insufficient cash", o.Status, o.FilledQuantity, o.Reason)
+	}
+	if o := order(t, engine, limit.ID); o.Status != New {
+		t.Errorf("limit = %s, want still new", o.Status)
+	}
+
+	account, _ := engine.Account(accountID)
+	if account.Cash < 40*90 {
+		t.Errorf("cash = %g, no longer covers the limit order", account.Cash)
+	}
+}
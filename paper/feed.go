@@ -0,0 +1,83 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quote is the top of the book. A size of zero means the size is unknown and
+// does not limit fills.
+type Quote struct {
+	Symbol  string    `json:"symbol"`
+	Bid     float64   `json:"bid"`
+	Ask     float64   `json:"ask"`
+	BidSize float64   `json:"bidSize"`
+	AskSize float64   `json:"askSize"`
+	Last    float64   `json:"last"`
+	Time    time.Time `json:"time"`
+}
+
+// buyPrice is what a marketable buy pays, falling back to the last price
+// when there is no ask.
+func (q Quote) buyPrice() float64 {
+	if q.Ask > 0 {
+		return q.Ask
+	}
+	return q.Last
+}
+
+func (q Quote) sellPrice() float64 {
+	if q.Bid > 0 {
+		return q.Bid
+	}
+	return q.Last
+}
+
+func (q Quote) last() float64 {
+	if q.Last > 0 {
+		return q.Last
+	}
+	if q.Bid > 0 && q.Ask > 0 {
+		return (q.Bid + q.Ask) / 2
+	}
+	return q.Bid + q.Ask
+}
+
+type QuoteFeed interface {
+	Quote(ctx context.Context, symbol string) (Quote, error)
+}
+
+// ManualFeed serves the quotes it was given, for driving the engine in
+// tests and demos.
+type ManualFeed struct {
+	quotes map[string]Quote
+	mu     sync.RWMutex
+}
+
+func NewManualFeed() *ManualFeed {
+	return &ManualFeed{quotes: make(map[string]Quote)}
+}
+
+func (f *ManualFeed) Set(quote Quote) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if quote.Time.IsZero() {
+		quote.Time = time.Now()
+	}
+	f.quotes[strings.ToUpper(quote.Symbol)] = quote
+}
+
+func (f *ManualFeed) Quote(ctx context.Context, symbol string) (Quote, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	quote, ok := f.quotes[strings.ToUpper(symbol)]
+	if !ok {
+		return Quote{}, fmt.Errorf("no quote for %s", symbol)
+	}
+	return quote, nil
+}
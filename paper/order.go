@@ -0,0 +1,86 @@
+package paper
+
+import (
+	"fmt"
+	"time"
+
+	"id/projects/market-data/backtest"
+)
+
+type Execution struct {
+	ID        string        `json:"id"`
+	OrderID   string        `json:"orderId"`
+	AccountID string        `json:"accountId"`
+	Symbol    string        `json:"symbol"`
+	Side      backtest.Side `json:"side"`
+	Quantity  float64       `json:"quantity"`
+	Price     float64       `json:"price"`
+	Fee       float64       `json:"fee"`
+	Tax       float64       `json:"tax"`
+	Time      time.Time     `json:"time"`
+}
+
+// Order is an instruction to buy or sell. LimitPrice applies to limit and
+// stop-limit orders and StopPrice to stop and stop-limit orders; a stop
+// order becomes a market order, and a stop-limit order a limit order, once
+// the last price trades through StopPrice.
+type Order struct {
+	ID             string        `json:"id"`
+	AccountID      string        `json:"accountId"`
+	Symbol         string        `json:"symbol"`
+	Side           backtest.Side `json:"side"`
+	Type           OrderType     `json:"type"`
+	TimeInForce    TimeInForce   `json:"timeInForce"`
+	Quantity       float64       `json:"quantity"`
+	LimitPrice     float64       `json:"limitPrice,omitempty"`
+	StopPrice      float64       `json:"stopPrice,omitempty"`
+	Status         Status        `json:"status"`
+	Triggered      bool          `json:"triggered,omitempty"`
+	FilledQuantity float64       `json:"filledQuantity"`
+	AvgFillPrice   float64       `json:"avgFillPrice"`
+	Fees           float64       `json:"fees"`
+	Reason         string        `json:"reason,omitempty"`
+	Executions     []Execution   `json:"executions"`
+	CreatedAt      time.Time     `json:"createdAt"`
+	UpdatedAt      time.Time     `json:"updatedAt"`
+}
+
+// Amendment changes an open order; zero fields keep their current value.
+type Amendment struct {
+	Quantity   float64 `json:"quantity"`
+	LimitPrice float64 `json:"limitPrice"`
+	StopPrice  float64 `json:"stopPrice"`
+}
+
+func (o *Order) Open() bool {
+	return o.Status == New || o.Status == PartiallyFilled
+}
+
+func (o *Order) Remaining() float64 {
+	return o.Quantity - o.FilledQuantity
+}
+
+func (o *Order) copy() Order {
+	c := *o
+	c.Executions = append([]Execution(nil), o.Executions...)
+	return c
+}
+
+// validate checks the fields that do not depend on the account.
+func (o *Order) validate(costs backtest.Costs) error {
+	switch {
+	case o.Symbol == "":
+		return fmt.Errorf("%w: symbol is required", ErrInvalidOrder)
+	case o.Quantity <= 0:
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidOrder)
+	case costs.LotSize > 0 && costs.Lots(o.Quantity) != o.Quantity:
+		return fmt.Errorf("%w: quantity must be a multiple of the lot size %g", ErrInvalidOrder, costs.LotSize)
+	case (o.Type == Limit || o.Type == StopLimit) && o.LimitPrice <= 0:
+		return fmt.Errorf("%w: %s orders need a positive limit price", ErrInvalidOrder, o.Type)
+	case (o.Type == Stop || o.Type == StopLimit) && o.StopPrice <= 0:
+		return fmt.Errorf("%w: %s orders need a positive stop price", ErrInvalidOrder, o.Type)
+	case o.Type == Market && (o.LimitPrice != 0 || o.StopPrice != 0):
+		return fmt.Errorf("%w: market orders take no limit or stop price", ErrInvalidOrder)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Package paper is a paper trading engine. Accounts hold simulated cash,
+// orders are matched against the latest quotes of a QuoteFeed and every fill
+// is booked in the account the way a broker would, without real money.
+package paper
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"id/projects/market-data/backtest"
+)
+
+var (
+	ErrAccountNotFound = errors.New("account not found")
+	ErrOrderNotFound   = errors.New("order not found")
+	ErrOrderClosed     = errors.New("order is no longer open")
+	ErrInvalidOrder    = errors.New("invalid order")
+	ErrRejected        = errors.New("order rejected")
+	ErrInvalidCash     = errors.New("initial cash must be positive")
+)
+
+type OrderType string
+
+const (
+	Market    OrderType = "market"
+	Limit     OrderType = "limit"
+	Stop      OrderType = "stop"
+	StopLimit OrderType = "stopLimit"
+)
+
+type TimeInForce string
+
+const (
+	// Day orders are cancelled once the market has moved on to a new day.
+	Day TimeInForce = "day"
+	// GTC orders stay open until they fill or are cancelled.
+	GTC TimeInForce = "gtc"
+	// IOC orders fill what they can on the first match and cancel the rest.
+	IOC TimeInForce = "ioc"
+	// FOK orders fill in full on the first match or are cancelled.
+	FOK TimeInForce = "fok"
+)
+
+type Status string
+
+const (
+	New             Status = "new"
+	PartiallyFilled Status = "partiallyFilled"
+	Filled          Status = "filled"
+	Cancelled       Status = "cancelled"
+	Rejected        Status = "rejected"
+)
+
+func ParseSide(value string) (backtest.Side, error) {
+	switch strings.ToUpper(value) {
+	case string(backtest.Buy):
+		return backtest.Buy, nil
+	case string(backtest.Sell):
+		return backtest.Sell, nil
+	}
+	return "", fmt.Errorf("%w: unknown side %q, expected buy or sell", ErrInvalidOrder, value)
+}
+
+func ParseOrderType(value string) (OrderType, error) {
+	for _, orderType := range []OrderType{Market, Limit, Stop, StopLimit} {
+		if strings.EqualFold(value, string(orderType)) {
+			return orderType, nil
+		}
+	}
+	if value == "" {
+		return Market, nil
+	}
+	return "", fmt.Errorf("%w: unknown type %q, expected market, limit, stop or stopLimit", ErrInvalidOrder, value)
+}
+
+func ParseTimeInForce(value string) (TimeInForce, error) {
+	for _, tif := range []TimeInForce{Day, GTC, IOC, FOK} {
+		if strings.EqualFold(value, string(tif)) {
+			return tif, nil
+		}
+	}
+	if value == "" {
+		return Day, nil
+	}
+	return "", fmt.Errorf("%w: unknown time in force %q, expected day, gtc, ioc or fok", ErrInvalidOrder, value)
+}
+
+func newID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("new id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
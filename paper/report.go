@@ -0,0 +1,73 @@
+package paper
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// reportBuffer is how many execution reports a subscriber may fall behind
+// before further reports to it are dropped
+const reportBuffer = 256
+
+type ReportType string
+
+const (
+	ReportNew       ReportType = "new"
+	ReportTrade     ReportType = "trade"
+	ReportReplaced  ReportType = "replaced"
+	ReportCancelled ReportType = "cancelled"
+	ReportRejected  ReportType = "rejected"
+)
+
+// ExecutionReport tells a subscriber that an order changed. Order is the
+// order after the change and Execution the fill of a trade report.
+type ExecutionReport struct {
+	Type      ReportType `json:"type"`
+	Order     Order      `json:"order"`
+	Execution *Execution `json:"execution,omitempty"`
+	Time      time.Time  `json:"time"`
+}
+
+// Executions streams the execution reports of every account until ctx is
+// cancelled, when the channel is closed.
+func (e *Engine) Executions(ctx context.Context) (<-chan ExecutionReport, error) {
+	reports := make(chan ExecutionReport, reportBuffer)
+
+	e.mu.Lock()
+	e.subscribers[reports] = struct{}{}
+	e.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		e.mu.Lock()
+		delete(e.subscribers, reports)
+		close(reports)
+		e.mu.Unlock()
+	}()
+
+	return reports, nil
+}
+
+// report sends a report to every subscriber without waiting on any. It must
+// be called with the lock held.
+func (e *Engine) report(reportType ReportType, order *Order, execution *Execution) {
+	if len(e.subscribers) == 0 {
+		return
+	}
+
+	report := ExecutionReport{Type: reportType, Order: order.copy(), Time: time.Now().UTC()}
+	if execution != nil {
+		copied := *execution
+		report.Execution = &copied
+	}
+
+	for reports := range e.subscribers {
+		select {
+		case reports <- report:
+		default:
+			log.Printf("paper trading: execution report for order %s dropped, subscriber is behind", order.ID)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Lot is shares bought together that are still held. Cost includes the
+// buy fee, less what earlier sells took out.
+type Lot struct {
+	Date     time.Time `json:"date"`
+	Quantity float64   `json:"quantity"`
+	Cost     float64   `json:"cost"`
+}
+
+// Holding is what a portfolio owns of one symbol. RealizedPnL is net of
+// trade fees; Dividends and Fees (outside of trades) are kept apart.
+// Holdings that were sold off stay listed with a zero quantity.
+type Holding struct {
+	Symbol      string  `json:"symbol"`
+	Quantity    float64 `json:"quantity"`
+	CostBasis   float64 `json:"costBasis"`
+	AverageCost float64 `json:"averageCost"`
+	RealizedPnL float64 `json:"realizedPnl"`
+	Dividends   float64 `json:"dividends"`
+	Fees        float64 `json:"fees"`
+	Lots        []Lot   `json:"lots,omitempty"`
+}
+
+// Ledger is the result of replaying a portfolio. Fees are those charged to
+// no symbol, by currency ("" is the portfolio's currency).
+type Ledger struct {
+	Method   CostMethod         `json:"method"`
+	Holdings []Holding          `json:"holdings"`
+	Fees     map[string]float64 `json:"fees"`
+}
+
+// Replay works out the holdings left by transactions, in date order, with
+// method deciding the cost of what is sold. A sell of more than is held
+// fails.
+func Replay(transactions []Transaction, method CostMethod) (Ledger, error) {
+	ordered := append([]Transaction(nil), transactions...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Date.Before(ordered[j].Date)
+	})
+
+	holdings := make(map[string]*Holding)
+	ledger := Ledger{Method: method, Fees: make(map[string]float64)}
+
+	for _, t := range ordered {
+		if t.Type == Fee && t.Symbol == "" {
+			ledger.Fees[t.Currency] += t.Amount
+			continue
+		}
+
+		holding, ok := holdings[t.Symbol]
+		if !ok {
+			holding = &Holding{Symbol: t.Symbol}
+			holdings[t.Symbol] = holding
+		}
+
+		switch t.Type {
+		case Buy:
+			cost := t.Quantity*t.Price + t.Fee
+			holding.Lots = append(holding.Lots, Lot{Date: t.Date, Quantity: t.Quantity, Cost: cost})
+			holding.Quantity += t.Quantity
+			holding.CostBasis += cost
+		case Sell:
+			if t.Quantity > holding.Quantity+epsilon {
+				return Ledger{}, fmt.Errorf("%w: sell of %g %s on %s exceeds the %g held", ErrInvalidTransaction, t.Quantity, t.Symbol, t.Date.Format("2006-01-02"), holding.Quantity)
+			}
+
+			cost := holding.sell(t.Quantity, method)
+			holding.RealizedPnL += t.Quantity*t.Price - t.Fee - cost
+		case Dividend:
+			holding.Dividends += t.Amount
+		case Fee:
+			holding.Fees += t.Amount
+		}
+	}
+
+	for _, holding := range holdings {
+		// lots only say something when sells are matched against them
+		if method == Average {
+			holding.Lots = nil
+		}
+		if holding.Quantity > epsilon {
+			holding.AverageCost = holding.CostBasis / holding.Quantity
+		} else {
+			holding.Quantity, holding.CostBasis, holding.Lots = 0, 0, nil
+		}
+		ledger.Holdings = append(ledger.Holdings, *holding)
+	}
+	sort.Slice(ledger.Holdings, func(i, j int) bool {
+		return ledger.Holdings[i].Symbol < ledger.Holdings[j].Symbol
+	})
+
+	return ledger, nil
+}
+
+// sell takes quantity out of the holding and returns its cost. FIFO empties
+// the oldest lots first; Average takes the same share of every lot, which
+// leaves each lot at the average cost.
+func (h *Holding) sell(quantity float64, method CostMethod) float64 {
+	cost := 0.0
+
+	if method == Average {
+		share := quantity / h.Quantity
+		for i := range h.Lots {
+			taken := h.Lots[i].Cost * share
+			h.Lots[i].Quantity -= h.Lots[i].Quantity * share
+			h.Lots[i].Cost -= taken
+			cost += taken
+		}
+	} else {
+		left := quantity
+		for len(h.Lots) > 0 && left > epsilon {
+			lot := &h.Lots[0]
+			taken := lot.Quantity
+			if left < taken {
+				taken = left
+			}
+
+			part := lot.Cost * taken / lot.Quantity
+			lot.Quantity -= taken
+			lot.Cost -= part
+			cost += part
+			left -= taken
+
+			if lot.Quantity < epsilon {
+				h.Lots = h.Lots[1:]
+			}
+		}
+	}
+
+	h.Quantity -= quantity
+	h.CostBasis -= cost
+	return cost
+}
@@ -0,0 +1,101 @@
+package portfolio
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func day(n int) time.Time {
+	return time.Date(2024, time.January, n, 0, 0, 0, 0, time.UTC)
+}
+
+func TestReplay(t *testing.T) {
+	// two lots of 100 costing 1010 and 2010 with their fees
+	lots := []Transaction{
+		{Type: Buy, Symbol: "BBCA", Date: day(2), Quantity: 100, Price: 10, Fee: 10},
+		{Type: Buy, Symbol: "BBCA", Date: day(3), Quantity: 100, Price: 20, Fee: 10},
+	}
+	with := func(transactions ...Transaction) []Transaction {
+		return append(append([]Transaction(nil), lots...), transactions...)
+	}
+	sellPart := Transaction{Type: Sell, Symbol: "BBCA", Date: day(4), Quantity: 150, Price: 30, Fee: 15}
+	sellAll := Transaction{Type: Sell, Symbol: "BBCA", Date: day(4), Quantity: 200, Price: 30, Fee: 20}
+
+	tests := []struct {
+		name         string
+		method       CostMethod
+		transactions []Transaction
+		want         Holding
+		lots         int
+		err          error
+	}{
+		{"fifo part", FIFO, with(sellPart), Holding{Quantity: 50, CostBasis: 1005, AverageCost: 20.1, RealizedPnL: 4500 - 15 - 1010 - 1005}, 1, nil},
+		{"average part", Average, with(sellPart), Holding{Quantity: 50, CostBasis: 755, AverageCost: 15.1, RealizedPnL: 4500 - 15 - 3020*0.75}, 0, nil},
+		{"fifo all", FIFO, with(sellAll), Holding{RealizedPnL: 6000 - 20 - 3020}, 0, nil},
+		{"average all", Average, with(sellAll), Holding{RealizedPnL: 6000 - 20 - 3020}, 0, nil},
+		{"out of order", FIFO, []Transaction{sellPart, lots[1], lots[0]}, Holding{Quantity: 50, CostBasis: 1005, AverageCost: 20.1, RealizedPnL: 4500 - 15 - 1010 - 1005}, 1, nil},
+		{"dividends and fees", FIFO, with(
+			Transaction{Type: Dividend, Symbol: "BBCA", Date: day(5), Amount: 300},
+			Transaction{Type: Fee, Symbol: "BBCA", Date: day(5), Amount: 25},
+		), Holding{Quantity: 200, CostBasis: 3020, AverageCost: 15.1, Dividends: 300, Fees: 25}, 2, nil},
+		{"oversold", Average, with(Transaction{Type: Sell, Symbol: "BBCA", Date: day(4), Quantity: 201, Price: 30}), Holding{}, 0, ErrInvalidTransaction},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ledger, err := Replay(test.transactions, test.method)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("Replay error = %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Replay: %v", err)
+			}
+			if len(ledger.Holdings) != 1 {
+				t.Fatalf("holdings = %+v, want BBCA only", ledger.Holdings)
+			}
+
+			got := ledger.Holdings[0]
+			fields := []struct {
+				name      string
+				got, want float64
+			}{
+				{"quantity", got.Quantity, test.want.Quantity},
+				{"cost basis", got.CostBasis, test.want.CostBasis},
+				{"average cost", got.AverageCost, test.want.AverageCost},
+				{"realized", got.RealizedPnL, test.want.RealizedPnL},
+				{"dividends", got.Dividends, test.want.Dividends},
+				{"fees", got.Fees, test.want.Fees},
+			}
+			for _, field := range fields {
+				if math.Abs(field.got-field.want) > 1e-6 {
+					t.Errorf("%s = %g, want %g", field.name, field.got, field.want)
+				}
+			}
+			if len(got.Lots) != test.lots {
+				t.Errorf("lots = %+v, want %d", got.Lots, test.lots)
+			}
+		})
+	}
+}
+
+func TestReplayPortfolioFees(t *testing.T) {
+	ledger, err := Replay([]Transaction{
+		{Type: Fee, Date: day(2), Amount: 10},
+		{Type: Fee, Date: day(3), Amount: 5, Currency: "USD"},
+		{Type: Fee, Date: day(4), Amount: 15},
+	}, FIFO)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if len(ledger.Holdings) != 0 {
+		t.Errorf("holdings = %+v, want none", ledger.Holdings)
+	}
+	if ledger.Fees[""] != 25 || ledger.Fees["USD"] != 5 {
+		t.Errorf("fees = %v, want 25 and 5 USD", ledger.Fees)
+	}
+}
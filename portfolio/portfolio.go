@@ -0,0 +1,125 @@
+// Package portfolio tracks what is actually owned. A portfolio is a list of
+// transactions; holdings, cost basis and realised P&L are worked out by
+// replaying them, and Value marks the holdings to market.
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPortfolioNotFound   = errors.New("portfolio not found")
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrInvalidTransaction  = errors.New("invalid transaction")
+)
+
+const epsilon = 1e-9
+
+type TransactionType string
+
+const (
+	Buy  TransactionType = "buy"
+	Sell TransactionType = "sell"
+	// Dividend is cash paid on a holding; Amount is the total received.
+	Dividend TransactionType = "dividend"
+	// Fee is a cost outside of a trade, such as custody or platform fees.
+	// Without a symbol it is charged to the portfolio as a whole.
+	Fee TransactionType = "fee"
+)
+
+// CostMethod decides which shares a sell takes out of a holding, and so its
+// cost basis and realised P&L.
+type CostMethod string
+
+const (
+	// FIFO sells the oldest lots first.
+	FIFO CostMethod = "fifo"
+	// Average sells at the average cost of the whole holding.
+	Average CostMethod = "average"
+)
+
+func ParseTransactionType(value string) (TransactionType, error) {
+	for _, transactionType := range []TransactionType{Buy, Sell, Dividend, Fee} {
+		if strings.EqualFold(value, string(transactionType)) {
+			return transactionType, nil
+		}
+	}
+	return "", fmt.Errorf("%w: unknown type %q, expected buy, sell, dividend or fee", ErrInvalidTransaction, value)
+}
+
+func ParseCostMethod(value string) (CostMethod, error) {
+	switch strings.ToLower(value) {
+	case "", string(FIFO):
+		return FIFO, nil
+	case string(Average), "avg":
+		return Average, nil
+	}
+	return "", fmt.Errorf("unknown cost method %q, expected fifo or average", value)
+}
+
+// Transaction is one entry in a portfolio. Buys and sells take Quantity,
+// Price and the trade's Fee; dividends and fees take Amount. Currency only
+// matters for transactions without a symbol, which are otherwise in the
+// portfolio's currency; everything else is in the currency the symbol
+// trades in.
+type Transaction struct {
+	ID       string          `json:"id"`
+	Type     TransactionType `json:"type"`
+	Symbol   string          `json:"symbol,omitempty"`
+	Date     time.Time       `json:"date"`
+	Quantity float64         `json:"quantity,omitempty"`
+	Price    float64         `json:"price,omitempty"`
+	Fee      float64         `json:"fee,omitempty"`
+	Amount   float64         `json:"amount,omitempty"`
+	Currency string          `json:"currency,omitempty"`
+	Note     string          `json:"note,omitempty"`
+}
+
+// Validate checks the fields of t on their own; whether a sell is covered
+// is only known when the whole portfolio is replayed.
+func (t Transaction) Validate() error {
+	switch {
+	case t.Date.IsZero():
+		return fmt.Errorf("%w: date is required", ErrInvalidTransaction)
+	case t.Fee < 0:
+		return fmt.Errorf("%w: fee must not be negative", ErrInvalidTransaction)
+	}
+
+	switch t.Type {
+	case Buy, Sell:
+		if t.Symbol == "" {
+			return fmt.Errorf("%w: %s needs a symbol", ErrInvalidTransaction, t.Type)
+		}
+		if t.Quantity <= 0 || t.Price <= 0 {
+			return fmt.Errorf("%w: %s needs a positive quantity and price", ErrInvalidTransaction, t.Type)
+		}
+	case Dividend:
+		if t.Symbol == "" {
+			return fmt.Errorf("%w: dividend needs a symbol", ErrInvalidTransaction)
+		}
+		if t.Amount <= 0 {
+			return fmt.Errorf("%w: dividend needs a positive amount", ErrInvalidTransaction)
+		}
+	case Fee:
+		if t.Amount <= 0 {
+			return fmt.Errorf("%w: fee needs a positive amount", ErrInvalidTransaction)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidTransaction, t.Type)
+	}
+
+	return nil
+}
+
+// Portfolio is a named list of transactions. Currency is what totals are
+// reported in.
+type Portfolio struct {
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	Currency     string        `json:"currency"`
+	Transactions []Transaction `json:"transactions"`
+	CreatedAt    time.Time     `json:"createdAt"`
+}
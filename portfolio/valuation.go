@@ -0,0 +1,188 @@
+package portfolio
+
+import (
+	"sort"
+	"strings"
+)
+
+// Quote is the market data a holding is valued with.
+type Quote struct {
+	Symbol        string  `json:"symbol"`
+	Name          string  `json:"name"`
+	Currency      string  `json:"currency"`
+	Price         float64 `json:"price"`
+	PreviousClose float64 `json:"previousClose"`
+	Sector        string  `json:"sector"`
+}
+
+// HoldingValue is a holding marked to market, in the currency the symbol
+// trades in. Weight is its share of the portfolio's market value.
+type HoldingValue struct {
+	Holding
+	Name               string  `json:"name"`
+	Currency           string  `json:"currency"`
+	Sector             string  `json:"sector"`
+	Price              float64 `json:"price"`
+	MarketValue        float64 `json:"marketValue"`
+	UnrealizedPnL      float64 `json:"unrealizedPnl"`
+	UnrealizedReturn   float64 `json:"unrealizedReturn"`
+	DailyChange        float64 `json:"dailyChange"`
+	DailyChangePercent float64 `json:"dailyChangePercent"`
+	Weight             float64 `json:"weight"`
+	Error              string  `json:"error,omitempty"`
+}
+
+type AllocationEntry struct {
+	Name        string  `json:"name"`
+	MarketValue float64 `json:"marketValue"`
+	Weight      float64 `json:"weight"`
+}
+
+type Allocation struct {
+	Sectors    []AllocationEntry `json:"sectors"`
+	Currencies []AllocationEntry `json:"currencies"`
+}
+
+// Valuation is a portfolio marked to market. Totals are in Currency,
+// converted at Rates, the price of one unit of each currency held; holdings
+// stay in their own currency.
+type Valuation struct {
+	PortfolioID        string             `json:"portfolioId"`
+	Currency           string             `json:"currency"`
+	Method             CostMethod         `json:"method"`
+	MarketValue        float64            `json:"marketValue"`
+	CostBasis          float64            `json:"costBasis"`
+	UnrealizedPnL      float64            `json:"unrealizedPnl"`
+	RealizedPnL        float64            `json:"realizedPnl"`
+	Dividends          float64            `json:"dividends"`
+	Fees               float64            `json:"fees"`
+	TotalPnL           float64            `json:"totalPnl"`
+	DailyChange        float64            `json:"dailyChange"`
+	DailyChangePercent float64            `json:"dailyChangePercent"`
+	Rates              map[string]float64 `json:"rates"`
+	Holdings           []HoldingValue     `json:"holdings"`
+	Allocation         Allocation         `json:"allocation"`
+}
+
+// Value marks ledger to quotes, by symbol. A holding without a quote is
+// valued at cost, and one whose currency has no rate in rates is left out
+// of the totals; both carry an Error saying so. Quotes without a currency
+// or sector count as the portfolio's currency and "Unknown".
+func Value(p Portfolio, ledger Ledger, quotes map[string]Quote, rates map[string]float64) Valuation {
+	valuation := Valuation{
+		PortfolioID: p.ID,
+		Currency:    p.Currency,
+		Method:      ledger.Method,
+		Rates:       map[string]float64{p.Currency: 1},
+		Holdings:    []HoldingValue{},
+		Allocation:  Allocation{Sectors: []AllocationEntry{}, Currencies: []AllocationEntry{}},
+	}
+
+	rate := func(currency string) (float64, bool) {
+		if currency == "" || strings.EqualFold(currency, p.Currency) {
+			return 1, true
+		}
+		r, ok := rates[currency]
+		if ok && r > 0 {
+			valuation.Rates[currency] = r
+		}
+		return r, ok && r > 0
+	}
+
+	sectors := make(map[string]float64)
+	currencies := make(map[string]float64)
+	previous := 0.0
+
+	for _, holding := range ledger.Holdings {
+		value := HoldingValue{Holding: holding, Currency: p.Currency, Sector: "Unknown", Price: holding.AverageCost}
+
+		if quote, ok := quotes[holding.Symbol]; ok {
+			value.Name = quote.Name
+			if quote.Currency != "" {
+				value.Currency = quote.Currency
+			}
+			if quote.Sector != "" {
+				value.Sector = quote.Sector
+			}
+			if quote.Price > 0 {
+				value.Price = quote.Price
+			}
+			if quote.Price > 0 && quote.PreviousClose > 0 && holding.Quantity > 0 {
+				value.DailyChange = holding.Quantity * (value.Price - quote.PreviousClose)
+				value.DailyChangePercent = (value.Price - quote.PreviousClose) / quote.PreviousClose * 100
+			}
+		} else if holding.Quantity > 0 {
+			value.Error = "no quote, valued at cost"
+		}
+
+		value.MarketValue = holding.Quantity * value.Price
+		value.UnrealizedPnL = value.MarketValue - holding.CostBasis
+		if holding.CostBasis > 0 {
+			value.UnrealizedReturn = value.UnrealizedPnL / holding.CostBasis
+		}
+
+		r, ok := rate(value.Currency)
+		if !ok {
+			value.Error = "no exchange rate from " + value.Currency + " to " + p.Currency + ", left out of the totals"
+			valuation.Holdings = append(valuation.Holdings, value)
+			continue
+		}
+
+		valuation.MarketValue += value.MarketValue * r
+		valuation.CostBasis += holding.CostBasis * r
+		valuation.UnrealizedPnL += value.UnrealizedPnL * r
+		valuation.RealizedPnL += holding.RealizedPnL * r
+		valuation.Dividends += holding.Dividends * r
+		valuation.Fees += holding.Fees * r
+		valuation.DailyChange += value.DailyChange * r
+		previous += (value.MarketValue - value.DailyChange) * r
+
+		if value.MarketValue > 0 {
+			sectors[value.Sector] += value.MarketValue * r
+			currencies[value.Currency] += value.MarketValue * r
+		}
+
+		valuation.Holdings = append(valuation.Holdings, value)
+	}
+
+	for currency, fees := range ledger.Fees {
+		if r, ok := rate(currency); ok {
+			valuation.Fees += fees * r
+		}
+	}
+
+	valuation.TotalPnL = valuation.RealizedPnL + valuation.UnrealizedPnL + valuation.Dividends - valuation.Fees
+	if previous > 0 {
+		valuation.DailyChangePercent = valuation.DailyChange / previous * 100
+	}
+
+	for i := range valuation.Holdings {
+		holding := &valuation.Holdings[i]
+		if r, ok := rate(holding.Currency); ok && valuation.MarketValue > 0 {
+			holding.Weight = holding.MarketValue * r / valuation.MarketValue
+		}
+	}
+	valuation.Allocation.Sectors = allocate(sectors, valuation.MarketValue)
+	valuation.Allocation.Currencies = allocate(currencies, valuation.MarketValue)
+
+	return valuation
+}
+
+// allocate turns market values by name into weights, largest first.
+func allocate(values map[string]float64, total float64) []AllocationEntry {
+	entries := []AllocationEntry{}
+	for name, value := range values {
+		entry := AllocationEntry{Name: name, MarketValue: value}
+		if total > 0 {
+			entry.Weight = value / total
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].MarketValue != entries[j].MarketValue {
+			return entries[i].MarketValue > entries[j].MarketValue
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
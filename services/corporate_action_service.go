@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"id/projects/market-data/backtest"
+	"id/projects/market-data/helper"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const yahooEventsURL = "https://query1.finance.yahoo.com/v7/finance/download/%s?period1=0&period2=%d&interval=1d&events=%s"
+
+type corporateActionRecord struct {
+	Fetched   backtest.CorporateActions `json:"fetched"`
+	Manual    backtest.CorporateActions `json:"manual"`
+	FetchedAt time.Time                 `json:"fetchedAt"`
+}
+
+type corporateActionService struct {
+	path    string
+	ttl     time.Duration
+	Client  *http.Client
+	records map[string]*corporateActionRecord
+	mu      sync.Mutex
+}
+
+type CorporateActionService interface {
+	Actions(ctx context.Context, symbol string) (backtest.CorporateActions, error)
+	Save(actions backtest.CorporateActions) (backtest.CorporateActions, error)
+}
+
+// NewCorporateActionService keeps the splits and dividends of every symbol
+// asked for as JSON at path. Yahoo's history is fetched the first time a
+// symbol is needed and again once it is older than ttl; actions saved by hand
+// are kept apart and win over fetched ones on the same date.
+func NewCorporateActionService(path string, ttl time.Duration) (*corporateActionService, error) {
+	s := &corporateActionService{
+		path:    path,
+		ttl:     ttl,
+		Client:  &http.Client{Timeout: 30 * time.Second},
+		records: make(map[string]*corporateActionRecord),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open corporate actions: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.records); err != nil {
+		return nil, fmt.Errorf("read corporate actions: %w", err)
+	}
+
+	return s, nil
+}
+
+// Actions returns the splits and dividends of symbol, sorted by date. When
+// refreshing from Yahoo fails, what was stored before is returned; only a
+// symbol that was never stored fails. The lock is not held while fetching,
+// so a slow download does not hold up other symbols.
+func (s *corporateActionService) Actions(ctx context.Context, symbol string) (backtest.CorporateActions, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	s.mu.Lock()
+	record, ok := s.records[symbol]
+	if ok && time.Since(record.FetchedAt) <= s.ttl {
+		actions := record.Fetched.Merge(record.Manual)
+		s.mu.Unlock()
+
+		actions.Symbol = symbol
+		return actions, nil
+	}
+	s.mu.Unlock()
+
+	fetched, err := s.fetch(ctx, symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// another request may have stored the symbol while this one was fetching
+	record, ok = s.records[symbol]
+	switch {
+	case err != nil && !ok:
+		return backtest.CorporateActions{}, err
+	case err != nil:
+		log.Printf("corporate actions: %v", err)
+	default:
+		if !ok {
+			record = &corporateActionRecord{}
+			s.records[symbol] = record
+		}
+		record.Fetched = fetched
+		record.FetchedAt = time.Now().UTC()
+		if err := s.write(); err != nil {
+			return backtest.CorporateActions{}, err
+		}
+	}
+
+	actions := record.Fetched.Merge(record.Manual)
+	actions.Symbol = symbol
+	return actions, nil
+}
+
+// Save adds actions entered by hand to the symbol's stored ones and returns
+// the merged result.
+func (s *corporateActionService) Save(actions backtest.CorporateActions) (backtest.CorporateActions, error) {
+	if err := actions.Validate(); err != nil {
+		return backtest.CorporateActions{}, err
+	}
+
+	symbol := strings.ToUpper(strings.TrimSpace(actions.Symbol))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[symbol]
+	if !ok {
+		record = &corporateActionRecord{}
+		s.records[symbol] = record
+	}
+
+	record.Manual = record.Manual.Merge(actions)
+	record.Manual.Symbol = symbol
+	if err := s.write(); err != nil {
+		return backtest.CorporateActions{}, err
+	}
+
+	merged := record.Fetched.Merge(record.Manual)
+	merged.Symbol = symbol
+	return merged, nil
+}
+
+// fetch downloads the whole dividend and split history of symbol. Yahoo
+// restates dividends per share after the later splits, so they are scaled
+// back to what was paid at the time.
+func (s *corporateActionService) fetch(ctx context.Context, symbol string) (backtest.CorporateActions, error) {
+	actions := backtest.CorporateActions{Symbol: symbol}
+
+	splitRows, err := s.fetchEvents(ctx, symbol, "split")
+	if err != nil {
+		return actions, err
+	}
+	for _, row := range splitRows {
+		ratio, err := parseSplitRatio(row.value)
+		if err != nil {
+			return actions, fmt.Errorf("fetch splits of %s: %w", symbol, err)
+		}
+		actions.Splits = append(actions.Splits, backtest.Split{Date: row.date, Ratio: ratio})
+	}
+
+	dividendRows, err := s.fetchEvents(ctx, symbol, "div")
+	if err != nil {
+		return actions, err
+	}
+	for _, row := range dividendRows {
+		amount, err := strconv.ParseFloat(row.value, 64)
+		if err != nil {
+			return actions, fmt.Errorf("fetch dividends of %s: %w", symbol, err)
+		}
+
+		for _, split := range actions.Splits {
+			if split.Date.After(row.date) {
+				amount *= split.Ratio
+			}
+		}
+		actions.Dividends = append(actions.Dividends, backtest.Dividend{Date: row.date, Amount: amount})
+	}
+
+	return actions.Merge(backtest.CorporateActions{}), nil
+}
+
+type eventRow struct {
+	date  time.Time
+	value string
+}
+
+func (s *corporateActionService) fetchEvents(ctx context.Context, symbol string, event string) ([]eventRow, error) {
+	url := fmt.Sprintf(yahooEventsURL, symbol, time.Now().Unix(), event)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s events of %s: %w", event, symbol, err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s events of %s: %w", event, symbol, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s events of %s: unexpected status %s", event, symbol, resp.Status)
+	}
+
+	reader := csv.NewReader(resp.Body)
+	var rows []eventRow
+	for header := true; ; header = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse %s events of %s: %w", event, symbol, err)
+		}
+		if header || len(record) < 2 {
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", record[0])
+		if err != nil {
+			return nil, fmt.Errorf("parse %s events of %s: %w", event, symbol, err)
+		}
+		rows = append(rows, eventRow{date: date, value: strings.TrimSpace(record[1])})
+	}
+
+	return rows, nil
+}
+
+// parseSplitRatio reads Yahoo's "2:1" form, new shares first, or a plain
+// number.
+func parseSplitRatio(value string) (float64, error) {
+	after, before, ok := strings.Cut(value, ":")
+	if !ok {
+		after, before, ok = strings.Cut(value, "/")
+	}
+	if !ok {
+		return strconv.ParseFloat(value, 64)
+	}
+
+	numerator, err := strconv.ParseFloat(strings.TrimSpace(after), 64)
+	if err != nil {
+		return 0, err
+	}
+	denominator, err := strconv.ParseFloat(strings.TrimSpace(before), 64)
+	if err != nil {
+		return 0, err
+	}
+	if numerator <= 0 || denominator <= 0 {
+		return 0, fmt.Errorf("invalid split ratio %q", value)
+	}
+	return numerator / denominator, nil
+}
+
+func (s *corporateActionService) write() error {
+	if s.path == "" {
+		return nil
+	}
+
+	if err := helper.WriteFileAtomic(s.path, s.records); err != nil {
+		return fmt.Errorf("save corporate actions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,413 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"id/projects/market-data/helper"
+	"id/projects/market-data/models"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrQueueFull   = errors.New("job queue is full, try again later")
+	ErrJobDone     = errors.New("job has already finished")
+)
+
+type progressKey struct{}
+
+// WithProgress returns a context through which a handler run as a job
+// reports how far it got.
+func WithProgress(ctx context.Context, progress func(done int, total int)) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// Progress returns the progress callback of ctx, or nil outside a job.
+func Progress(ctx context.Context) func(done int, total int) {
+	progress, _ := ctx.Value(progressKey{}).(func(done int, total int))
+	return progress
+}
+
+type jobService struct {
+	path      string
+	workers   int
+	retention time.Duration
+	maxJobs   int
+	handler   http.Handler
+	queue     chan string
+	jobs      map[string]*models.Job
+	cancels   map[string]context.CancelFunc
+	mu        sync.Mutex
+	// version numbers the snapshots; written is the last one on disk, so a
+	// snapshot that lost the race to writeMu is not written over a newer one
+	version int
+	written int
+	writeMu sync.Mutex
+}
+
+// jobSnapshot is a copy of the jobs taken under the lock, written without it.
+type jobSnapshot struct {
+	version int
+	jobs    map[string]models.Job
+}
+
+type JobService interface {
+	Submit(kind string, path string, request json.RawMessage) (models.Job, error)
+	Get(id string) (models.Job, error)
+	List(status string) []models.Job
+	Cancel(id string) (models.Job, error)
+}
+
+// NewJobService keeps jobs as JSON at path, loading whatever a previous run
+// stored there. Jobs that were queued or running when it stopped are queued
+// again. At most queueSize jobs wait for one of the workers. Finished jobs
+// are dropped once they are older than retention, and the oldest of them
+// when more than maxJobs are kept; zero disables either limit.
+func NewJobService(path string, workers int, queueSize int, retention time.Duration, maxJobs int) (*jobService, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	s := &jobService{
+		path:      path,
+		workers:   workers,
+		retention: retention,
+		maxJobs:   maxJobs,
+		jobs:      make(map[string]*models.Job),
+		cancels:   make(map[string]context.CancelFunc),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("open job store: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.jobs); err != nil {
+			return nil, fmt.Errorf("read job store: %w", err)
+		}
+	}
+
+	var pending []*models.Job
+	for _, job := range s.jobs {
+		if !job.Done() {
+			job.Status = models.JobQueued
+			job.Progress = 0
+			job.StartedAt = nil
+			pending = append(pending, job)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+
+	if len(pending) > queueSize {
+		queueSize = len(pending)
+	}
+	s.queue = make(chan string, queueSize)
+	for _, job := range pending {
+		s.queue <- job.ID
+	}
+
+	s.evict(time.Now().UTC())
+
+	return s, nil
+}
+
+// Start runs the workers until ctx is cancelled. Every job is served by
+// handler as a GET request on its path, so it behaves exactly like the
+// synchronous endpoint.
+func (s *jobService) Start(ctx context.Context, handler http.Handler) {
+	s.handler = handler
+	for i := 0; i < s.workers; i++ {
+		go s.work(ctx)
+	}
+}
+
+func (s *jobService) Submit(kind string, path string, request json.RawMessage) (models.Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	job := &models.Job{
+		ID:        id,
+		Type:      kind,
+		Path:      path,
+		Status:    models.JobQueued,
+		Request:   request,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	select {
+	case s.queue <- id:
+	default:
+		s.mu.Unlock()
+		return models.Job{}, ErrQueueFull
+	}
+
+	s.jobs[id] = job
+	submitted := *job
+	snapshot := s.snapshot()
+	s.mu.Unlock()
+
+	s.save(snapshot)
+	return submitted, nil
+}
+
+func (s *jobService) Get(id string) (models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return models.Job{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+// List returns the jobs with status, or all of them when it is empty, newest
+// first and without their results.
+func (s *jobService) List(status string) []models.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []models.Job{}
+	for _, job := range s.jobs {
+		if status == "" || job.Status == status {
+			summary := *job
+			summary.Request = nil
+			summary.Result = nil
+			jobs = append(jobs, summary)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Cancel stops a running job or drops a queued one.
+func (s *jobService) Cancel(id string) (models.Job, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	if !ok {
+		s.mu.Unlock()
+		return models.Job{}, ErrJobNotFound
+	}
+	if job.Done() {
+		s.mu.Unlock()
+		return *job, ErrJobDone
+	}
+
+	if cancel, ok := s.cancels[id]; ok {
+		// the worker records the cancellation once the handler returns
+		cancel()
+		s.mu.Unlock()
+		return *job, nil
+	}
+
+	s.finish(job, models.JobCancelled, "cancelled before it started", nil)
+	cancelled := *job
+	snapshot := s.snapshot()
+	s.mu.Unlock()
+
+	s.save(snapshot)
+	return cancelled, nil
+}
+
+func (s *jobService) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-s.queue:
+			s.run(ctx, id)
+		}
+	}
+}
+
+func (s *jobService) run(ctx context.Context, id string) {
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	if !ok || job.Status != models.JobQueued {
+		s.mu.Unlock()
+		return
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.cancels[id] = cancel
+
+	now := time.Now().UTC()
+	job.Status = models.JobRunning
+	job.StartedAt = &now
+	path, request := job.Path, job.Request
+	snapshot := s.snapshot()
+	s.mu.Unlock()
+
+	s.save(snapshot)
+
+	jobCtx = WithProgress(jobCtx, func(done int, total int) {
+		if total <= 0 {
+			return
+		}
+		s.mu.Lock()
+		job.Progress = float64(done) / float64(total) * 100
+		s.mu.Unlock()
+	})
+
+	status, message, result := s.serve(jobCtx, path, request)
+
+	s.mu.Lock()
+	delete(s.cancels, id)
+	switch {
+	case ctx.Err() != nil:
+		// shutting down: leave it for the next start to run again
+		job.Status = models.JobQueued
+		job.Progress = 0
+		job.StartedAt = nil
+	case jobCtx.Err() != nil:
+		s.finish(job, models.JobCancelled, "cancelled", nil)
+	default:
+		s.finish(job, status, message, result)
+	}
+	snapshot = s.snapshot()
+	s.mu.Unlock()
+
+	s.save(snapshot)
+}
+
+// serve runs the request through the handler and unwraps the API envelope.
+// A status outside 2xx, such as the empty 500 of a recovered panic, fails
+// the job.
+func (s *jobService) serve(ctx context.Context, path string, request json.RawMessage) (string, string, json.RawMessage) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, bytes.NewReader(request))
+	if err != nil {
+		return models.JobFailed, err.Error(), nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, req)
+
+	if recorder.Code < 200 || recorder.Code > 299 {
+		return models.JobFailed, http.StatusText(recorder.Code), nil
+	}
+
+	body := recorder.Body.Bytes()
+	if mediaType, _, _ := mime.ParseMediaType(recorder.Header().Get("Content-Type")); recorder.Code == http.StatusOK && mediaType == "text/csv" {
+		// a CSV export: keep the body as a string
+		text, _ := json.Marshal(string(body))
+		return models.JobSucceeded, "", text
+	}
+
+	var envelope struct {
+		Meta helper.Meta     `json:"meta"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return models.JobFailed, "unreadable response: " + err.Error(), nil
+	}
+
+	if envelope.Meta.Status != "SUCCESS" {
+		return models.JobFailed, envelope.Meta.Message, envelope.Data
+	}
+	return models.JobSucceeded, envelope.Meta.Message, envelope.Data
+}
+
+// finish must be called with the lock held; the caller saves a snapshot
+// after releasing it.
+func (s *jobService) finish(job *models.Job, status string, message string, result json.RawMessage) {
+	now := time.Now().UTC()
+	job.Status = status
+	job.Message = message
+	job.Result = result
+	job.FinishedAt = &now
+	if status == models.JobSucceeded {
+		job.Progress = 100
+	}
+
+	s.evict(now)
+}
+
+// evict drops the finished jobs past the retention and, beyond maxJobs, the
+// oldest finished ones. It must be called with the lock held.
+func (s *jobService) evict(now time.Time) {
+	var finished []*models.Job
+	for id, job := range s.jobs {
+		if !job.Done() || job.FinishedAt == nil {
+			continue
+		}
+		if s.retention > 0 && now.Sub(*job.FinishedAt) > s.retention {
+			delete(s.jobs, id)
+			continue
+		}
+		finished = append(finished, job)
+	}
+
+	if s.maxJobs > 0 && len(s.jobs) > s.maxJobs {
+		sort.Slice(finished, func(i, j int) bool {
+			return finished[i].FinishedAt.Before(*finished[j].FinishedAt)
+		})
+		for _, job := range finished {
+			if len(s.jobs) <= s.maxJobs {
+				break
+			}
+			delete(s.jobs, job.ID)
+		}
+	}
+}
+
+func newJobID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("new job id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// snapshot copies the jobs for save. It must be called with the lock held,
+// after the changes to be saved.
+func (s *jobService) snapshot() jobSnapshot {
+	jobs := make(map[string]models.Job, len(s.jobs))
+	for id, job := range s.jobs {
+		jobs[id] = *job
+	}
+	s.version++
+	return jobSnapshot{version: s.version, jobs: jobs}
+}
+
+// save writes snapshot unless a newer one has been written already. Errors
+// are logged: the jobs live on in memory either way.
+func (s *jobService) save(snapshot jobSnapshot) {
+	if s.path == "" {
+		return
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if snapshot.version <= s.written {
+		return
+	}
+
+	if err := helper.WriteFileAtomic(s.path, snapshot.jobs); err != nil {
+		log.Printf("jobs: save job store: %v", err)
+		return
+	}
+	s.written = snapshot.version
+}
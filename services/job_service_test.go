@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"id/projects/market-data/helper"
+	"id/projects/market-data/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestJobService(t *testing.T) *jobService {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(gin.RecoveryWithWriter(io.Discard))
+	r.GET("/panic", func(c *gin.Context) {
+		panic("handler failed")
+	})
+	r.GET("/csv", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte("date,equity\n2024-01-02,100\n"))
+	})
+	r.GET("/text", func(c *gin.Context) {
+		c.String(http.StatusOK, "not an envelope")
+	})
+	r.GET("/ok", func(c *gin.Context) {
+		c.JSON(http.StatusOK, helper.APIResponse("done", http.StatusOK, "SUCCESS", map[string]int{"answer": 42}))
+	})
+	r.GET("/failed", func(c *gin.Context) {
+		c.JSON(http.StatusOK, helper.APIResponse("bad request", http.StatusBadRequest, "FAILED", nil))
+	})
+
+	service, err := NewJobService(filepath.Join(t.TempDir(), "jobs.json"), 1, 10, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	service.Start(ctx, r)
+	return service
+}
+
+func waitForJob(t *testing.T, service *jobService, id string) models.Job {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := service.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Done() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return models.Job{}
+}
+
+func TestJobResults(t *testing.T) {
+	service := newTestJobService(t)
+
+	csv, _ := json.Marshal("date,equity\n2024-01-02,100\n")
+	tests := []struct {
+		path    string
+		status  string
+		message string
+		result  string
+	}{
+		{"/panic", models.JobFailed, "Internal Server Error", ""},
+		{"/csv", models.JobSucceeded, "", string(csv)},
+		{"/text", models.JobFailed, "", ""},
+		{"/ok", models.JobSucceeded, "done", `{"answer":42}`},
+		{"/failed", models.JobFailed, "bad request", "null"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			submitted, err := service.Submit("test", test.path, json.RawMessage(`{}`))
+			if err != nil {
+				t.Fatalf("Submit: %v", err)
+			}
+
+			job := waitForJob(t, service, submitted.ID)
+			if job.Status != test.status {
+				t.Errorf("status = %s (%s), want %s", job.Status, job.Message, test.status)
+			}
+			if test.message != "" && job.Message != test.message {
+				t.Errorf("message = %q, want %q", job.Message, test.message)
+			}
+			if string(job.Result) != test.result {
+				t.Errorf("result = %s, want %s", job.Result, test.result)
+			}
+		})
+	}
+}
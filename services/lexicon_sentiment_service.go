@@ -0,0 +1,147 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"id/projects/market-data/models"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	LexiconPositive    = "positive"
+	LexiconNegative    = "negative"
+	LexiconUncertainty = "uncertainty"
+	LexiconLitigious   = "litigious"
+)
+
+// negationWindow is how many words before a positive word are searched for a negator
+const negationWindow = 3
+
+var ErrNotTrainable = errors.New("sentiment model cannot be trained")
+
+var negators = map[string]struct{}{
+	"no": {}, "not": {}, "none": {}, "neither": {}, "never": {}, "nobody": {}, "without": {},
+	"isn": {}, "aren": {}, "wasn": {}, "weren": {}, "don": {}, "doesn": {}, "didn": {}, "won": {}, "cannot": {},
+	"tidak": {}, "tak": {}, "bukan": {}, "belum": {}, "tanpa": {},
+}
+
+type lexiconSentimentService struct {
+	Lexicon   map[string]string
+	Tokenizer *Tokenizer
+}
+
+// NewLexiconSentimentService loads a Loughran-McDonald style word list from a
+// CSV file of "category,word" rows, where category is positive, negative,
+// uncertainty or litigious. A missing file yields an empty lexicon.
+func NewLexiconSentimentService(lexiconPath string) (*lexiconSentimentService, error) {
+	lexicon, err := readLexicon(lexiconPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Negators and lexicon entries are matched on the raw words, so no stop words or stemming
+	return &lexiconSentimentService{
+		Lexicon:   lexicon,
+		Tokenizer: NewTokenizer(nil, false, false),
+	}, nil
+}
+
+func (s *lexiconSentimentService) SentimentAnalysis(text string) int {
+	score := s.Score(text)
+	return score.Positive - score.Negative
+}
+
+// SentimentProbability estimates the probability that text is positive from
+// the share of positive words, smoothed so that text without lexicon words is 0.5.
+func (s *lexiconSentimentService) SentimentProbability(text string) float64 {
+	score := s.Score(text)
+	return float64(score.Positive+1) / float64(score.Positive+score.Negative+2)
+}
+
+// Score counts the lexicon words of every category in text. Following
+// Loughran and McDonald, a positive word preceded by a negator within
+// negationWindow words is counted as negative.
+func (s *lexiconSentimentService) Score(text string) models.LexiconScore {
+	var score models.LexiconScore
+
+	tokens := s.Tokenizer.Tokenize(text)
+	for i, token := range tokens {
+		switch s.Lexicon[token] {
+		case LexiconPositive:
+			if negated(tokens, i) {
+				score.Negative++
+			} else {
+				score.Positive++
+			}
+		case LexiconNegative:
+			score.Negative++
+		case LexiconUncertainty:
+			score.Uncertainty++
+		case LexiconLitigious:
+			score.Litigious++
+		}
+	}
+
+	return score
+}
+
+func (s *lexiconSentimentService) Train(samples []models.SentimentTrainSample) error {
+	return ErrNotTrainable
+}
+
+func (s *lexiconSentimentService) Learned() int {
+	return 0
+}
+
+func negated(tokens []string, i int) bool {
+	for j := i - 1; j >= 0 && j >= i-negationWindow; j-- {
+		if _, ok := negators[tokens[j]]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func readLexicon(path string) (map[string]string, error) {
+	lexicon := make(map[string]string)
+	if path == "" {
+		return lexicon, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lexicon, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open sentiment lexicon: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	reader.Comment = '#'
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read sentiment lexicon: %w", err)
+		}
+
+		category := strings.ToLower(strings.TrimSpace(record[0]))
+		switch category {
+		case LexiconPositive, LexiconNegative, LexiconUncertainty, LexiconLitigious:
+		default:
+			return nil, fmt.Errorf("read sentiment lexicon: unknown category %q", record[0])
+		}
+
+		lexicon[strings.ToLower(strings.TrimSpace(record[1]))] = category
+	}
+
+	return lexicon, nil
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"id/projects/market-data/models"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// yahooHeadlineFeed is Yahoo Finance's RSS feed of headlines for a symbol
+const yahooHeadlineFeed = "https://feeds.finance.yahoo.com/rss/2.0/headline?s=%s&region=US&lang=en-US"
+
+type newsIngestService struct {
+	Feeds []string
+	// FeedSymbols tags every article of a feed with symbols, on top of the
+	// ones the dictionary finds in its text.
+	FeedSymbols map[string][]string
+	Store       NewsStore
+	Dictionary  *SymbolDictionary
+	Client      *http.Client
+	Interval    time.Duration
+}
+
+type NewsIngestService interface {
+	Ingest(ctx context.Context) (int, error)
+	Run(ctx context.Context)
+}
+
+// rssItem and atomEntry cover the RSS 2.0 and Atom elements we store
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	Description string `xml:"description"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string `xml:"pubDate"`
+	Source      string `xml:"source"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Links     []atomLink `xml:"link"`
+	Summary   string     `xml:"summary"`
+	Content   string     `xml:"content"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+type feedDocument struct {
+	XMLName xml.Name
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02 15:04:05",
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// NewNewsIngestService polls feeds every interval. Without feeds it falls
+// back to the Yahoo Finance headline feed of every dictionary symbol, so news
+// is collected out of the box.
+func NewNewsIngestService(feeds []string, store NewsStore, dictionary *SymbolDictionary, interval time.Duration) *newsIngestService {
+	s := &newsIngestService{
+		Feeds:       feeds,
+		FeedSymbols: make(map[string][]string),
+		Store:       store,
+		Dictionary:  dictionary,
+		Client:      &http.Client{Timeout: 30 * time.Second},
+		Interval:    interval,
+	}
+
+	if len(s.Feeds) == 0 {
+		for _, symbol := range dictionary.Symbols() {
+			feed := fmt.Sprintf(yahooHeadlineFeed, url.QueryEscape(symbol))
+			s.Feeds = append(s.Feeds, feed)
+			s.FeedSymbols[feed] = []string{symbol}
+		}
+	}
+
+	return s
+}
+
+// Run ingests every feed once immediately and then every Interval until ctx
+// is cancelled. Failures are logged and retried on the next tick.
+func (s *newsIngestService) Run(ctx context.Context) {
+	if len(s.Feeds) == 0 || s.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		added, err := s.Ingest(ctx)
+		if err != nil {
+			log.Printf("news ingest: %v", err)
+		} else if added > 0 {
+			log.Printf("news ingest: stored %d new articles", added)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ingest fetches every feed once, tags the articles with symbols and stores
+// the new ones. A feed that fails does not stop the others; the first error
+// is returned after all feeds were tried.
+func (s *newsIngestService) Ingest(ctx context.Context) (int, error) {
+	var articles []models.NewsArticle
+	var firstErr error
+
+	for _, feed := range s.Feeds {
+		fetched, err := s.fetchFeed(ctx, feed)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		articles = append(articles, fetched...)
+	}
+
+	added, err := s.Store.Save(articles)
+	if err != nil {
+		return added, err
+	}
+
+	return added, firstErr
+}
+
+func (s *newsIngestService) fetchFeed(ctx context.Context, feed string) ([]models.NewsArticle, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch feed %s: %w", feed, err)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch feed %s: %w", feed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch feed %s: unexpected status %s", feed, resp.Status)
+	}
+
+	var document feedDocument
+	decoder := xml.NewDecoder(resp.Body)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("parse feed %s: %w", feed, err)
+	}
+
+	fetchedAt := time.Now().UTC()
+
+	var articles []models.NewsArticle
+	switch document.XMLName.Local {
+	case "rss":
+		for _, item := range document.Channel.Items {
+			source := item.Source
+			if source == "" {
+				source = document.Channel.Title
+			}
+
+			body := item.Content
+			if body == "" {
+				body = item.Description
+			}
+
+			articles = append(articles, s.newArticle(item.GUID, item.Link, item.Title, body, source, item.PubDate, fetchedAt))
+		}
+	case "feed":
+		for _, entry := range document.Entries {
+			body := entry.Content
+			if body == "" {
+				body = entry.Summary
+			}
+
+			published := entry.Published
+			if published == "" {
+				published = entry.Updated
+			}
+
+			articles = append(articles, s.newArticle(entry.ID, entry.link(), entry.Title, body, document.Title, published, fetchedAt))
+		}
+	default:
+		return nil, fmt.Errorf("parse feed %s: unsupported document <%s>", feed, document.XMLName.Local)
+	}
+
+	if symbols := s.FeedSymbols[feed]; len(symbols) > 0 {
+		for i := range articles {
+			articles[i].Symbols = mergeSymbols(articles[i].Symbols, symbols)
+		}
+	}
+
+	return articles, nil
+}
+
+func mergeSymbols(symbols []string, extra []string) []string {
+	for _, symbol := range extra {
+		found := false
+		for _, existing := range symbols {
+			if existing == symbol {
+				found = true
+				break
+			}
+		}
+		if !found {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+func (s *newsIngestService) newArticle(guid string, link string, title string, body string, source string, published string, fetchedAt time.Time) models.NewsArticle {
+	title = cleanText(title)
+	body = cleanText(body)
+
+	key := strings.TrimSpace(guid)
+	if key == "" {
+		key = strings.TrimSpace(link)
+	}
+	if key == "" {
+		key = source + "\n" + title
+	}
+	sum := sha1.Sum([]byte(key))
+
+	return models.NewsArticle{
+		ID:          hex.EncodeToString(sum[:]),
+		Title:       title,
+		Body:        body,
+		Link:        strings.TrimSpace(link),
+		Source:      cleanText(source),
+		PublishedAt: parseFeedDate(published),
+		FetchedAt:   fetchedAt,
+		Symbols:     s.Dictionary.Tag(title + "\n" + body),
+	}
+}
+
+func (e atomEntry) link() string {
+	for _, link := range e.Links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	if len(e.Links) > 0 {
+		return e.Links[0].Href
+	}
+	return ""
+}
+
+func parseFeedDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// cleanText strips markup from feed fields, which often carry HTML snippets
+func cleanText(text string) string {
+	text = html.UnescapeString(htmlTag.ReplaceAllString(text, " "))
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newFeedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/headlines.rss", "/headlines.atom":
+			data, err := os.ReadFile(filepath.Join("testdata", r.URL.Path))
+			if err != nil {
+				t.Errorf("read fixture: %v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Write(data)
+		case "/broken":
+			w.Write([]byte("<html><body>not a feed</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestDictionary(t *testing.T) *SymbolDictionary {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "symbols.csv")
+	rows := "BBCA.JK,BBCA|Bank Central Asia\nTLKM.JK,TLKM|Telkom\nAAPL,AAPL|Apple Inc|Apple\n"
+	if err := os.WriteFile(path, []byte(rows), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dictionary, err := NewSymbolDictionary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dictionary
+}
+
+func TestIngestParsesRSSAndAtom(t *testing.T) {
+	server := newFeedServer(t)
+	store, err := NewNewsStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewNewsIngestService([]string{server.URL + "/headlines.rss", server.URL + "/headlines.atom"}, store, newTestDictionary(t), time.Hour)
+	added, err := service.Ingest(context.Background())
+	if err != nil {
+		t.Fatalf("Ingest() error = %v", err)
+	}
+
+	// the Atom copy of the BBCA story shares its link with the RSS item
+	if added != 4 {
+		t.Fatalf("Ingest() added %d articles, want 4", added)
+	}
+
+	articles, total := store.Search(NewsQuery{})
+	if total != 4 {
+		t.Fatalf("store holds %d articles, want 4", total)
+	}
+
+	byLink := make(map[string]int)
+	for i, article := range articles {
+		byLink[article.Link] = i
+	}
+
+	profit := articles[byLink["https://example.com/bbca-profit"]]
+	if profit.Body != "BBCA net income rose 12%." {
+		t.Errorf("RSS body = %q, want markup stripped", profit.Body)
+	}
+	if profit.Source != "Market Wire" {
+		t.Errorf("RSS source = %q, want the channel title", profit.Source)
+	}
+	if want := time.Date(2024, 1, 2, 2, 30, 0, 0, time.UTC); !profit.PublishedAt.Equal(want) {
+		t.Errorf("RSS publish time = %v, want %v", profit.PublishedAt, want)
+	}
+	if len(profit.Symbols) != 1 || profit.Symbols[0] != "BBCA.JK" {
+		t.Errorf("RSS symbols = %v, want [BBCA.JK]", profit.Symbols)
+	}
+
+	update := articles[byLink["https://example.com/update-1"]]
+	if update.Body != "Telkom led gains as stocks were mixed." || update.Source != "Wire Desk" {
+		t.Errorf("RSS item = %q from %q, want content:encoded from the item source", update.Body, update.Source)
+	}
+	if _, ok := byLink["https://example.com/update-2"]; !ok {
+		t.Error("second story with the same generic headline was dropped")
+	}
+
+	apple := articles[byLink["https://example.org/apple-chips"]]
+	if apple.Source != "Tech Daily" || apple.Body != "Apple Inc said the chips ship next year." {
+		t.Errorf("Atom entry = %q from %q", apple.Body, apple.Source)
+	}
+	if want := time.Date(2024, 1, 4, 8, 0, 0, 0, time.UTC); !apple.PublishedAt.Equal(want) {
+		t.Errorf("Atom publish time = %v, want the updated time %v", apple.PublishedAt, want)
+	}
+
+	again, err := service.Ingest(context.Background())
+	if err != nil || again != 0 {
+		t.Errorf("second Ingest() = %d, %v, want 0 new articles", again, err)
+	}
+}
+
+func TestIngestKeepsGoingAfterAFailedFeed(t *testing.T) {
+	server := newFeedServer(t)
+	store, err := NewNewsStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feeds := []string{server.URL + "/missing", server.URL + "/broken", server.URL + "/headlines.atom"}
+	service := NewNewsIngestService(feeds, store, newTestDictionary(t), time.Hour)
+
+	added, err := service.Ingest(context.Background())
+	if err == nil {
+		t.Error("Ingest() error = nil, want the first feed's error")
+	}
+	if added != 2 {
+		t.Errorf("Ingest() added %d articles, want the 2 of the working feed", added)
+	}
+}
+
+func TestIngestDefaultsToYahooHeadlines(t *testing.T) {
+	store, err := NewNewsStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewNewsIngestService(nil, store, newTestDictionary(t), time.Hour)
+	if len(service.Feeds) != 3 {
+		t.Fatalf("default feeds = %v, want one per dictionary symbol", service.Feeds)
+	}
+	if want := "https://feeds.finance.yahoo.com/rss/2.0/headline?s=BBCA.JK&region=US&lang=en-US"; service.Feeds[0] != want {
+		t.Errorf("first default feed = %q, want %q", service.Feeds[0], want)
+	}
+
+	// articles of a symbol's feed are about that symbol even when their text
+	// never names it
+	server := newFeedServer(t)
+	feed := server.URL + "/headlines.rss"
+	service.Feeds = []string{feed}
+	service.FeedSymbols = map[string][]string{feed: {"TLKM.JK"}}
+	if _, err := service.Ingest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if articles := store.FindBySymbol("TLKM.JK"); len(articles) != 3 {
+		t.Errorf("FindBySymbol(TLKM.JK) = %d articles, want all 3 of its feed", len(articles))
+	}
+}
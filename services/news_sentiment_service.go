@@ -0,0 +1,69 @@
+package services
+
+import (
+	"errors"
+	"id/projects/market-data/models"
+	"time"
+)
+
+var ErrUnknownSentimentModel = errors.New("unknown sentiment model")
+
+type newsSentimentService struct {
+	Store           NewsStore
+	Models          map[string]SentimenService
+	DefaultModel    string
+	DefaultHalfLife time.Duration
+}
+
+type NewsSentimentService interface {
+	Model(name string) (SentimenService, string, bool)
+	HalfLife(value string) (time.Duration, error)
+	SymbolSentiment(symbol string, model string, halfLife time.Duration) (models.SentimentStatistics, []models.ArticleSentiment, error)
+}
+
+// NewNewsSentimentService scores stored news with the sentiment models given
+// by name; defaultModel and defaultHalfLife apply when a caller leaves them empty.
+func NewNewsSentimentService(store NewsStore, sentimentServices map[string]SentimenService, defaultModel string, defaultHalfLife time.Duration) *newsSentimentService {
+	return &newsSentimentService{
+		Store:           store,
+		Models:          sentimentServices,
+		DefaultModel:    defaultModel,
+		DefaultHalfLife: defaultHalfLife,
+	}
+}
+
+// Model looks up a sentiment model by name and returns it with its resolved name.
+func (s *newsSentimentService) Model(name string) (SentimenService, string, bool) {
+	if name == "" {
+		name = s.DefaultModel
+	}
+
+	sentimentService, ok := s.Models[name]
+	return sentimentService, name, ok
+}
+
+// HalfLife parses a duration such as "72h", falling back to the default when empty.
+func (s *newsSentimentService) HalfLife(value string) (time.Duration, error) {
+	if value == "" {
+		return s.DefaultHalfLife, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// SymbolSentiment scores every stored article about symbol and aggregates
+// them as of now.
+func (s *newsSentimentService) SymbolSentiment(symbol string, model string, halfLife time.Duration) (models.SentimentStatistics, []models.ArticleSentiment, error) {
+	sentimentService, _, ok := s.Model(model)
+	if !ok {
+		return models.SentimentStatistics{}, nil, ErrUnknownSentimentModel
+	}
+
+	newsArticles := s.Store.FindBySymbol(symbol)
+
+	articles := make([]models.ArticleSentiment, 0, len(newsArticles))
+	for _, article := range newsArticles {
+		articles = append(articles, ScoreArticle(sentimentService, article))
+	}
+
+	return SentimentStatistics(articles, time.Now(), halfLife), articles, nil
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"id/projects/market-data/helper"
+	"id/projects/market-data/models"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type newsStore struct {
+	path     string
+	articles []models.NewsArticle
+	ids      map[string]struct{}
+	links    map[string]struct{}
+	titles   map[string]struct{}
+	mu       sync.RWMutex
+	// version numbers the snapshots; written is the last one on disk, so a
+	// snapshot that lost the race to writeMu is not written over a newer one
+	version int
+	written int
+	writeMu sync.Mutex
+}
+
+// newsSnapshot is a copy of the articles taken under the lock, written
+// without it.
+type newsSnapshot struct {
+	version  int
+	articles []models.NewsArticle
+}
+
+type NewsStore interface {
+	Save(articles []models.NewsArticle) (int, error)
+	FindBySymbol(symbol string) []models.NewsArticle
+	Search(query NewsQuery) ([]models.NewsArticle, int)
+	Count() int
+}
+
+// NewsQuery filters stored articles. Zero values disable a filter; Start and
+// End are inclusive bounds on the publish time.
+type NewsQuery struct {
+	Symbol  string
+	Start   time.Time
+	End     time.Time
+	Sources []string
+	Offset  int
+	Limit   int
+}
+
+// NewNewsStore keeps ingested articles in memory and persists them as JSON at
+// path, loading whatever was stored there by a previous run.
+func NewNewsStore(path string) (*newsStore, error) {
+	s := &newsStore{
+		path:   path,
+		ids:    make(map[string]struct{}),
+		links:  make(map[string]struct{}),
+		titles: make(map[string]struct{}),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open news store: %w", err)
+	}
+
+	var articles []models.NewsArticle
+	if err := json.Unmarshal(data, &articles); err != nil {
+		return nil, fmt.Errorf("read news store: %w", err)
+	}
+
+	for _, article := range articles {
+		s.add(article)
+	}
+
+	return s, nil
+}
+
+// Save stores the articles that have not been seen before and returns how
+// many were added. Articles are matched by ID and link; only articles
+// without a link fall back to matching by title, as distinct stories often
+// share a generic headline.
+func (s *newsStore) Save(articles []models.NewsArticle) (int, error) {
+	s.mu.Lock()
+	added := 0
+	for _, article := range articles {
+		if s.add(article) {
+			added++
+		}
+	}
+
+	if added == 0 {
+		s.mu.Unlock()
+		return 0, nil
+	}
+
+	snapshot := s.snapshot()
+	s.mu.Unlock()
+
+	return added, s.write(snapshot)
+}
+
+// FindBySymbol returns the articles tagged with symbol, newest first.
+func (s *newsStore) FindBySymbol(symbol string) []models.NewsArticle {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	var articles []models.NewsArticle
+	for _, article := range s.articles {
+		for _, tagged := range article.Symbols {
+			if tagged == symbol {
+				articles = append(articles, article)
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(articles, func(i, j int) bool {
+		return articles[i].PublishedAt.After(articles[j].PublishedAt)
+	})
+
+	return articles
+}
+
+// Search returns one page of the matching articles, newest first, together
+// with the total number of matches.
+func (s *newsStore) Search(query NewsQuery) ([]models.NewsArticle, int) {
+	var articles []models.NewsArticle
+	if query.Symbol != "" {
+		articles = s.FindBySymbol(query.Symbol)
+	} else {
+		s.mu.RLock()
+		articles = append(articles, s.articles...)
+		s.mu.RUnlock()
+
+		sort.SliceStable(articles, func(i, j int) bool {
+			return articles[i].PublishedAt.After(articles[j].PublishedAt)
+		})
+	}
+
+	sources := make(map[string]struct{}, len(query.Sources))
+	for _, source := range query.Sources {
+		sources[strings.ToLower(strings.TrimSpace(source))] = struct{}{}
+	}
+
+	matched := articles[:0]
+	for _, article := range articles {
+		if !query.Start.IsZero() && article.PublishedAt.Before(query.Start) {
+			continue
+		}
+		if !query.End.IsZero() && article.PublishedAt.After(query.End) {
+			continue
+		}
+		if len(sources) > 0 {
+			if _, ok := sources[strings.ToLower(article.Source)]; !ok {
+				continue
+			}
+		}
+		matched = append(matched, article)
+	}
+
+	total := len(matched)
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+	if query.Offset >= total {
+		return []models.NewsArticle{}, total
+	}
+
+	// compared against what is left so a huge limit cannot overflow
+	end := total
+	if query.Limit > 0 && query.Limit < total-query.Offset {
+		end = query.Offset + query.Limit
+	}
+
+	return matched[query.Offset:end], total
+}
+
+func (s *newsStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.articles)
+}
+
+func (s *newsStore) add(article models.NewsArticle) bool {
+	title := normalizeTitle(article.Title)
+
+	if _, ok := s.ids[article.ID]; ok {
+		return false
+	}
+	if article.Link != "" {
+		if _, ok := s.links[article.Link]; ok {
+			return false
+		}
+	} else if _, ok := s.titles[title]; ok && title != "" {
+		return false
+	}
+
+	s.ids[article.ID] = struct{}{}
+	if article.Link != "" {
+		s.links[article.Link] = struct{}{}
+	}
+	if title != "" {
+		s.titles[title] = struct{}{}
+	}
+	s.articles = append(s.articles, article)
+	return true
+}
+
+// snapshot copies the articles for write. It must be called with the lock
+// held, after the changes to be saved.
+func (s *newsStore) snapshot() newsSnapshot {
+	s.version++
+	return newsSnapshot{version: s.version, articles: append([]models.NewsArticle(nil), s.articles...)}
+}
+
+// write replaces the store file atomically so a crash never leaves it
+// truncated, unless a newer snapshot has been written already.
+func (s *newsStore) write(snapshot newsSnapshot) error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if snapshot.version <= s.written {
+		return nil
+	}
+
+	if err := helper.WriteFileAtomic(s.path, snapshot.articles); err != nil {
+		return fmt.Errorf("save news store: %w", err)
+	}
+	s.written = snapshot.version
+	return nil
+}
+
+// normalizeTitle lowercases a headline and collapses punctuation and spacing,
+// so the same story syndicated by several feeds is only stored once.
+func normalizeTitle(title string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !('a' <= r && r <= 'z') && !('0' <= r && r <= '9') && r < 0x80
+	}), " ")
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"id/projects/market-data/helper"
+	"id/projects/market-data/portfolio"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	finance "github.com/piquette/finance-go"
+	"github.com/piquette/finance-go/quote"
+)
+
+type portfolioService struct {
+	path       string
+	currency   string
+	sectors    *SectorDictionary
+	Quote      func(symbol string) (*finance.Quote, error)
+	portfolios map[string]*portfolio.Portfolio
+	mu         sync.Mutex
+}
+
+type PortfolioService interface {
+	Create(name string, currency string) (portfolio.Portfolio, error)
+	Get(id string) (portfolio.Portfolio, error)
+	List() []portfolio.Portfolio
+	AddTransactions(id string, transactions []portfolio.Transaction) (portfolio.Portfolio, error)
+	DeleteTransaction(id string, transactionID string) (portfolio.Portfolio, error)
+	Valuation(ctx context.Context, id string, method portfolio.CostMethod) (portfolio.Valuation, error)
+}
+
+// NewPortfolioService keeps portfolios as JSON at path. Portfolios created
+// without a currency report in currency; holdings are priced with Yahoo
+// quotes and sectors come from sectors.
+func NewPortfolioService(path string, currency string, sectors *SectorDictionary) (*portfolioService, error) {
+	s := &portfolioService{
+		path:       path,
+		currency:   strings.ToUpper(currency),
+		sectors:    sectors,
+		Quote:      quote.Get,
+		portfolios: make(map[string]*portfolio.Portfolio),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open portfolios: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.portfolios); err != nil {
+		return nil, fmt.Errorf("read portfolios: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *portfolioService) Create(name string, currency string) (portfolio.Portfolio, error) {
+	id, err := newPortfolioID()
+	if err != nil {
+		return portfolio.Portfolio{}, err
+	}
+
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = s.currency
+	}
+
+	p := &portfolio.Portfolio{
+		ID:           id,
+		Name:         name,
+		Currency:     currency,
+		Transactions: []portfolio.Transaction{},
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.portfolios[id] = p
+	return copyPortfolio(p), s.write()
+}
+
+func (s *portfolioService) Get(id string) (portfolio.Portfolio, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.portfolios[id]
+	if !ok {
+		return portfolio.Portfolio{}, portfolio.ErrPortfolioNotFound
+	}
+	return copyPortfolio(p), nil
+}
+
+func (s *portfolioService) List() []portfolio.Portfolio {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	portfolios := make([]portfolio.Portfolio, 0, len(s.portfolios))
+	for _, p := range s.portfolios {
+		portfolios = append(portfolios, copyPortfolio(p))
+	}
+	sort.Slice(portfolios, func(i, j int) bool {
+		return portfolios[i].CreatedAt.Before(portfolios[j].CreatedAt)
+	})
+	return portfolios
+}
+
+// AddTransactions records transactions all together or not at all: the
+// portfolio is replayed with them first, so a sell of more than was held at
+// the time is refused.
+func (s *portfolioService) AddTransactions(id string, transactions []portfolio.Transaction) (portfolio.Portfolio, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.portfolios[id]
+	if !ok {
+		return portfolio.Portfolio{}, portfolio.ErrPortfolioNotFound
+	}
+
+	added := make([]portfolio.Transaction, 0, len(transactions))
+	for _, t := range transactions {
+		t.Symbol = strings.ToUpper(strings.TrimSpace(t.Symbol))
+		t.Currency = strings.ToUpper(strings.TrimSpace(t.Currency))
+		if t.Currency == p.Currency {
+			t.Currency = ""
+		}
+		if err := t.Validate(); err != nil {
+			return portfolio.Portfolio{}, err
+		}
+
+		transactionID, err := newPortfolioID()
+		if err != nil {
+			return portfolio.Portfolio{}, err
+		}
+		t.ID = transactionID
+		added = append(added, t)
+	}
+
+	all := append(append([]portfolio.Transaction(nil), p.Transactions...), added...)
+	if _, err := portfolio.Replay(all, portfolio.FIFO); err != nil {
+		return portfolio.Portfolio{}, err
+	}
+
+	p.Transactions = all
+	return copyPortfolio(p), s.write()
+}
+
+// DeleteTransaction removes a transaction unless that would leave a later
+// sell uncovered.
+func (s *portfolioService) DeleteTransaction(id string, transactionID string) (portfolio.Portfolio, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.portfolios[id]
+	if !ok {
+		return portfolio.Portfolio{}, portfolio.ErrPortfolioNotFound
+	}
+
+	kept := make([]portfolio.Transaction, 0, len(p.Transactions))
+	for _, t := range p.Transactions {
+		if t.ID != transactionID {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == len(p.Transactions) {
+		return portfolio.Portfolio{}, portfolio.ErrTransactionNotFound
+	}
+
+	if _, err := portfolio.Replay(kept, portfolio.FIFO); err != nil {
+		return portfolio.Portfolio{}, err
+	}
+
+	p.Transactions = kept
+	return copyPortfolio(p), s.write()
+}
+
+// Valuation replays a portfolio with method and marks it to the latest
+// quotes. Holdings in other currencies are converted to the portfolio's at
+// Yahoo's exchange rate; quotes that fail are reported on the holding
+// instead of failing the whole valuation.
+func (s *portfolioService) Valuation(ctx context.Context, id string, method portfolio.CostMethod) (portfolio.Valuation, error) {
+	p, err := s.Get(id)
+	if err != nil {
+		return portfolio.Valuation{}, err
+	}
+
+	ledger, err := portfolio.Replay(p.Transactions, method)
+	if err != nil {
+		return portfolio.Valuation{}, err
+	}
+
+	quotes := make(map[string]portfolio.Quote)
+	currencies := make(map[string]bool)
+	for _, holding := range ledger.Holdings {
+		if ctx.Err() != nil {
+			return portfolio.Valuation{}, ctx.Err()
+		}
+
+		q, err := s.Quote(holding.Symbol)
+		if err != nil || q == nil {
+			continue
+		}
+
+		currency := strings.ToUpper(q.CurrencyID)
+		quotes[holding.Symbol] = portfolio.Quote{
+			Symbol:        holding.Symbol,
+			Name:          q.ShortName,
+			Currency:      currency,
+			Price:         q.RegularMarketPrice,
+			PreviousClose: q.RegularMarketPreviousClose,
+			Sector:        s.sectors.Sector(holding.Symbol),
+		}
+		currencies[currency] = true
+	}
+	for currency := range ledger.Fees {
+		currencies[currency] = true
+	}
+
+	rates := make(map[string]float64)
+	for currency := range currencies {
+		if currency == "" || currency == p.Currency {
+			continue
+		}
+
+		// Yahoo quotes currency pairs as e.g. USDIDR=X
+		q, err := s.Quote(currency + p.Currency + "=X")
+		if err != nil || q == nil || q.RegularMarketPrice <= 0 {
+			continue
+		}
+		rates[currency] = q.RegularMarketPrice
+	}
+
+	return portfolio.Value(p, ledger, quotes, rates), nil
+}
+
+func copyPortfolio(p *portfolio.Portfolio) portfolio.Portfolio {
+	c := *p
+	c.Transactions = append([]portfolio.Transaction{}, p.Transactions...)
+	return c
+}
+
+func newPortfolioID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("new portfolio id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// write replaces the store file atomically so a crash never leaves it truncated
+func (s *portfolioService) write() error {
+	if s.path == "" {
+		return nil
+	}
+
+	if err := helper.WriteFileAtomic(s.path, s.portfolios); err != nil {
+		return fmt.Errorf("save portfolios: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"id/projects/market-data/paper"
+	"time"
+
+	"github.com/piquette/finance-go/quote"
+)
+
+// yahooQuoteFeed serves the paper trading engine with Yahoo Finance quotes.
+// Yahoo quotes are delayed, which is good enough to practise with.
+type yahooQuoteFeed struct {
+}
+
+func NewYahooQuoteFeed() paper.QuoteFeed {
+	return &yahooQuoteFeed{}
+}
+
+func (f *yahooQuoteFeed) Quote(ctx context.Context, symbol string) (paper.Quote, error) {
+	params := &quote.Params{Symbols: []string{symbol}}
+	params.Context = &ctx
+
+	iter := quote.ListP(params)
+	if !iter.Next() {
+		if err := iter.Err(); err != nil {
+			return paper.Quote{}, err
+		}
+		return paper.Quote{}, fmt.Errorf("no quote for %s", symbol)
+	}
+	q := iter.Quote()
+
+	// report the time in the exchange's zone so day orders expire on the
+	// exchange's calendar rather than the server's
+	location, err := time.LoadLocation(q.ExchangeTimezoneName)
+	if err != nil || q.ExchangeTimezoneName == "" {
+		location = time.FixedZone(q.ExchangeTimezoneShortName, q.GMTOffSetMilliseconds/1000)
+	}
+
+	return paper.Quote{
+		Symbol:  q.Symbol,
+		Bid:     q.Bid,
+		Ask:     q.Ask,
+		BidSize: float64(q.BidSize),
+		AskSize: float64(q.AskSize),
+		Last:    q.RegularMarketPrice,
+		Time:    time.Unix(int64(q.RegularMarketTime), 0).In(location),
+	}, nil
+}
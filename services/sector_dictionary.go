@@ -0,0 +1,60 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type SectorDictionary struct {
+	sectors map[string]string
+}
+
+// NewSectorDictionary reads a CSV file of "symbol,sector" rows, e.g.
+// "BBCA.JK,Financial Services". Yahoo's quote endpoint carries no sector, so
+// this is where portfolio allocation gets it from. A missing file yields an
+// empty dictionary.
+func NewSectorDictionary(path string) (*SectorDictionary, error) {
+	dictionary := &SectorDictionary{sectors: make(map[string]string)}
+	if path == "" {
+		return dictionary, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return dictionary, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open sector dictionary: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	reader.Comment = '#'
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read sector dictionary: %w", err)
+		}
+
+		symbol := strings.ToUpper(strings.TrimSpace(record[0]))
+		if sector := strings.TrimSpace(record[1]); symbol != "" && sector != "" {
+			dictionary.sectors[symbol] = sector
+		}
+	}
+
+	return dictionary, nil
+}
+
+// Sector returns the sector of symbol, or "" when it is not listed.
+func (d *SectorDictionary) Sector(symbol string) string {
+	return d.sectors[strings.ToUpper(symbol)]
+}
@@ -1,21 +1,183 @@
 package services
 
-import "github.com/jbrukh/bayesian"
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"id/projects/market-data/models"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/jbrukh/bayesian"
+)
+
+const (
+	Negative bayesian.Class = "negative"
+	Positive bayesian.Class = "positive"
+)
 
 type sentimentService struct {
-	Model *bayesian.Classifier
+	Model     *bayesian.Classifier
+	Tokenizer *Tokenizer
+	modelPath string
+	mu        sync.RWMutex
 }
 
 type SentimenService interface {
 	SentimentAnalysis(text string) int
+	SentimentProbability(text string) float64
+	Train(samples []models.SentimentTrainSample) error
+	Learned() int
 }
 
-func NewSentimenService() *sentimentService {
-	model := bayesian.NewClassifier("negative", "positive")
-	return &sentimentService{Model: model}
+// NewSentimenService loads the persisted classifier from modelPath. When no
+// model has been saved yet, a new classifier is trained from the labeled
+// corpus at corpusPath (if present) and written to modelPath. The tokenizer
+// must match the one the persisted model was trained with.
+func NewSentimenService(modelPath string, corpusPath string, tokenizer *Tokenizer) (*sentimentService, error) {
+	s := &sentimentService{Tokenizer: tokenizer, modelPath: modelPath}
+
+	model, err := bayesian.NewClassifierFromFile(modelPath)
+	if err == nil {
+		s.Model = model
+		return s, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("load sentiment model: %w", err)
+	}
+
+	s.Model = bayesian.NewClassifier(Negative, Positive)
+
+	samples, err := readCorpus(corpusPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return s, nil
+	}
+
+	if err := s.Train(samples); err != nil {
+		return nil, err
+	}
+
+	return s, nil
 }
 
 func (s *sentimentService) SentimentAnalysis(text string) int {
-	scores, _, _ := s.Model.LogScores([]string{text})
-	return int(scores[1] - scores[0])
+	return int(s.logOdds(text))
+}
+
+// SentimentProbability is the posterior probability that text is positive.
+func (s *sentimentService) SentimentProbability(text string) float64 {
+	return 1 / (1 + math.Exp(-s.logOdds(text)))
+}
+
+// logOdds is the log-likelihood ratio of the positive over the negative class
+func (s *sentimentService) logOdds(text string) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// An untrained classifier has no priors, so every score is -Inf
+	if s.Model.Learned() == 0 {
+		return 0
+	}
+
+	scores, _, _ := s.Model.LogScores(s.Tokenizer.Tokenize(text))
+	return scores[1] - scores[0]
+}
+
+func (s *sentimentService) Train(samples []models.SentimentTrainSample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Validate every label first so a bad sample does not leave the model half trained
+	classes := make([]bayesian.Class, len(samples))
+	for i, sample := range samples {
+		class, err := sentimentClass(sample.Label)
+		if err != nil {
+			return err
+		}
+		classes[i] = class
+	}
+
+	for i, sample := range samples {
+		s.Model.Learn(s.Tokenizer.Tokenize(sample.Text), classes[i])
+	}
+
+	return s.save()
+}
+
+func (s *sentimentService) Learned() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Model.Learned()
+}
+
+func (s *sentimentService) save() error {
+	if s.modelPath == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.modelPath), 0755); err != nil {
+		return fmt.Errorf("save sentiment model: %w", err)
+	}
+
+	if err := s.Model.WriteToFile(s.modelPath); err != nil {
+		return fmt.Errorf("save sentiment model: %w", err)
+	}
+
+	return nil
+}
+
+func sentimentClass(label string) (bayesian.Class, error) {
+	switch strings.ToLower(strings.TrimSpace(label)) {
+	case string(Positive):
+		return Positive, nil
+	case string(Negative):
+		return Negative, nil
+	}
+	return "", fmt.Errorf("unknown sentiment label %q", label)
+}
+
+// readCorpus reads a CSV file of "label,text" rows. A missing file is not an error.
+func readCorpus(path string) ([]models.SentimentTrainSample, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open sentiment corpus: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	reader.Comment = '#'
+
+	var samples []models.SentimentTrainSample
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read sentiment corpus: %w", err)
+		}
+
+		samples = append(samples, models.SentimentTrainSample{
+			Label: record[0],
+			Text:  record[1],
+		})
+	}
+
+	return samples, nil
 }
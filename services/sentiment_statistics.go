@@ -0,0 +1,223 @@
+package services
+
+import (
+	"id/projects/market-data/models"
+	"math"
+	"sort"
+	"time"
+)
+
+func SentimentLabel(score float64) string {
+	if score > 0 {
+		return "positive"
+	} else if score < 0 {
+		return "negative"
+	}
+	return "neutral"
+}
+
+// ScoreArticle runs the sentiment model over the title and body of article.
+func ScoreArticle(sentimentService SentimenService, article models.NewsArticle) models.ArticleSentiment {
+	text := article.Title + " " + article.Body
+	score := sentimentService.SentimentAnalysis(text)
+
+	scored := models.ArticleSentiment{
+		Title:       article.Title,
+		Source:      article.Source,
+		PublishedAt: article.PublishedAt,
+		Score:       score,
+		Probability: sentimentService.SentimentProbability(text),
+		Label:       SentimentLabel(float64(score)),
+	}
+
+	// lexicon models also report the categories that do not move the score
+	if scorer, ok := sentimentService.(lexiconScorer); ok {
+		categories := scorer.Score(text)
+		scored.Categories = &categories
+	}
+
+	return scored
+}
+
+type lexiconScorer interface {
+	Score(text string) models.LexiconScore
+}
+
+// SentimentStatistics aggregates scored articles. The weighted score weights
+// every article by the model's confidence, |2p - 1|, so articles the model is
+// unsure about barely move it. The decayed score halves an article's weight
+// for every halfLife it was published before now.
+func SentimentStatistics(articles []models.ArticleSentiment, now time.Time, halfLife time.Duration) models.SentimentStatistics {
+	var stats models.SentimentStatistics
+	stats.Count = len(articles)
+	if stats.Count == 0 {
+		return stats
+	}
+
+	var totalScore, totalProbability, weightedScore, totalWeight float64
+	for _, article := range articles {
+		switch article.Label {
+		case "positive":
+			stats.Positive++
+		case "negative":
+			stats.Negative++
+		default:
+			stats.Neutral++
+		}
+
+		if article.Categories != nil {
+			stats.UncertaintyWords += article.Categories.Uncertainty
+			stats.LitigiousWords += article.Categories.Litigious
+		}
+
+		weight := math.Abs(2*article.Probability - 1)
+		totalScore += float64(article.Score)
+		totalProbability += article.Probability
+		weightedScore += weight * float64(article.Score)
+		totalWeight += weight
+	}
+
+	stats.TotalScore = totalScore
+	stats.MeanScore = totalScore / float64(stats.Count)
+	stats.MeanProbability = totalProbability / float64(stats.Count)
+	if totalWeight > 0 {
+		stats.WeightedScore = weightedScore / totalWeight
+	}
+	stats.PositiveRatio = float64(stats.Positive) / float64(stats.Count)
+	stats.NegativeRatio = float64(stats.Negative) / float64(stats.Count)
+	stats.DecayedScore = DecayedScore(articles, now, halfLife)
+
+	return stats
+}
+
+// DecayWeight is the weight of an article published at publishedAt as seen at
+// now. Articles without a publish time, from the future, or any article when
+// halfLife is not positive get the full weight.
+func DecayWeight(publishedAt time.Time, now time.Time, halfLife time.Duration) float64 {
+	if publishedAt.IsZero() || halfLife <= 0 {
+		return 1
+	}
+
+	age := now.Sub(publishedAt)
+	if age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
+
+// DecayedScore is the mean score of the articles published up to now, each
+// weighted by DecayWeight.
+func DecayedScore(articles []models.ArticleSentiment, now time.Time, halfLife time.Duration) float64 {
+	var score, totalWeight float64
+	for _, article := range articles {
+		if article.PublishedAt.After(now) {
+			continue
+		}
+		weight := DecayWeight(article.PublishedAt, now, halfLife)
+		score += weight * float64(article.Score)
+		totalWeight += weight
+	}
+
+	if totalWeight == 0 {
+		return 0
+	}
+	return score / totalWeight
+}
+
+// MaxSentimentTimeSeriesDays is the longest range SentimentTimeSeries covers
+const MaxSentimentTimeSeriesDays = 3 * 366
+
+// SentimentTimeSeries buckets the articles by publish day between start and
+// end (inclusive) and reports the day's own statistics together with the
+// decayed score of everything published up to the end of that day. Ranges
+// longer than MaxSentimentTimeSeriesDays are cut at that many days.
+func SentimentTimeSeries(articles []models.ArticleSentiment, start time.Time, end time.Time, halfLife time.Duration) []models.SentimentPoint {
+	if last := start.AddDate(0, 0, MaxSentimentTimeSeriesDays-1); end.After(last) {
+		end = last
+	}
+
+	sorted := make([]models.ArticleSentiment, 0, len(articles))
+	for _, article := range articles {
+		if !article.PublishedAt.IsZero() {
+			sorted = append(sorted, article)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].PublishedAt.Before(sorted[j].PublishedAt)
+	})
+
+	// both the day buckets and the decayed sums only move forward through
+	// the sorted articles; the sums are decayed to the end of each day
+	// instead of being recomputed from every article
+	var points []models.SentimentPoint
+	var next, added int
+	var score, weight float64
+	var decayedAt time.Time
+
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		dayEnd := day.AddDate(0, 0, 1)
+
+		for next < len(sorted) && sorted[next].PublishedAt.Before(day) {
+			next++
+		}
+		first := next
+		for next < len(sorted) && sorted[next].PublishedAt.Before(dayEnd) {
+			next++
+		}
+		stats := SentimentStatistics(sorted[first:next], dayEnd, halfLife)
+
+		if !decayedAt.IsZero() {
+			decay := DecayWeight(decayedAt, dayEnd, halfLife)
+			score *= decay
+			weight *= decay
+		}
+		for added < len(sorted) && !sorted[added].PublishedAt.After(dayEnd) {
+			articleWeight := DecayWeight(sorted[added].PublishedAt, dayEnd, halfLife)
+			score += articleWeight * float64(sorted[added].Score)
+			weight += articleWeight
+			added++
+		}
+		decayedAt = dayEnd
+
+		decayed := 0.0
+		if weight > 0 {
+			decayed = score / weight
+		}
+
+		points = append(points, models.SentimentPoint{
+			Date:          day.Format("2006-01-02"),
+			Count:         stats.Count,
+			MeanScore:     stats.MeanScore,
+			WeightedScore: stats.WeightedScore,
+			DecayedScore:  decayed,
+		})
+	}
+
+	return points
+}
+
+// SentimentTrend fits a least-squares line through the daily decayed scores
+// and labels its slope.
+func SentimentTrend(points []models.SentimentPoint) (string, float64) {
+	n := float64(len(points))
+	if n < 2 {
+		return "stable", 0
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, point := range points {
+		x := float64(i)
+		sumX += x
+		sumY += point.DecayedScore
+		sumXY += x * point.DecayedScore
+		sumXX += x * x
+	}
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+
+	if slope > 0 {
+		return "improving", slope
+	} else if slope < 0 {
+		return "deteriorating", slope
+	}
+	return "stable", slope
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type symbolPattern struct {
+	Symbol string
+	Match  *regexp.Regexp
+}
+
+type SymbolDictionary struct {
+	patterns []symbolPattern
+}
+
+// NewSymbolDictionary reads a CSV file of "symbol,alias|alias|..." rows, e.g.
+// "BBCA.JK,BBCA|Bank Central Asia|BCA". Aliases written in upper case (tickers
+// and acronyms) are matched case-sensitively, every other alias ignores case.
+// A missing file yields an empty dictionary.
+func NewSymbolDictionary(path string) (*SymbolDictionary, error) {
+	dictionary := &SymbolDictionary{}
+	if path == "" {
+		return dictionary, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return dictionary, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open symbol dictionary: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	reader.Comment = '#'
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read symbol dictionary: %w", err)
+		}
+
+		symbol := strings.ToUpper(strings.TrimSpace(record[0]))
+
+		var exact, folded []string
+		for _, alias := range strings.Split(record[1], "|") {
+			alias = strings.TrimSpace(alias)
+			if alias == "" {
+				continue
+			}
+			if alias == strings.ToUpper(alias) {
+				exact = append(exact, regexp.QuoteMeta(alias))
+			} else {
+				folded = append(folded, regexp.QuoteMeta(alias))
+			}
+		}
+
+		var alternatives []string
+		if len(exact) > 0 {
+			alternatives = append(alternatives, strings.Join(exact, "|"))
+		}
+		if len(folded) > 0 {
+			alternatives = append(alternatives, "(?i:"+strings.Join(folded, "|")+")")
+		}
+		if len(alternatives) == 0 {
+			continue
+		}
+
+		match, err := regexp.Compile(`\b(?:` + strings.Join(alternatives, "|") + `)\b`)
+		if err != nil {
+			return nil, fmt.Errorf("read symbol dictionary: %w", err)
+		}
+		dictionary.patterns = append(dictionary.patterns, symbolPattern{Symbol: symbol, Match: match})
+	}
+
+	return dictionary, nil
+}
+
+// Tag returns every symbol whose aliases occur in text.
+func (d *SymbolDictionary) Tag(text string) []string {
+	var symbols []string
+	for _, pattern := range d.patterns {
+		if pattern.Match.MatchString(text) {
+			symbols = append(symbols, pattern.Symbol)
+		}
+	}
+	return symbols
+}
+
+// Symbols returns the symbols of the dictionary in file order.
+func (d *SymbolDictionary) Symbols() []string {
+	symbols := make([]string, 0, len(d.patterns))
+	for _, pattern := range d.patterns {
+		symbols = append(symbols, pattern.Symbol)
+	}
+	return symbols
+}
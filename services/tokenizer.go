@@ -0,0 +1,121 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+)
+
+type Tokenizer struct {
+	StopWords map[string]struct{}
+	Stem      bool
+	Bigrams   bool
+}
+
+var englishStopWords = []string{
+	"a", "about", "above", "after", "again", "all", "am", "an", "and", "any", "are", "as", "at",
+	"be", "because", "been", "before", "being", "below", "between", "both", "but", "by",
+	"can", "could", "did", "do", "does", "doing", "down", "during", "each", "few", "for", "from", "further",
+	"had", "has", "have", "having", "he", "her", "here", "hers", "herself", "him", "himself", "his", "how",
+	"i", "if", "in", "into", "is", "it", "its", "itself", "just", "me", "more", "most", "my", "myself",
+	"of", "off", "on", "once", "only", "or", "other", "our", "ours", "ourselves", "out", "over", "own",
+	"same", "she", "should", "so", "some", "such", "than", "that", "the", "their", "theirs", "them",
+	"themselves", "then", "there", "these", "they", "this", "those", "through", "to", "too",
+	"under", "until", "up", "very", "was", "we", "were", "what", "when", "where", "which", "while",
+	"who", "whom", "why", "will", "with", "would", "you", "your", "yours", "yourself", "yourselves",
+	"s", "t", "said", "says", "also",
+}
+
+var indonesianStopWords = []string{
+	"ada", "adalah", "agar", "akan", "aku", "anda", "antara", "apa", "apakah", "atas", "atau",
+	"bagi", "bahwa", "baik", "banyak", "beberapa", "begitu", "belum", "bisa", "dalam", "dan",
+	"dari", "dengan", "di", "dia", "hal", "hanya", "harus", "hingga", "ia", "ini", "itu", "jika",
+	"juga", "kali", "kami", "kamu", "karena", "ke", "kepada", "ketika", "kita", "lagi", "lain",
+	"lalu", "maka", "masih", "mereka", "namun", "oleh", "pada", "para", "saat", "saja", "sama",
+	"sampai", "sangat", "saya", "sebagai", "sebuah", "secara", "sedang", "sehingga", "sejak",
+	"selain", "seperti", "serta", "setelah", "sudah", "tahun", "telah", "tentang", "tersebut",
+	"untuk", "yaitu", "yakni", "yang",
+}
+
+var stopWordLists = map[string][]string{
+	"en": englishStopWords,
+	"id": indonesianStopWords,
+}
+
+// NewTokenizer builds a tokenizer that removes the stop words of the given
+// languages ("en", "id"). Unknown languages are ignored.
+func NewTokenizer(languages []string, stem bool, bigrams bool) *Tokenizer {
+	stopWords := make(map[string]struct{})
+	for _, language := range languages {
+		for _, word := range stopWordLists[strings.ToLower(strings.TrimSpace(language))] {
+			stopWords[word] = struct{}{}
+		}
+	}
+
+	return &Tokenizer{
+		StopWords: stopWords,
+		Stem:      stem,
+		Bigrams:   bigrams,
+	}
+}
+
+// Tokenize lowercases the text, splits it on anything that is not a letter or
+// digit, drops stop words and optionally stems the words and appends bigrams.
+func (t *Tokenizer) Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if _, ok := t.StopWords[field]; ok {
+			continue
+		}
+		if t.Stem {
+			field = stem(field)
+		}
+		if field == "" {
+			continue
+		}
+		tokens = append(tokens, field)
+	}
+
+	if t.Bigrams {
+		words := len(tokens)
+		for i := 0; i+1 < words; i++ {
+			tokens = append(tokens, tokens[i]+"_"+tokens[i+1])
+		}
+	}
+
+	return tokens
+}
+
+var englishSuffixes = []string{"ational", "ization", "fulness", "ousness", "iveness", "ments", "ment", "ingly", "edly", "ness", "ing", "ies", "ied", "ed", "ly", "s"}
+
+var indonesianSuffixes = []string{"nya", "lah", "kah", "pun", "ku", "mu"}
+
+// stem is a light suffix stripper for English inflections and Indonesian
+// particles/possessive pronouns. English suffixes leave at least three
+// characters of the word and Indonesian ones at least four, so short tokens
+// are left intact. The Indonesian rules only apply to words no English
+// suffix matched.
+func stem(word string) string {
+	for _, suffix := range englishSuffixes {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			if suffix == "s" && strings.HasSuffix(word, "ss") {
+				return word
+			}
+			if suffix == "ies" || suffix == "ied" {
+				return strings.TrimSuffix(word, suffix) + "y"
+			}
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+
+	for _, suffix := range indonesianSuffixes {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 4 {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+
+	return word
+}